@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ActionCreate                = "create"
+	ActionDelete                = "delete"
+	ActionAddConfig             = "add-config"
+	ActionRemoveConfig          = "remove-config"
+	ActionDeleteConfigsByLabels = "delete-configs-by-labels"
+	ActionRollback              = "rollback"
+)
+
+// Entry is one recorded mutation. Resource is "configs/{name}" or
+// "groups/{name}"; Version is the version the request targeted and
+// NewVersion the version it produced, if any. The hashes are the ETags of
+// the resource before and after the change.
+type Entry struct {
+	Seq        uint64    `json:"seq"`
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	Resource   string    `json:"resource"`
+	Version    int       `json:"version,omitempty"`
+	NewVersion int       `json:"newVersion,omitempty"`
+	Identity   string    `json:"identity"`
+	RequestID  string    `json:"requestId,omitempty"`
+	BeforeHash string    `json:"beforeHash,omitempty"`
+	AfterHash  string    `json:"afterHash,omitempty"`
+}
+
+type Query struct {
+	// Resource matches an exact resource key or everything below it, so
+	// "groups" selects all groups and "groups/web_configs" one group.
+	Resource string
+	Since    time.Time
+	Identity string
+	Limit    int
+	// Visible, if set, drops the entries it returns false for before the
+	// limit is applied.
+	Visible func(Entry) bool
+}
+
+func (q Query) matches(e Entry) bool {
+	if q.Resource != "" && e.Resource != q.Resource && !strings.HasPrefix(e.Resource, q.Resource+"/") {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if q.Identity != "" && e.Identity != q.Identity {
+		return false
+	}
+	return q.Visible == nil || q.Visible(e)
+}
+
+// Log is an append-only audit store. The newest maxEntries entries are kept
+// in memory for queries; when a sink is configured every entry is also
+// written to it as one JSON line. A nil *Log records nothing.
+type Log struct {
+	mu         sync.RWMutex
+	entries    []Entry
+	maxEntries int
+	seq        uint64
+	sink       io.WriteCloser
+}
+
+func NewLog(maxEntries int) *Log {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	return &Log{
+		entries:    make([]Entry, 0),
+		maxEntries: maxEntries,
+	}
+}
+
+// OpenFile loads the entries already recorded in a JSON-lines file and
+// appends new ones to it.
+func (l *Log) OpenFile(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		l.append(e)
+		if e.Seq > l.seq {
+			l.seq = e.Seq
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return err
+	}
+	l.sink = file
+	return nil
+}
+
+func (l *Log) append(e Entry) {
+	l.entries = append(l.entries, e)
+	if len(l.entries) > l.maxEntries {
+		l.entries = append(l.entries[:0:0], l.entries[len(l.entries)-l.maxEntries:]...)
+	}
+}
+
+// Record assigns the entry a sequence number and timestamp and stores it.
+func (l *Log) Record(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	e.Seq = l.seq
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	l.append(e)
+
+	if l.sink != nil {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := l.sink.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query returns matching entries, oldest first. With a limit only the most
+// recent matches are returned.
+func (l *Log) Query(q Query) []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := make([]Entry, 0)
+	for _, e := range l.entries {
+		if q.matches(e) {
+			result = append(result, e)
+		}
+	}
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[len(result)-q.Limit:]
+	}
+	return result
+}
+
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sink == nil {
+		return nil
+	}
+	return l.sink.Close()
+}
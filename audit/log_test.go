@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func resources(entries []Entry) string {
+	keys := make([]string, 0, len(entries))
+	for _, e := range entries {
+		keys = append(keys, e.Resource)
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestQuery(t *testing.T) {
+	l := NewLog(0)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{Resource: "configs/db", Identity: "alice"},
+		{Resource: "groups/web", Identity: "bob"},
+		{Resource: "groups/web_configs", Identity: "alice"},
+		{Resource: "namespaces/team-a/configs/db", Identity: "bob"},
+		{Resource: "configs/db", Identity: "bob"},
+	} {
+		e.Action = ActionCreate
+		e.Time = start.Add(time.Duration(i) * time.Hour)
+		if err := l.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{"all", Query{}, "configs/db,groups/web,groups/web_configs,namespaces/team-a/configs/db,configs/db"},
+		{"kind", Query{Resource: "groups"}, "groups/web,groups/web_configs"},
+		{"exact key, not a name prefix", Query{Resource: "groups/web"}, "groups/web"},
+		{"namespace", Query{Resource: "namespaces/team-a"}, "namespaces/team-a/configs/db"},
+		{"since", Query{Since: start.Add(3 * time.Hour)}, "namespaces/team-a/configs/db,configs/db"},
+		{"identity", Query{Identity: "alice"}, "configs/db,groups/web_configs"},
+		{"most recent matches", Query{Identity: "bob", Limit: 2}, "namespaces/team-a/configs/db,configs/db"},
+		{"visible before limit", Query{Limit: 1, Visible: func(e Entry) bool { return e.Identity == "alice" }}, "groups/web_configs"},
+	}
+	for _, tt := range tests {
+		if got := resources(l.Query(tt.query)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecordKeepsNewestEntries(t *testing.T) {
+	l := NewLog(2)
+	for _, resource := range []string{"configs/a", "configs/b", "configs/c"} {
+		if err := l.Record(Entry{Resource: resource}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries := l.Query(Query{})
+	if resources(entries) != "configs/b,configs/c" || entries[1].Seq != 3 {
+		t.Errorf("kept %+v, want the newest two with their sequence numbers", entries)
+	}
+
+	var nilLog *Log
+	if err := nilLog.Record(Entry{Resource: "configs/a"}); err != nil {
+		t.Errorf("nil log: %v", err)
+	}
+}
+
+func TestOpenFileReloadsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := NewLog(0)
+	if err := l.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, resource := range []string{"configs/a", "configs/b"} {
+		if err := l.Record(Entry{Action: ActionCreate, Resource: resource}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l = NewLog(0)
+	if err := l.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := l.Record(Entry{Action: ActionDelete, Resource: "configs/a"}); err != nil {
+		t.Fatal(err)
+	}
+	entries := l.Query(Query{})
+	if resources(entries) != "configs/a,configs/b,configs/a" {
+		t.Fatalf("reloaded %+v", entries)
+	}
+	for i, e := range entries {
+		if e.Seq != uint64(i+1) {
+			t.Errorf("entry %d has sequence number %d, want %d", i, e.Seq, i+1)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 3 {
+		t.Errorf("file has %d lines, want 3", lines)
+	}
+}
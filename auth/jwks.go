@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval bounds how often an unknown kid triggers a reload.
+const jwksRefreshInterval = time.Minute
+
+var ErrUnknownKey = errors.New("no matching key in JWKS")
+
+// errUnsupportedKey marks keys of a type or curve the verifier cannot use.
+// Identity providers publish such keys alongside usable ones, so they are
+// skipped rather than failing the whole set.
+var errUnsupportedKey = errors.New("unsupported key")
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// JWKS is a JSON Web Key Set read from a file or an http(s) URL. It is
+// reloaded when a token references a kid it does not know, at most once
+// per jwksRefreshInterval.
+type JWKS struct {
+	source string
+	client *http.Client
+
+	mu       sync.Mutex
+	keys     map[string]interface{} // kid -> *rsa.PublicKey, *ecdsa.PublicKey or []byte
+	loadedAt time.Time
+	// loading is closed when the reload in progress finishes; nil when
+	// none is. loadErr is the result of the last reload.
+	loading chan struct{}
+	loadErr error
+}
+
+func NewJWKS(source string) (*JWKS, error) {
+	set := &JWKS{
+		source: source,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	keys, err := set.load()
+	if err != nil {
+		return nil, err
+	}
+	set.keys = keys
+	set.loadedAt = time.Now()
+	return set, nil
+}
+
+// Key returns the key with the given kid. An empty kid matches the only key
+// in a single-key set. The set is fetched without holding the lock, so
+// known keys are served while a reload is in flight, and callers looking
+// for an unknown kid meanwhile wait for that reload instead of starting
+// another.
+func (s *JWKS) Key(kid string) (interface{}, error) {
+	s.mu.Lock()
+	if key, ok := s.lookup(kid); ok {
+		s.mu.Unlock()
+		return key, nil
+	}
+	loading := s.loading
+	if loading == nil {
+		if time.Since(s.loadedAt) < jwksRefreshInterval {
+			s.mu.Unlock()
+			return nil, ErrUnknownKey
+		}
+		loading = make(chan struct{})
+		s.loading = loading
+		s.loadedAt = time.Now()
+		s.mu.Unlock()
+
+		keys, err := s.load()
+
+		s.mu.Lock()
+		if err == nil {
+			s.keys = keys
+		}
+		s.loadErr = err
+		s.loading = nil
+		close(loading)
+		s.mu.Unlock()
+	} else {
+		s.mu.Unlock()
+		<-loading
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	if s.loadErr != nil {
+		return nil, s.loadErr
+	}
+	return nil, ErrUnknownKey
+}
+
+func (s *JWKS) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// load reads and parses the set. It does not touch the JWKS fields.
+func (s *JWKS) load() (map[string]interface{}, error) {
+	data, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+	keys := make(map[string]interface{}, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if errors.Is(err, errUnsupportedKey) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (s *JWKS) read() ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		return os.ReadFile(s.source)
+	}
+	resp, err := s.client.Get(s.source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 2 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("%w: curve %q", errUnsupportedKey, k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		curve := elliptic.P256()
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.K, "="))
+		if err != nil {
+			return nil, err
+		}
+		return secret, nil
+	}
+	return nil, fmt.Errorf("%w: type %q", errUnsupportedKey, k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
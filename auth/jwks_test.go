@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding
+
+// testKeys are the private halves of the keys in the JWKS file written by
+// writeJWKS.
+type testKeys struct {
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	secret []byte
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKeys{rsa: rsaKey, ec: ecKey, secret: []byte("0123456789abcdef0123456789abcdef")}
+}
+
+// writeJWKS writes a key set with the usable keys "rsa-1", "ec-1" and
+// "oct-1", plus keys the verifier cannot use, and returns its path.
+func writeJWKS(t *testing.T, keys testKeys) string {
+	t.Helper()
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]interface{}{"keys": []map[string]string{
+		{
+			"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256",
+			"n": b64.EncodeToString(keys.rsa.N.Bytes()),
+			"e": b64.EncodeToString(big.NewInt(int64(keys.rsa.E)).Bytes()),
+		},
+		{
+			"kty": "EC", "kid": "ec-1", "crv": "P-256",
+			"x": b64.EncodeToString(keys.ec.X.FillBytes(make([]byte, 32))),
+			"y": b64.EncodeToString(keys.ec.Y.FillBytes(make([]byte, 32))),
+		},
+		{"kty": "oct", "kid": "oct-1", "k": b64.EncodeToString(keys.secret)},
+		// Unsupported type and curve: skipped.
+		{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": b64.EncodeToString(make([]byte, 32))},
+		{
+			"kty": "EC", "kid": "ec-384", "crv": "P-384",
+			"x": b64.EncodeToString(p384.X.FillBytes(make([]byte, 48))),
+			"y": b64.EncodeToString(p384.Y.FillBytes(make([]byte, 48))),
+		},
+		// Encryption key: ignored.
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// signToken returns a compact JWS of claims signed with alg.
+func signToken(t *testing.T, keys testKeys, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, keys.rsa, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, keys.ec, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case "HS256":
+		mac := hmac.New(sha256.New, keys.secret)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+	return signed + "." + b64.EncodeToString(signature)
+}
+
+func TestJWKSSkipsUnsupportedKeys(t *testing.T) {
+	set, err := NewJWKS(writeJWKS(t, newTestKeys(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kid := range []string{"rsa-1", "ec-1", "oct-1"} {
+		if _, err := set.Key(kid); err != nil {
+			t.Errorf("Key(%q): %v", kid, err)
+		}
+	}
+	for _, kid := range []string{"ed-1", "ec-384", "enc-1"} {
+		if _, err := set.Key(kid); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Key(%q) returned %v, want ErrUnknownKey", kid, err)
+		}
+	}
+}
+
+func TestJWKSRejectsMalformedKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	data := `{"keys":[{"kty":"EC","kid":"bad","crv":"P-256","x":"AQ","y":"AQ"}]}`
+	if err := os.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewJWKS(file); err == nil {
+		t.Error("NewJWKS accepted a point that is not on the curve")
+	}
+}
+
+func TestJWKSReloadsForUnknownKid(t *testing.T) {
+	keys := newTestKeys(t)
+	file := writeJWKS(t, keys)
+	set, err := NewJWKS(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := `{"keys":[{"kty":"oct","kid":"oct-2","k":"` + b64.EncodeToString([]byte("rotated")) + `"}]}`
+	if err := os.WriteFile(file, []byte(rotated), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Within the refresh interval an unknown kid does not trigger a reload.
+	if _, err := set.Key("oct-2"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Key(oct-2) returned %v before the refresh interval passed", err)
+	}
+
+	set.mu.Lock()
+	set.loadedAt = time.Now().Add(-2 * jwksRefreshInterval)
+	set.mu.Unlock()
+
+	// Concurrent lookups share one reload; run with -race.
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := set.Key("oct-2")
+			if err == nil && string(key.([]byte)) != "rotated" {
+				err = errors.New("wrong key returned")
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := set.Key("rsa-1"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Key(rsa-1) returned %v after it was removed from the set", err)
+	}
+}
+
+func TestJWKSReloadErrorKeepsKeys(t *testing.T) {
+	file := writeJWKS(t, newTestKeys(t))
+	set, err := NewJWKS(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	set.mu.Lock()
+	set.loadedAt = time.Time{}
+	set.mu.Unlock()
+
+	if _, err := set.Key("missing"); err == nil || errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Key(missing) returned %v, want the parse error", err)
+	}
+	if _, err := set.Key("rsa-1"); err != nil {
+		t.Errorf("a failed reload dropped the loaded keys: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+type JWTOptions struct {
+	// Issuer and Audience are checked against "iss" and "aud" when set.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew when checking exp and nbf.
+	Leeway time.Duration
+	// HMACSecret verifies HS256 tokens in addition to "oct" keys in the
+	// JWKS.
+	HMACSecret []byte
+}
+
+// JWTVerifier validates RS256, ES256 and HS256 tokens and maps their claims
+// to grants through a policy.
+type JWTVerifier struct {
+	keys   *JWKS
+	policy *Policy
+	opts   JWTOptions
+}
+
+// NewJWTVerifier returns a verifier. keys may be nil when only HS256 tokens
+// signed with opts.HMACSecret are accepted.
+func NewJWTVerifier(keys *JWKS, policy *Policy, opts JWTOptions) *JWTVerifier {
+	return &JWTVerifier{
+		keys:   keys,
+		policy: policy,
+		opts:   opts,
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// looksLikeJWT reports whether a bearer token is a compact JWS rather than
+// an API key.
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func (v *JWTVerifier) Authenticate(token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, ErrInvalidToken
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	if err := v.verify(header, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return Principal{}, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	if err := v.validateClaims(claims); err != nil {
+		return Principal{}, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return Principal{}, ErrInvalidToken
+	}
+	roles := v.policy.roles(claims)
+	return Principal{
+		ID:     "jwt:" + subject,
+		Roles:  roles,
+		Grants: v.policy.grants(roles),
+	}, nil
+}
+
+// verify checks the signature. The key type must fit the algorithm so a
+// public key can never be used as an HMAC secret.
+func (v *JWTVerifier) verify(header jwtHeader, signed, signature []byte) error {
+	digest := sha256.Sum256(signed)
+
+	switch header.Alg {
+	case "HS256":
+		secret, err := v.hmacSecret(header.Kid)
+		if err != nil {
+			return err
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrInvalidToken
+		}
+		return nil
+	case "RS256":
+		key, err := v.publicKey(header.Kid)
+		if err != nil {
+			return err
+		}
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidToken
+		}
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidToken
+		}
+		return nil
+	case "ES256":
+		key, err := v.publicKey(header.Kid)
+		if err != nil {
+			return err
+		}
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return ErrInvalidToken
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return ErrInvalidToken
+		}
+		return nil
+	}
+	return ErrInvalidToken
+}
+
+func (v *JWTVerifier) publicKey(kid string) (interface{}, error) {
+	if v.keys == nil {
+		return nil, ErrUnknownKey
+	}
+	return v.keys.Key(kid)
+}
+
+func (v *JWTVerifier) hmacSecret(kid string) ([]byte, error) {
+	if v.keys != nil {
+		if key, err := v.keys.Key(kid); err == nil {
+			if secret, ok := key.([]byte); ok {
+				return secret, nil
+			}
+		}
+	}
+	if len(v.opts.HMACSecret) > 0 {
+		return v.opts.HMACSecret, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (v *JWTVerifier) validateClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return ErrInvalidToken
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.opts.Leeway)) {
+		return ErrTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.opts.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return ErrInvalidToken
+	}
+	if v.opts.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.opts.Issuer {
+			return ErrInvalidToken
+		}
+	}
+	if v.opts.Audience != "" && !containsString(claimStrings(claims, "aud"), v.opts.Audience) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func containsString(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPolicy() *Policy {
+	return &Policy{
+		RolesClaim: "roles",
+		ClaimRoles: []ClaimRole{
+			{Claim: "groups", Value: "platform", Roles: []string{"config-admin"}},
+			{Claim: "scope", Value: "configs.read", Roles: []string{"reader"}},
+		},
+		Roles: map[string][]Grant{
+			"config-admin": {{Permissions: []string{"configs:*"}}},
+			"reader":       {{Permissions: []string{ScopeConfigsRead}}},
+			"web-editor":   {{Permissions: []string{ScopeGroupsWrite}, Resources: []string{"groups/web_*"}}},
+		},
+	}
+}
+
+func TestJWTVerifier(t *testing.T) {
+	keys := newTestKeys(t)
+	set, err := NewJWKS(writeJWKS(t, keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewJWTVerifier(set, testPolicy(), JWTOptions{Issuer: "https://idp", Audience: "ars"})
+
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "alice",
+			"iss": "https://idp",
+			"aud": []string{"other", "ars"},
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		wantErr   error
+		wantRoles []string
+	}{
+		{
+			name:      "RS256",
+			token:     signToken(t, keys, "RS256", "rsa-1", claims(map[string]interface{}{"roles": []string{"web-editor"}})),
+			wantRoles: []string{"web-editor"},
+		},
+		{
+			name:      "ES256",
+			token:     signToken(t, keys, "ES256", "ec-1", claims(nil)),
+			wantRoles: []string{},
+		},
+		{
+			name:      "HS256 with an oct key",
+			token:     signToken(t, keys, "HS256", "oct-1", claims(map[string]interface{}{"aud": "ars"})),
+			wantRoles: []string{},
+		},
+		{
+			name:      "group claim",
+			token:     signToken(t, keys, "RS256", "rsa-1", claims(map[string]interface{}{"groups": []string{"dev", "platform"}})),
+			wantRoles: []string{"config-admin"},
+		},
+		{
+			name:      "string claims are not split",
+			token:     signToken(t, keys, "RS256", "rsa-1", claims(map[string]interface{}{"groups": "not platform"})),
+			wantRoles: []string{},
+		},
+		{
+			name:      "scope is split",
+			token:     signToken(t, keys, "RS256", "rsa-1", claims(map[string]interface{}{"scope": "openid configs.read"})),
+			wantRoles: []string{"reader"},
+		},
+		{
+			name:    "expired",
+			token:   signToken(t, keys, "RS256", "rsa-1", claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})),
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "wrong audience",
+			token:   signToken(t, keys, "RS256", "rsa-1", claims(map[string]interface{}{"aud": "other"})),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "wrong issuer",
+			token:   signToken(t, keys, "RS256", "rsa-1", claims(map[string]interface{}{"iss": "https://evil"})),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "no subject",
+			token:   signToken(t, keys, "RS256", "rsa-1", claims(map[string]interface{}{"sub": ""})),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "unknown kid",
+			token:   signToken(t, keys, "RS256", "rsa-2", claims(nil)),
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:    "key of an unsupported curve",
+			token:   signToken(t, keys, "ES256", "ec-384", claims(nil)),
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:    "algorithm does not fit the key",
+			token:   signToken(t, keys, "ES256", "rsa-1", claims(nil)),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "HMAC with a public key",
+			token:   signToken(t, keys, "HS256", "rsa-1", claims(nil)),
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:    "unsigned",
+			token:   signToken(t, keys, "none", "", claims(nil)),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "tampered payload",
+			token:   tamper(t, signToken(t, keys, "RS256", "rsa-1", claims(nil))),
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := verifier.Authenticate(tt.token)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.ID != "jwt:alice" {
+				t.Errorf("principal ID = %q", principal.ID)
+			}
+			if !reflect.DeepEqual(principal.Roles, tt.wantRoles) {
+				t.Errorf("roles = %v, want %v", principal.Roles, tt.wantRoles)
+			}
+		})
+	}
+}
+
+// tamper replaces the payload of a token with one naming another subject,
+// keeping the original signature.
+func tamper(t *testing.T, token string) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	payload, _ := json.Marshal(map[string]interface{}{"sub": "mallory", "exp": time.Now().Add(time.Hour).Unix()})
+	return parts[0] + "." + b64.EncodeToString(payload) + "." + parts[2]
+}
+
+func TestClaimStrings(t *testing.T) {
+	claims := map[string]interface{}{
+		"scope":  "a b  c",
+		"groups": "not platform",
+		"list":   []interface{}{"x", 1, "y"},
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"admin"},
+		},
+	}
+	tests := map[string][]string{
+		"scope":              {"a", "b", "c"},
+		"groups":             {"not platform"},
+		"list":               {"x", "y"},
+		"realm_access.roles": {"admin"},
+		"missing":            nil,
+		"scope.nested":       nil,
+	}
+	for path, want := range tests {
+		if got := claimStrings(claims, path); !reflect.DeepEqual(got, want) {
+			t.Errorf("claimStrings(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
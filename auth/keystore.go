@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const tokenPrefix = "ars_"
+
+// Key is an issued API key. Only the SHA-256 hash of the token is stored.
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	Prefixes  []string  `json:"prefixes,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type storedKey struct {
+	Key
+	Hash string `json:"hash"`
+}
+
+// KeyStore holds API keys in memory and, when a path is given, persists them
+// to a JSON file on every change.
+type KeyStore struct {
+	mu     sync.RWMutex
+	keys   map[string]storedKey // by ID
+	byHash map[string]string    // token hash -> ID
+	static map[string]Key       // token hash -> key, never persisted
+	path   string
+}
+
+// NewKeyStore loads the keys stored at path. An empty path keeps keys in
+// memory only.
+func NewKeyStore(path string) (*KeyStore, error) {
+	s := &KeyStore{
+		keys:   make(map[string]storedKey),
+		byHash: make(map[string]string),
+		static: make(map[string]Key),
+		path:   path,
+	}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored []storedKey
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	for _, k := range stored {
+		s.keys[k.ID] = k
+		s.byHash[k.Hash] = k.ID
+	}
+	return s, nil
+}
+
+// AddStatic registers a key whose token comes from configuration, such as
+// the bootstrap admin key. It cannot be listed or revoked.
+func (s *KeyStore) AddStatic(name, token string, scopes []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.static[hashToken(token)] = Key{ID: name, Name: name, Scopes: scopes}
+}
+
+// Issue creates a key and returns it with its token. The token is not
+// stored and cannot be recovered later.
+func (s *KeyStore) Issue(name string, scopes, prefixes []string) (Key, string, error) {
+	if len(scopes) == 0 {
+		return Key{}, "", ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return Key{}, "", ErrInvalidScope
+		}
+	}
+	token := RandomToken()
+	key := Key{
+		ID:        randomHex(8),
+		Name:      name,
+		Scopes:    scopes,
+		Prefixes:  prefixes,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := storedKey{Key: key, Hash: hashToken(token)}
+	s.keys[key.ID] = stored
+	s.byHash[stored.Hash] = key.ID
+	if err := s.save(); err != nil {
+		delete(s.keys, key.ID)
+		delete(s.byHash, stored.Hash)
+		return Key{}, "", err
+	}
+	return key, token, nil
+}
+
+func (s *KeyStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	delete(s.keys, id)
+	delete(s.byHash, stored.Hash)
+	if err := s.save(); err != nil {
+		s.keys[id] = stored
+		s.byHash[stored.Hash] = id
+		return err
+	}
+	return nil
+}
+
+// List returns issued keys ordered by creation time.
+func (s *KeyStore) List() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Key, 0, len(s.keys))
+	for _, stored := range s.keys {
+		result = append(result, stored.Key)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+func (s *KeyStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys) + len(s.static)
+}
+
+// Authenticate returns the principal for a bearer token.
+func (s *KeyStore) Authenticate(token string) (Principal, error) {
+	hash := hashToken(token)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key, ok := s.static[hash]; ok {
+		return principalForKey(key), nil
+	}
+	id, ok := s.byHash[hash]
+	if !ok {
+		return Principal{}, ErrUnauthenticated
+	}
+	return principalForKey(s.keys[id].Key), nil
+}
+
+func principalForKey(key Key) Principal {
+	grant := Grant{Permissions: key.Scopes}
+	for _, prefix := range key.Prefixes {
+		grant.Resources = append(grant.Resources, prefixPattern(prefix))
+	}
+	return Principal{
+		ID:     "apikey:" + key.ID,
+		Grants: []Grant{grant},
+	}
+}
+
+// save writes all issued keys atomically. The caller holds s.mu.
+func (s *KeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	stored := make([]storedKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		stored = append(stored, k)
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".keys-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RandomToken returns a new token in the format issued keys use.
+func RandomToken() string {
+	return tokenPrefix + randomHex(24)
+}
+
+// BootstrapToken returns the token stored in file, creating the file with a
+// new random token, readable only by its owner, if it does not exist. The
+// token is therefore never printed, and stays the same across restarts.
+func BootstrapToken(file string) (token string, created bool, err error) {
+	data, err := os.ReadFile(file)
+	if err == nil {
+		token = strings.TrimSpace(string(data))
+		if token == "" {
+			return "", false, fmt.Errorf("%s is empty", file)
+		}
+		return token, false, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", false, err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", false, err
+	}
+	token = RandomToken()
+	if _, err := f.WriteString(token + "\n"); err != nil {
+		f.Close()
+		os.Remove(file)
+		return "", false, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(file)
+		return "", false, err
+	}
+	return token, true, nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
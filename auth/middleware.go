@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Authenticator rejects requests without a valid bearer token (an API key,
+// or a JWT when a verifier is configured) and checks the caller's grants
+// against the matched route. It must run as router middleware so route
+// variables are available.
+type Authenticator struct {
+	keys        *KeyStore
+	jwt         *JWTVerifier
+	exemptPaths map[string]bool
+}
+
+func NewAuthenticator(keys *KeyStore) *Authenticator {
+	return &Authenticator{
+		keys:        keys,
+		exemptPaths: make(map[string]bool),
+	}
+}
+
+// UseJWT accepts JWT bearer tokens verified by v in addition to API keys.
+func (a *Authenticator) UseJWT(v *JWTVerifier) {
+	a.jwt = v
+}
+
+// Exempt lets requests for path through without credentials.
+func (a *Authenticator) Exempt(path string) {
+	a.exemptPaths[path] = true
+}
+
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.exemptPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok {
+			writeAuthError(w, ErrUnauthenticated)
+			return
+		}
+		principal, err := a.authenticate(token)
+		if err != nil {
+			writeAuthError(w, err)
+			return
+		}
+		namespace := requestNamespace(r)
+		resource := requestResource(r)
+		for _, scope := range RequiredScopes(r) {
+			if !principal.Allows(scope, namespace, resource) {
+				writeAuthError(w, ErrForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+func (a *Authenticator) authenticate(token string) (Principal, error) {
+	if a.jwt != nil && looksLikeJWT(token) {
+		return a.jwt.Authenticate(token)
+	}
+	return a.keys.Authenticate(token)
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(header[7:])
+	return token, token != ""
+}
+
+func writeAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="ars"`)
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
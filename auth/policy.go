@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// ClaimRole grants roles to tokens whose claim contains value, e.g. every
+// member of the "platform" group becomes "config-admin".
+type ClaimRole struct {
+	Claim string   `json:"claim"`
+	Value string   `json:"value"`
+	Roles []string `json:"roles"`
+}
+
+// Policy maps token claims to roles and roles to grants. It is loaded from
+// a JSON file:
+//
+//	{
+//	  "rolesClaim": "realm_access.roles",
+//	  "claimRoles": [{"claim": "groups", "value": "platform", "roles": ["config-admin"]}],
+//	  "roles": {
+//	    "config-admin": [{"permissions": ["configs:*", "groups:*"]}],
+//	    "web-editor":   [{"permissions": ["groups:read", "groups:write"], "resources": ["groups/web_*"]}]
+//	  }
+//	}
+type Policy struct {
+	// RolesClaim is a dot-separated path to a claim holding role names
+	// directly. Defaults to "roles".
+	RolesClaim string             `json:"rolesClaim"`
+	ClaimRoles []ClaimRole        `json:"claimRoles"`
+	Roles      map[string][]Grant `json:"roles"`
+}
+
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+	if policy.RolesClaim == "" {
+		policy.RolesClaim = "roles"
+	}
+	for role, grants := range policy.Roles {
+		for _, grant := range grants {
+			for _, permission := range grant.Permissions {
+				if !validScope(permission) {
+					return nil, fmt.Errorf("role %q: %w %q", role, ErrInvalidScope, permission)
+				}
+			}
+			for _, pattern := range grant.Resources {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("role %q: resource pattern %q: %w", role, pattern, err)
+				}
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// roles returns the roles granted by the claims, without duplicates.
+func (p *Policy) roles(claims map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	roles := make([]string, 0)
+	add := func(role string) {
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	for _, role := range claimStrings(claims, p.RolesClaim) {
+		add(role)
+	}
+	for _, mapping := range p.ClaimRoles {
+		for _, value := range claimStrings(claims, mapping.Claim) {
+			if value == mapping.Value {
+				for _, role := range mapping.Roles {
+					add(role)
+				}
+				break
+			}
+		}
+	}
+	return roles
+}
+
+func (p *Policy) grants(roles []string) []Grant {
+	grants := make([]Grant, 0)
+	for _, role := range roles {
+		grants = append(grants, p.Roles[role]...)
+	}
+	return grants
+}
+
+// claimStrings resolves a dot-separated claim path and returns its value as
+// a list of strings. A single string is one value, except for the "scope"
+// claim, which is a space-separated list (RFC 8693).
+func claimStrings(claims map[string]interface{}, claimPath string) []string {
+	var value interface{} = claims
+	for _, part := range strings.Split(claimPath, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = obj[part]
+	}
+	switch v := value.(type) {
+	case string:
+		if claimPath == "scope" {
+			return strings.Fields(v)
+		}
+		return []string{v}
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
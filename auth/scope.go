@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"net/http"
+	"projekat/model"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Scopes are "<resource>:<action>". A granted scope of "*" allows
+// everything and "<resource>:*" every action on that resource.
+const (
+	ScopeConfigsRead   = "configs:read"
+	ScopeConfigsWrite  = "configs:write"
+	ScopeConfigsDelete = "configs:delete"
+	ScopeGroupsRead    = "groups:read"
+	ScopeGroupsWrite   = "groups:write"
+	ScopeGroupsDelete  = "groups:delete"
+	ScopeWebhooksRead  = "webhooks:read"
+	ScopeWebhooksWrite = "webhooks:write"
+	ScopeAuditRead     = "audit:read"
+	ScopeKeysAdmin     = "keys:admin"
+	ScopeMetricsRead   = "metrics:read"
+
+	ScopeNamespacesRead   = "namespaces:read"
+	ScopeNamespacesWrite  = "namespaces:write"
+	ScopeNamespacesDelete = "namespaces:delete"
+)
+
+var knownScopes = []string{
+	ScopeConfigsRead, ScopeConfigsWrite, ScopeConfigsDelete,
+	ScopeGroupsRead, ScopeGroupsWrite, ScopeGroupsDelete,
+	ScopeWebhooksRead, ScopeWebhooksWrite,
+	ScopeAuditRead, ScopeKeysAdmin, ScopeMetricsRead,
+	ScopeNamespacesRead, ScopeNamespacesWrite, ScopeNamespacesDelete,
+}
+
+// validScope reports whether s is a known scope or a wildcard over a known
+// resource.
+func validScope(s string) bool {
+	if s == "*" {
+		return true
+	}
+	for _, known := range knownScopes {
+		if s == known || s == strings.SplitN(known, ":", 2)[0]+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeMatches(granted, required string) bool {
+	if granted == "*" || granted == required {
+		return true
+	}
+	if strings.HasSuffix(granted, ":*") {
+		return strings.HasPrefix(required, strings.TrimSuffix(granted, "*"))
+	}
+	return false
+}
+
+// RequiredScopes returns the scopes a request needs. Reads need the read
+// scope, creating versions (including adding or removing configs in a group)
+// needs write, and deleting a version needs delete. Routes under
+// /namespaces/{namespace}/ need the same scopes as their un-namespaced
+// counterparts. Requests for unknown paths need "*".
+func RequiredScopes(r *http.Request) []string {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if segments[0] == "namespaces" {
+		if len(segments) <= 2 {
+			switch {
+			case read:
+				return []string{ScopeNamespacesRead}
+			case r.Method == http.MethodDelete:
+				return []string{ScopeNamespacesDelete}
+			}
+			return []string{ScopeNamespacesWrite}
+		}
+		segments = segments[2:]
+	}
+
+	switch segments[0] {
+	case "configs":
+		if read {
+			return []string{ScopeConfigsRead}
+		}
+		if r.Method == http.MethodDelete {
+			return []string{ScopeConfigsDelete}
+		}
+		return []string{ScopeConfigsWrite}
+	case "groups":
+		if read {
+			return []string{ScopeGroupsRead}
+		}
+		if r.Method == http.MethodDelete && len(segments) == 3 {
+			return []string{ScopeGroupsDelete}
+		}
+		return []string{ScopeGroupsWrite}
+	case "search", "watch", "usage":
+		return []string{ScopeConfigsRead, ScopeGroupsRead}
+	case "webhooks":
+		if read {
+			return []string{ScopeWebhooksRead}
+		}
+		return []string{ScopeWebhooksWrite}
+	case "audit":
+		return []string{ScopeAuditRead}
+	case "admin":
+		return []string{ScopeKeysAdmin}
+	case "metrics":
+		return []string{ScopeMetricsRead}
+	}
+	return []string{"*"}
+}
+
+// requestNamespace returns the namespace a request operates in: the
+// {namespace} route variable, "default" for the legacy config, group, search
+// and usage routes, the ?namespace= filter of /watch, and "" for requests that
+// are not scoped to one namespace.
+func requestNamespace(r *http.Request) string {
+	if namespace := mux.Vars(r)["namespace"]; namespace != "" {
+		return namespace
+	}
+	switch strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 2)[0] {
+	case "configs", "groups", "search", "usage":
+		return model.DefaultNamespace
+	case "watch":
+		return r.URL.Query().Get("namespace")
+	}
+	return ""
+}
+
+// requestResource returns "configs/{name}" or "groups/{name}" for routes
+// that address a named config or group, and "" otherwise.
+func requestResource(r *http.Request) string {
+	name := mux.Vars(r)["name"]
+	if name == "" {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if segments[0] == "namespaces" && len(segments) > 2 {
+		segments = segments[2:]
+	}
+	if segments[0] != "configs" && segments[0] != "groups" {
+		return ""
+	}
+	return segments[0] + "/" + name
+}
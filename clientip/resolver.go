@@ -0,0 +1,142 @@
+// Package clientip determines the address of the client behind a chain of
+// reverse proxies.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Resolver trusts forwarding headers only when they were set by a proxy in
+// its trusted networks.
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// NewResolver parses the trusted proxies, given as CIDRs or single
+// addresses. Without trusted proxies forwarding headers are ignored.
+func NewResolver(proxies []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		r.trusted = append(r.trusted, network)
+	}
+	return r, nil
+}
+
+func (r *Resolver) isTrusted(ip net.IP) bool {
+	for _, network := range r.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client that sent req. The peer is the
+// client unless it is a trusted proxy; then the hops recorded in Forwarded,
+// or else X-Forwarded-For, are walked from the right and the first one that
+// is not a trusted proxy is the client. X-Real-IP is used when a trusted
+// peer sent neither. A hop that is not an IP address ends the walk at the
+// proxy that recorded it, since nothing to its left can be verified.
+func (r *Resolver) ClientIP(req *http.Request) string {
+	peer := hostIP(req.RemoteAddr)
+	peerIP := net.ParseIP(peer)
+	if peerIP == nil || !r.isTrusted(peerIP) {
+		return peer
+	}
+
+	hops := forwardedFor(req.Header.Values("Forwarded"))
+	if len(hops) == 0 {
+		hops = splitList(req.Header.Values("X-Forwarded-For"))
+	}
+	if len(hops) == 0 {
+		if ip := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-IP"))); ip != nil {
+			return ip.String()
+		}
+		return peer
+	}
+
+	client := peerIP
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(stripPort(hops[i]))
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !r.isTrusted(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// hostIP strips the port from a RemoteAddr.
+func hostIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// stripPort removes the brackets and port of "[2001:db8::1]:4711" or
+// "192.0.2.1:4711" and leaves bare addresses alone.
+func stripPort(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+}
+
+// splitList splits comma-separated header values into trimmed elements.
+func splitList(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			if element = strings.TrimSpace(element); element != "" {
+				result = append(result, element)
+			}
+		}
+	}
+	return result
+}
+
+// forwardedFor returns the for= parameter of every element of RFC 7239
+// Forwarded headers, in order. Elements without one are kept as "" so they
+// count as hops that cannot be verified.
+func forwardedFor(values []string) []string {
+	var result []string
+	for _, element := range splitList(values) {
+		node := ""
+		for _, pair := range strings.Split(element, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(name, "for") {
+				node = strings.Trim(value, `"`)
+			}
+		}
+		result = append(result, node)
+	}
+	return result
+}
@@ -0,0 +1,199 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := NewResolver([]string{"10.0.0.0/8", "2001:db8:ffff::/48", "192.0.2.10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		peer    string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name: "no headers",
+			peer: "203.0.113.7:5000",
+			want: "203.0.113.7",
+		},
+		{
+			name:    "untrusted peer cannot set X-Forwarded-For",
+			peer:    "203.0.113.7:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "untrusted peer cannot set Forwarded",
+			peer:    "203.0.113.7:5000",
+			headers: map[string][]string{"Forwarded": {"for=198.51.100.1"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "untrusted peer cannot set X-Real-IP",
+			peer:    "203.0.113.7:5000",
+			headers: map[string][]string{"X-Real-IP": {"198.51.100.1"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "trusted proxy",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "spoofed left-most X-Forwarded-For is ignored",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4, 198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "spoofed hop in an earlier X-Forwarded-For header",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4", "198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "chain of trusted proxies",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4, 198.51.100.1, 10.1.1.1, 192.0.2.10"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "every hop trusted",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"10.2.2.2, 10.1.1.1"}},
+			want:    "10.2.2.2",
+		},
+		{
+			name: "Forwarded takes precedence over X-Forwarded-For",
+			peer: "10.0.0.1:5000",
+			headers: map[string][]string{
+				"Forwarded":       {"for=198.51.100.2;proto=https"},
+				"X-Forwarded-For": {"198.51.100.1"},
+			},
+			want: "198.51.100.2",
+		},
+		{
+			name: "X-Forwarded-For takes precedence over X-Real-IP",
+			peer: "10.0.0.1:5000",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"198.51.100.1"},
+				"X-Real-IP":       {"198.51.100.3"},
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:    "X-Real-IP from a trusted proxy",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Real-IP": {"198.51.100.3"}},
+			want:    "198.51.100.3",
+		},
+		{
+			name:    "invalid X-Real-IP",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Real-IP": {"not-an-ip"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "Forwarded IPv6 with brackets and port",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {`for="[2001:db8::1]:4711"`}},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "Forwarded IPv6 with brackets",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {`for="[2001:db8::1]"`}},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "X-Forwarded-For IPv4 with port",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1:4711"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "trusted IPv6 peer",
+			peer:    "[2001:db8:ffff::1]:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"2001:db8::2"}},
+			want:    "2001:db8::2",
+		},
+		{
+			name:    "untrusted IPv6 peer",
+			peer:    "[2001:db8::9]:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:    "2001:db8::9",
+		},
+		{
+			name:    "Forwarded with several elements",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {"for=1.2.3.4, for=198.51.100.1;by=10.0.0.1, for=10.3.3.3"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "garbage hop stops the walk at the proxy that recorded it",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1, garbage, 10.1.1.1"}},
+			want:    "10.1.1.1",
+		},
+		{
+			name:    "garbage right-most hop",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1, garbage"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "obfuscated Forwarded node",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {"for=198.51.100.1, for=_hidden"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "Forwarded element without for",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {"for=198.51.100.1, proto=https"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "empty elements are skipped",
+			peer:    "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {" , 198.51.100.1 ,"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name: "peer without a port",
+			peer: "203.0.113.7",
+			want: "203.0.113.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.peer
+			for name, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+			if got := resolver.ClientIP(req); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewResolverRejectsInvalidProxies(t *testing.T) {
+	for _, proxy := range []string{"not-an-ip", "10.0.0.0/33", "2001:db8::/129"} {
+		if _, err := NewResolver([]string{proxy}); err == nil {
+			t.Errorf("NewResolver(%q) succeeded", proxy)
+		}
+	}
+}
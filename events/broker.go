@@ -0,0 +1,167 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	Created    Type = "created"
+	NewVersion Type = "new-version"
+	Deleted    Type = "deleted"
+)
+
+const (
+	ResourceConfigs = "configs"
+	ResourceGroups  = "groups"
+)
+
+// Event describes a change to a config or config group version. IDs increase
+// monotonically across all resources. Object holds the model.Config or
+// model.ConfigGroup that was created or deleted.
+type Event struct {
+	ID        uint64      `json:"id"`
+	Type      Type        `json:"type"`
+	Resource  string      `json:"resource"`
+	Namespace string      `json:"namespace"`
+	Name      string      `json:"name"`
+	Version   int         `json:"version"`
+	Time      time.Time   `json:"time"`
+	Object    interface{} `json:"object,omitempty"`
+}
+
+// subscriberBuffer is how many events a subscriber may lag behind before it
+// is disconnected. A disconnected client can resume from the ring buffer.
+const subscriberBuffer = 64
+
+// Subscription delivers published events on C. C is closed when the
+// subscriber falls too far behind or the broker is closed.
+type Subscription struct {
+	C  <-chan Event
+	ch chan Event
+}
+
+// Broker fans out events to subscribers and keeps the most recent ones in a
+// ring buffer so clients can resume after reconnecting. A nil *Broker
+// discards everything published to it.
+type Broker struct {
+	mu          sync.Mutex
+	lastID      uint64
+	ring        []Event
+	next        int
+	size        int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewBroker(capacity int) *Broker {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Broker{
+		ring:        make([]Event, capacity),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the next ID to e, stores it and delivers it to every
+// subscriber.
+func (b *Broker) Publish(e Event) Event {
+	if b == nil {
+		return e
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.ring[b.next] = e
+	b.next = (b.next + 1) % len(b.ring)
+	if b.size < len(b.ring) {
+		b.size++
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- e:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+	return e
+}
+
+// LastID returns the ID of the most recently published event.
+func (b *Broker) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+// Subscribe registers a subscriber and returns the buffered events newer
+// than lastID. complete is false when some of those events were already
+// evicted from the ring buffer, and when lastID is newer than any event
+// published: IDs restart with the server, so the client may have missed
+// anything and gets every buffered event.
+func (b *Broker) Subscribe(lastID uint64) (sub *Subscription, backlog []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, subscriberBuffer)
+	sub = &Subscription{C: ch, ch: ch}
+	if b.closed {
+		close(ch)
+		return sub, nil, true
+	}
+	b.subscribers[sub] = struct{}{}
+
+	backlog = make([]Event, 0)
+	if lastID > b.lastID {
+		lastID = 0
+		complete = false
+	} else {
+		oldest := b.lastID - uint64(b.size) + 1
+		complete = lastID+1 >= oldest
+	}
+	for i := 0; i < b.size; i++ {
+		e := b.ring[(b.next-b.size+i+len(b.ring))%len(b.ring)]
+		if e.ID > lastID {
+			backlog = append(backlog, e)
+		}
+	}
+	return sub, backlog, complete
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Closed reports whether Close has been called.
+func (b *Broker) Closed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// Close disconnects every subscriber so long-lived streams end during
+// shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
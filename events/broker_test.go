@@ -0,0 +1,63 @@
+package events
+
+import "testing"
+
+func publishN(b *Broker, n int) {
+	for i := 0; i < n; i++ {
+		b.Publish(Event{Type: Created, Resource: ResourceConfigs, Name: "db"})
+	}
+}
+
+func TestSubscribeBacklog(t *testing.T) {
+	tests := []struct {
+		name         string
+		capacity     int
+		published    int
+		lastID       uint64
+		wantFirst    uint64
+		wantBacklog  int
+		wantComplete bool
+	}{
+		{"nothing missed", 10, 5, 5, 0, 0, true},
+		{"resume within the buffer", 10, 5, 2, 3, 3, true},
+		{"resume from the start", 10, 5, 0, 1, 5, true},
+		{"oldest buffered event is next", 4, 10, 6, 7, 4, true},
+		{"events evicted", 4, 10, 3, 7, 4, false},
+		{"ID from before a restart", 10, 3, 50, 1, 3, false},
+		{"ID from before a restart, nothing published", 10, 0, 50, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBroker(tt.capacity)
+			publishN(b, tt.published)
+			sub, backlog, complete := b.Subscribe(tt.lastID)
+			defer b.Unsubscribe(sub)
+
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+			if len(backlog) != tt.wantBacklog {
+				t.Fatalf("backlog has %d events, want %d", len(backlog), tt.wantBacklog)
+			}
+			for i, e := range backlog {
+				if e.ID != tt.wantFirst+uint64(i) {
+					t.Errorf("backlog[%d].ID = %d, want %d", i, e.ID, tt.wantFirst+uint64(i))
+				}
+			}
+		})
+	}
+}
+
+func TestSlowSubscriberIsDisconnected(t *testing.T) {
+	b := NewBroker(10)
+	sub, _, _ := b.Subscribe(0)
+	publishN(b, subscriberBuffer+1)
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("received %d events before the channel closed, want %d", n, subscriberBuffer)
+	}
+}
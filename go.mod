@@ -2,4 +2,4 @@ module projekat
 
 go 1.19
 
-require github.com/gorilla/mux v1.8.1 // indirect
+require github.com/gorilla/mux v1.8.1
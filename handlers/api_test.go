@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"projekat/audit"
+	"projekat/model"
+	"projekat/repositories"
+	"projekat/services"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// testAPI serves the config, group, namespace and audit routes as main.go
+// registers them, over in-memory repositories.
+type testAPI struct {
+	t      *testing.T
+	router *mux.Router
+	audit  *audit.Log
+	index  *repositories.ChangeIndex
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	index := repositories.NewChangeIndex()
+	configRepo := repositories.NewConfigInMemRepository(index)
+	groupRepo := repositories.NewConfigGroupInMemRepository(index)
+	namespaces := services.NewNamespaceService(repositories.NewNamespaceInMemRepository(), configRepo, groupRepo)
+	if err := namespaces.EnsureDefault(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	quotas := services.NewQuotaService(model.QuotaPolicy{}, namespaces, configRepo, groupRepo)
+	auditLog := audit.NewLog(0)
+	configHandler := NewConfigHandler(services.NewConfigService(configRepo, namespaces, quotas, nil), auditLog)
+	groupHandler := NewConfigGroupHandler(services.NewConfigGroupService(groupRepo, namespaces, quotas, nil), auditLog)
+	namespaceHandler := NewNamespaceHandler(namespaces)
+	blocking := NewBlockingQuery(index)
+
+	register := func(r *mux.Router) {
+		r.HandleFunc("/configs", blocking.Wrap(configHandler.GetAll)).Methods("GET")
+		r.HandleFunc("/configs", configHandler.Create).Methods("POST")
+		r.HandleFunc("/configs/{name}/latest", blocking.Wrap(configHandler.GetLatest)).Methods("GET")
+		r.HandleFunc("/configs/{name}/diff", blocking.Wrap(configHandler.Diff)).Methods("GET")
+		r.HandleFunc("/configs/{name}", blocking.Wrap(configHandler.GetVersions)).Methods("GET")
+		r.HandleFunc("/configs/{name}/rollback", configHandler.Rollback).Methods("POST")
+		r.HandleFunc("/configs/{name}/{version}", blocking.Wrap(configHandler.Get)).Methods("GET")
+		r.HandleFunc("/configs/{name}/{version}", configHandler.Delete).Methods("DELETE")
+
+		r.HandleFunc("/groups", blocking.Wrap(groupHandler.GetAll)).Methods("GET")
+		r.HandleFunc("/groups", groupHandler.Create).Methods("POST")
+		r.HandleFunc("/groups/{name}/latest", blocking.Wrap(groupHandler.GetLatest)).Methods("GET")
+		r.HandleFunc("/groups/{name}/diff", blocking.Wrap(groupHandler.Diff)).Methods("GET")
+		r.HandleFunc("/groups/{name}", blocking.Wrap(groupHandler.GetVersions)).Methods("GET")
+		r.HandleFunc("/groups/{name}/rollback", groupHandler.Rollback).Methods("POST")
+		r.HandleFunc("/groups/{name}/{version}", blocking.Wrap(groupHandler.Get)).Methods("GET")
+		r.HandleFunc("/groups/{name}/{version}", groupHandler.Delete).Methods("DELETE")
+		r.HandleFunc("/groups/{name}/{version}/configs", groupHandler.AddConfig).Methods("POST")
+		r.HandleFunc("/groups/{name}/{version}/configs/{configName}", blocking.Wrap(groupHandler.GetConfig)).Methods("GET")
+		r.HandleFunc("/groups/{name}/{version}/configs/{configName}", groupHandler.RemoveConfig).Methods("DELETE")
+		r.HandleFunc("/groups/{name}/{version}/configs", blocking.Wrap(groupHandler.GetConfigsByLabels)).Methods("GET").Queries("labels", "{labels}")
+		r.HandleFunc("/groups/{name}/{version}/configs", groupHandler.DeleteConfigsByLabels).Methods("DELETE").Queries("labels", "{labels}")
+	}
+	router := mux.NewRouter()
+	register(router)
+	router.HandleFunc("/namespaces", namespaceHandler.Create).Methods("POST")
+	router.HandleFunc("/namespaces/{namespace}", namespaceHandler.Get).Methods("GET")
+	router.HandleFunc("/namespaces/{namespace}", namespaceHandler.Delete).Methods("DELETE")
+	register(router.PathPrefix("/namespaces/{namespace}").Subrouter())
+	router.HandleFunc("/audit", NewAuditHandler(auditLog).GetAll).Methods("GET")
+
+	return &testAPI{t: t, router: router, audit: auditLog, index: index}
+}
+
+// do serves a request; headers are given as name, value pairs.
+func (a *testAPI) do(method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// must is do for requests that set up a test and must answer want.
+func (a *testAPI) must(want int, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	rec := a.do(method, target, body, headers...)
+	if rec.Code != want {
+		a.t.Fatalf("%s %s: status %d, want %d: %s", method, target, rec.Code, want, rec.Body)
+	}
+	return rec
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body, err)
+	}
+}
+
+const (
+	testConfigV1 = `{"name":"db","version":1,"parameters":[{"key":"host","value":"a"}],"labels":[{"key":"env","value":"dev"}]}`
+	testConfigV2 = `{"name":"db","version":2,"parameters":[{"key":"host","value":"b"},{"key":"port","value":"5432"}],"labels":[{"key":"env","value":"prod"}]}`
+	testGroupV1  = `{"name":"web","version":1,"configs":[{"name":"server","parameters":[{"key":"port","value":"8080"}],"labels":[{"key":"env","value":"dev"}]}]}`
+)
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"projekat/auth"
+
+	"github.com/gorilla/mux"
+)
+
+type KeyHandler struct {
+	keys *auth.KeyStore
+}
+
+func NewKeyHandler(keys *auth.KeyStore) KeyHandler {
+	return KeyHandler{
+		keys: keys,
+	}
+}
+
+type issueKeyRequest struct {
+	Name     string   `json:"name"`
+	Scopes   []string `json:"scopes"`
+	Prefixes []string `json:"prefixes"`
+}
+
+type issuedKey struct {
+	auth.Key
+	Token string `json:"token"`
+}
+
+// POST /admin/keys
+//
+// The token is only returned in this response.
+func (h KeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req issueKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, token, err := h.keys.Issue(req.Name, req.Scopes, req.Prefixes)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidScope) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, issuedKey{Key: key, Token: token})
+}
+
+// GET /admin/keys
+func (h KeyHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, h.keys.List())
+}
+
+// DELETE /admin/keys/{id}
+func (h KeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.keys.Revoke(mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"projekat/audit"
+	"projekat/logging"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AuditHandler struct {
+	log *audit.Log
+}
+
+func NewAuditHandler(log *audit.Log) AuditHandler {
+	return AuditHandler{
+		log: log,
+	}
+}
+
+// GET /audit?resource=groups/web_configs&since=2024-01-01T00:00:00Z&identity=&limit=
+//
+// since accepts an RFC 3339 timestamp or a duration such as "1h" meaning
+// that long ago.
+func (h AuditHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := audit.Query{
+		Resource: strings.Trim(query.Get("resource"), "/"),
+		Identity: query.Get("identity"),
+		Visible: func(e audit.Entry) bool {
+			return auditEntryVisible(r.Context(), e)
+		},
+	}
+	if v := query.Get("since"); v != "" {
+		since, err := parseSince(v)
+		if err != nil {
+			http.Error(w, "invalid 'since': use an RFC 3339 timestamp or a duration", http.StatusBadRequest)
+			return
+		}
+		q.Since = since
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			http.Error(w, "invalid 'limit'", http.StatusBadRequest)
+			return
+		}
+		q.Limit = limit
+	}
+
+	writeJSON(w, r, http.StatusOK, h.log.Query(q))
+}
+
+func parseSince(v string) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// recordAudit stores an audit entry for a successful mutation. before and
+// after are the resource states around the change; nil means absent.
+func recordAudit(auditLog *audit.Log, r *http.Request, action, resource string, version, newVersion int, before, after interface{}) {
+	entry := audit.Entry{
+		Action:     action,
+		Resource:   resource,
+		Version:    version,
+		NewVersion: newVersion,
+		Identity:   identityFromRequest(r),
+		RequestID:  requestIDFromRequest(r),
+		BeforeHash: auditHash(before),
+		AfterHash:  auditHash(after),
+	}
+	if err := auditLog.Record(entry); err != nil {
+		logging.FromContext(r.Context()).Error("could not record audit entry", "error", err)
+	}
+}
+
+func auditHash(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	tag, _, err := computeETag(v)
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(tag, `"`)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"projekat/audit"
+	"projekat/auth"
+	"strings"
+	"testing"
+)
+
+func TestAuditIsLimitedToReadableResources(t *testing.T) {
+	log := audit.NewLog(0)
+	for _, resource := range []string{"configs/db", "configs/secret", "namespaces/team-a/groups/web", "namespaces/team-b/configs/db"} {
+		if err := log.Record(audit.Entry{Action: audit.ActionCreate, Resource: resource, Version: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := NewAuditHandler(log)
+	auditor := auth.Principal{ID: "apikey:auditor", Grants: []auth.Grant{
+		{Permissions: []string{auth.ScopeAuditRead}},
+		{Permissions: []string{auth.ScopeConfigsRead}, Resources: []string{"configs/db"}, Namespaces: []string{"default"}},
+		{Permissions: []string{auth.ScopeGroupsRead}, Namespaces: []string{"team-a"}},
+	}}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		target string
+		want   []string
+	}{
+		{"without authentication", context.Background(), "/audit",
+			[]string{"configs/db", "configs/secret", "namespaces/team-a/groups/web", "namespaces/team-b/configs/db"}},
+		{"readable resources only", auth.WithPrincipal(context.Background(), auditor), "/audit",
+			[]string{"configs/db", "namespaces/team-a/groups/web"}},
+		{"limit counts visible entries", auth.WithPrincipal(context.Background(), auditor), "/audit?limit=1",
+			[]string{"namespaces/team-a/groups/web"}},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.GetAll(rec, httptest.NewRequest(http.MethodGet, tt.target, nil).WithContext(tt.ctx))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.name, rec.Code, rec.Body)
+		}
+		var entries []audit.Entry
+		if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0, len(entries))
+		for _, e := range entries {
+			got = append(got, e.Resource)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAuditRecordsMutations(t *testing.T) {
+	api := newTestAPI(t)
+	created := api.must(http.StatusCreated, "POST", "/configs", testConfigV1, "X-Request-ID", "req-1")
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV2)
+	rolledBack := api.must(http.StatusCreated, "POST", "/configs/db/rollback?to=1", "")
+	api.must(http.StatusNoContent, "DELETE", "/configs/db/1", "")
+	api.must(http.StatusCreated, "POST", "/groups", testGroupV1)
+	api.must(http.StatusCreated, "POST", "/groups/web/1/configs", `{"name":"cache"}`)
+	api.must(http.StatusCreated, "DELETE", "/groups/web/2/configs/cache", "")
+	api.must(http.StatusCreated, "POST", "/namespaces", `{"name":"team-a"}`)
+	api.must(http.StatusCreated, "POST", "/namespaces/team-a/configs", testConfigV1)
+	// Reads and failed writes are not recorded.
+	api.must(http.StatusOK, "GET", "/configs/db/2", "")
+	api.must(http.StatusConflict, "POST", "/configs", testConfigV2)
+
+	var entries []audit.Entry
+	decodeBody(t, api.must(http.StatusOK, "GET", "/audit", ""), &entries)
+	want := []struct {
+		action, resource    string
+		version, newVersion int
+	}{
+		{audit.ActionCreate, "configs/db", 1, 0},
+		{audit.ActionCreate, "configs/db", 2, 0},
+		{audit.ActionRollback, "configs/db", 1, 3},
+		{audit.ActionDelete, "configs/db", 1, 0},
+		{audit.ActionCreate, "groups/web", 1, 0},
+		{audit.ActionAddConfig, "groups/web", 1, 2},
+		{audit.ActionRemoveConfig, "groups/web", 2, 3},
+		{audit.ActionCreate, "namespaces/team-a/configs/db", 1, 0},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("recorded %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Seq != uint64(i+1) || e.Action != w.action || e.Resource != w.resource || e.Version != w.version || e.NewVersion != w.newVersion {
+			t.Errorf("entry %d = %+v, want %s %s %d->%d", i, e, w.action, w.resource, w.version, w.newVersion)
+		}
+		if e.Time.IsZero() || e.Identity == "" {
+			t.Errorf("entry %d has no time or identity: %+v", i, e)
+		}
+	}
+
+	// The hashes are the ETags of the resource around the change.
+	if got := `"` + entries[0].AfterHash + `"`; got != created.Header().Get("ETag") || entries[0].BeforeHash != "" {
+		t.Errorf("create hashes %q -> %q, want none -> %s", entries[0].BeforeHash, entries[0].AfterHash, created.Header().Get("ETag"))
+	}
+	if entries[0].RequestID != "req-1" {
+		t.Errorf("request ID = %q, want req-1", entries[0].RequestID)
+	}
+	if entries[2].BeforeHash != entries[1].AfterHash || `"`+entries[2].AfterHash+`"` != rolledBack.Header().Get("ETag") {
+		t.Errorf("rollback hashes %q -> %q, want the latest version before and the new one after", entries[2].BeforeHash, entries[2].AfterHash)
+	}
+	if entries[3].BeforeHash != entries[0].AfterHash || entries[3].AfterHash != "" {
+		t.Errorf("delete hashes %q -> %q, want the deleted version before and none after", entries[3].BeforeHash, entries[3].AfterHash)
+	}
+
+	for target, want := range map[string]int{
+		"/audit?resource=groups":                                  3,
+		"/audit?resource=/configs/db/":                            4,
+		"/audit?resource=namespaces/team-a":                       1,
+		"/audit?limit=2":                                          2,
+		"/audit?since=1h":                                         8,
+		"/audit?since=2999-01-01T00:00:00Z":                       0,
+		"/audit?identity=nobody":                                  0,
+		"/audit?resource=configs&identity=" + entries[0].Identity: 4,
+	} {
+		decodeBody(t, api.must(http.StatusOK, "GET", target, ""), &entries)
+		if len(entries) != want {
+			t.Errorf("GET %s returned %d entries, want %d", target, len(entries), want)
+		}
+	}
+	for _, target := range []string{"/audit?since=yesterday", "/audit?limit=-1"} {
+		api.must(http.StatusBadRequest, "GET", target, "")
+	}
+}
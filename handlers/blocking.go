@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"projekat/model"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultBlockingWait = 5 * time.Minute
+	maxBlockingWait     = 10 * time.Minute
+)
+
+// BlockingQuery adds Consul-style blocking reads to GET handlers. Every
+// response carries the current modification index in X-Config-Index; a
+// request with ?index=N blocks until the index moves past N or the
+// ?wait=<duration> (default 5m, max 10m) expires, and then answers normally.
+type BlockingQuery struct {
+	index model.IndexWaiter
+}
+
+func NewBlockingQuery(index model.IndexWaiter) BlockingQuery {
+	return BlockingQuery{
+		index: index,
+	}
+}
+
+func (b BlockingQuery) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if v := query.Get("index"); v != "" {
+			after, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid index", http.StatusBadRequest)
+				return
+			}
+			wait := defaultBlockingWait
+			if v := query.Get("wait"); v != "" {
+				wait, err = time.ParseDuration(v)
+				if err != nil || wait < 0 {
+					http.Error(w, "invalid wait duration", http.StatusBadRequest)
+					return
+				}
+			}
+			if wait > maxBlockingWait {
+				wait = maxBlockingWait
+			}
+
+			// An index ahead of ours means the server restarted; answer
+			// right away so the client picks up the new index.
+			if after <= b.index.Index() {
+				ctx, cancel := context.WithTimeout(r.Context(), wait)
+				b.index.WaitForIndex(ctx, after)
+				cancel()
+				if r.Context().Err() != nil {
+					http.Error(w, "request cancelled", http.StatusServiceUnavailable)
+					return
+				}
+			}
+		}
+
+		w.Header().Set("X-Config-Index", strconv.FormatUint(b.index.Index(), 10))
+		next(w, r)
+	}
+}
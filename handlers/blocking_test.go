@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"projekat/model"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func configIndex(t *testing.T, rec *httptest.ResponseRecorder) uint64 {
+	t.Helper()
+	index, err := strconv.ParseUint(rec.Header().Get("X-Config-Index"), 10, 64)
+	if err != nil {
+		t.Fatalf("X-Config-Index %q: %v", rec.Header().Get("X-Config-Index"), err)
+	}
+	return index
+}
+
+func TestBlockingQueryWaitsForWrite(t *testing.T) {
+	api := newTestAPI(t)
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV1)
+	index := configIndex(t, api.must(http.StatusOK, "GET", "/configs", ""))
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- api.do("GET", fmt.Sprintf("/configs/db?index=%d&wait=10s", index), "")
+	}()
+	select {
+	case rec := <-done:
+		t.Fatalf("blocking read answered %d before any write", rec.Code)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV2)
+	select {
+	case rec := <-done:
+		if rec.Code != http.StatusOK {
+			t.Fatalf("blocking read: status %d", rec.Code)
+		}
+		if got := configIndex(t, rec); got <= index {
+			t.Errorf("X-Config-Index = %d after a write, want more than %d", got, index)
+		}
+		var versions []model.Config
+		decodeBody(t, rec, &versions)
+		if len(versions) != 2 {
+			t.Errorf("blocking read returned %d versions, want the new one included", len(versions))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocking read did not return after a write")
+	}
+}
+
+func TestBlockingQueryTimesOut(t *testing.T) {
+	api := newTestAPI(t)
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV1)
+	index := configIndex(t, api.must(http.StatusOK, "GET", "/configs/db/latest", ""))
+
+	start := time.Now()
+	rec := api.must(http.StatusOK, "GET", fmt.Sprintf("/configs/db/latest?index=%d&wait=30ms", index), "")
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("read returned after %v, before the wait expired", elapsed)
+	}
+	if got := configIndex(t, rec); got != index {
+		t.Errorf("X-Config-Index = %d after a timeout, want %d unchanged", got, index)
+	}
+}
+
+func TestBlockingQueryIndexAhead(t *testing.T) {
+	api := newTestAPI(t)
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV1)
+	index := configIndex(t, api.must(http.StatusOK, "GET", "/configs", ""))
+
+	// A client that watched a server before it restarted holds an index
+	// ahead of ours and must pick up the new one right away.
+	start := time.Now()
+	rec := api.must(http.StatusOK, "GET", fmt.Sprintf("/configs?index=%d&wait=10s", index+100), "")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("read with an index ahead of the server blocked for %v", elapsed)
+	}
+	if got := configIndex(t, rec); got != index {
+		t.Errorf("X-Config-Index = %d, want %d", got, index)
+	}
+}
+
+func TestBlockingQueryInvalidParameters(t *testing.T) {
+	api := newTestAPI(t)
+	for _, target := range []string{
+		"/configs?index=x",
+		"/configs?index=-1",
+		"/configs?index=0&wait=soon",
+		"/configs?index=0&wait=-1s",
+	} {
+		if rec := api.do("GET", target, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
@@ -3,24 +3,33 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"projekat/audit"
+	"projekat/auth"
 	"projekat/model"
 	"projekat/services"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 type ConfigHandler struct {
 	service services.ConfigService
+	audit   *audit.Log
 }
 
-func NewConfigHandler(service services.ConfigService) ConfigHandler {
+func NewConfigHandler(service services.ConfigService, auditLog *audit.Log) ConfigHandler {
 	return ConfigHandler{
 		service: service,
+		audit:   auditLog,
 	}
 }
 
 func (c ConfigHandler) Get(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigHandler.Get")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
 	name := mux.Vars(r)["name"]
 	version := mux.Vars(r)["version"]
 	versionInt, err := strconv.Atoi(version)
@@ -29,56 +38,97 @@ func (c ConfigHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	config, err := c.service.Get(name, versionInt)
+	config, err := c.service.Get(r.Context(), namespace, name, versionInt)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, err, http.StatusNotFound)
 		return
 	}
-	resp, err := json.Marshal(config)
+
+	writeJSON(w, r, http.StatusOK, config)
+}
+
+// GET /configs/{name}/latest
+func (c ConfigHandler) GetLatest(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigHandler.GetLatest")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
+	name := mux.Vars(r)["name"]
+
+	config, err := c.service.GetLatest(r.Context(), namespace, name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusNotFound)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(resp)
+	writeJSON(w, r, http.StatusOK, config)
 }
 
-func (c ConfigHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	configs, err := c.service.GetAll()
+// GET /configs/{name}
+func (c ConfigHandler) GetVersions(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigHandler.GetVersions")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
+	name := mux.Vars(r)["name"]
+
+	configs, err := c.service.GetVersions(r.Context(), namespace, name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusNotFound)
 		return
 	}
 
-	resp, err := json.Marshal(configs)
+	writeJSON(w, r, http.StatusOK, configs)
+}
+
+func (c ConfigHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigHandler.GetAll")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
+	configs, err := c.service.GetAll(r.Context(), namespace)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(resp)
+	writeJSON(w, r, http.StatusOK, visibleConfigs(r.Context(), namespace, configs))
 }
 
 func (c ConfigHandler) Create(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigHandler.Create")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
 	var config model.Config
 
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	config.Namespace = namespace
+	// Metadata and the creation time are the server's to set.
+	config.Metadata = nil
+	config.CreatedAt = time.Time{}
+	if !authorize(w, r, auth.ScopeConfigsWrite, namespace, "configs/"+config.Name) {
+		return
+	}
 
-	if err := c.service.Add(config); err != nil {
-		http.Error(w, err.Error(), http.StatusConflict)
+	config, err := c.service.Add(r.Context(), config)
+	if err != nil {
+		writeError(w, r, err, http.StatusConflict)
 		return
 	}
+	recordAudit(c.audit, r, audit.ActionCreate, model.ResourceKey(namespace, "configs", config.Name), config.Version, 0, nil, config)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(config)
+	writeJSON(w, r, http.StatusCreated, config)
 }
 
 func (c ConfigHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigHandler.Delete")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
 	name := mux.Vars(r)["name"]
 	version := mux.Vars(r)["version"]
 	versionInt, err := strconv.Atoi(version)
@@ -86,11 +136,86 @@ func (c ConfigHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !authorize(w, r, auth.ScopeConfigsDelete, namespace, "configs/"+name) {
+		return
+	}
+
+	config, err := c.service.Get(r.Context(), namespace, name, versionInt)
+	if err != nil {
+		writeError(w, r, err, http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, config) {
+		return
+	}
 
-	if err := c.service.Delete(name, versionInt); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	if err := c.service.Delete(r.Context(), namespace, name, versionInt); err != nil {
+		writeError(w, r, err, http.StatusNotFound)
 		return
 	}
+	recordAudit(c.audit, r, audit.ActionDelete, model.ResourceKey(namespace, "configs", name), versionInt, 0, config, nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// POST /configs/{name}/rollback?to=3&reason=...
+func (c ConfigHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigHandler.Rollback")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
+	name := mux.Vars(r)["name"]
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "invalid or missing 'to' version", http.StatusBadRequest)
+		return
+	}
+	if !authorize(w, r, auth.ScopeConfigsWrite, namespace, "configs/"+name) {
+		return
+	}
+
+	latest, err := c.service.GetLatest(r.Context(), namespace, name)
+	if err != nil {
+		writeError(w, r, err, http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, latest) {
+		return
+	}
+
+	reason := r.URL.Query().Get("reason")
+	config, err := c.service.Rollback(r.Context(), namespace, name, to, identityFromRequest(r), reason)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	recordAudit(c.audit, r, audit.ActionRollback, model.ResourceKey(namespace, "configs", name), to, config.Version, latest, config)
+
+	writeJSON(w, r, http.StatusCreated, config)
+}
+
+// GET /configs/{name}/diff?from=1&to=2
+func (c ConfigHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigHandler.Diff")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
+	name := mux.Vars(r)["name"]
+	from, to, err := parseVersionRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	diff, err := c.service.Diff(r.Context(), namespace, name, from, to)
+	if err != nil {
+		writeError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	if prefersText(r) {
+		writeText(w, r, diff.Text())
+		return
+	}
+	writeJSON(w, r, http.StatusOK, diff)
+}
@@ -3,24 +3,44 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"projekat/audit"
+	"projekat/auth"
 	"projekat/model"
 	"projekat/services"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 type ConfigGroupHandler struct {
 	service services.ConfigGroupService
+	audit   *audit.Log
 }
 
-func NewConfigGroupHandler(service services.ConfigGroupService) ConfigGroupHandler {
+func NewConfigGroupHandler(service services.ConfigGroupService, auditLog *audit.Log) ConfigGroupHandler {
 	return ConfigGroupHandler{
 		service: service,
+		audit:   auditLog,
 	}
 }
 
+// loadBase returns the group version a mutation is based on after
+// evaluating If-Match against it.
+func (h ConfigGroupHandler) loadBase(w http.ResponseWriter, r *http.Request, namespace, name string, version int) (model.ConfigGroup, bool) {
+	group, err := h.service.Get(r.Context(), namespace, name, version)
+	if err != nil {
+		writeError(w, r, err, http.StatusNotFound)
+		return model.ConfigGroup{}, false
+	}
+	return group, checkIfMatch(w, r, group)
+}
+
 func (h ConfigGroupHandler) Get(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.Get")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
 	name := mux.Vars(r)["name"]
 	version := mux.Vars(r)["version"]
 	versionInt, err := strconv.Atoi(version)
@@ -29,57 +49,97 @@ func (h ConfigGroupHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	group, err := h.service.Get(name, versionInt)
+	group, err := h.service.Get(r.Context(), namespace, name, versionInt)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, err, http.StatusNotFound)
 		return
 	}
 
-	resp, err := json.Marshal(group)
+	writeJSON(w, r, http.StatusOK, group)
+}
+
+// GET /groups/{name}/latest
+func (h ConfigGroupHandler) GetLatest(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.GetLatest")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
+	name := mux.Vars(r)["name"]
+
+	group, err := h.service.GetLatest(r.Context(), namespace, name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusNotFound)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(resp)
+	writeJSON(w, r, http.StatusOK, group)
 }
 
-func (h ConfigGroupHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	groups, err := h.service.GetAll()
+// GET /groups/{name}
+func (h ConfigGroupHandler) GetVersions(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.GetVersions")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
+	name := mux.Vars(r)["name"]
+
+	groups, err := h.service.GetVersions(r.Context(), namespace, name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusNotFound)
 		return
 	}
 
-	resp, err := json.Marshal(groups)
+	writeJSON(w, r, http.StatusOK, groups)
+}
+
+func (h ConfigGroupHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.GetAll")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
+	groups, err := h.service.GetAll(r.Context(), namespace)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(resp)
+	writeJSON(w, r, http.StatusOK, visibleGroups(r.Context(), namespace, groups))
 }
 
 func (h ConfigGroupHandler) Create(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.Create")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
 	var group model.ConfigGroup
 
 	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	group.Namespace = namespace
+	// Metadata and the creation time are the server's to set.
+	group.Metadata = nil
+	group.CreatedAt = time.Time{}
+	if !authorize(w, r, auth.ScopeGroupsWrite, namespace, "groups/"+group.Name) {
+		return
+	}
 
-	if err := h.service.Add(group); err != nil {
-		http.Error(w, err.Error(), http.StatusConflict)
+	group, err := h.service.Add(r.Context(), group)
+	if err != nil {
+		writeError(w, r, err, http.StatusConflict)
 		return
 	}
+	recordAudit(h.audit, r, audit.ActionCreate, model.ResourceKey(namespace, "groups", group.Name), group.Version, 0, nil, group)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(group)
+	writeJSON(w, r, http.StatusCreated, group)
 }
 
 func (h ConfigGroupHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.Delete")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
 	name := mux.Vars(r)["name"]
 	version := mux.Vars(r)["version"]
 	versionInt, err := strconv.Atoi(version)
@@ -87,94 +147,191 @@ func (h ConfigGroupHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !authorize(w, r, auth.ScopeGroupsDelete, namespace, "groups/"+name) {
+		return
+	}
 
-	if err := h.service.Delete(name, versionInt); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	base, ok := h.loadBase(w, r, namespace, name, versionInt)
+	if !ok {
 		return
 	}
 
+	if err := h.service.Delete(r.Context(), namespace, name, versionInt); err != nil {
+		writeError(w, r, err, http.StatusNotFound)
+		return
+	}
+	recordAudit(h.audit, r, audit.ActionDelete, model.ResourceKey(namespace, "groups", name), versionInt, 0, base, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// POST /groups/{name}/rollback?to=3&reason=...
+func (h ConfigGroupHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.Rollback")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
+	name := mux.Vars(r)["name"]
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "invalid or missing 'to' version", http.StatusBadRequest)
+		return
+	}
+	if !authorize(w, r, auth.ScopeGroupsWrite, namespace, "groups/"+name) {
+		return
+	}
+
+	latest, err := h.service.GetLatest(r.Context(), namespace, name)
+	if err != nil {
+		writeError(w, r, err, http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, latest) {
+		return
+	}
+
+	reason := r.URL.Query().Get("reason")
+	group, err := h.service.Rollback(r.Context(), namespace, name, to, identityFromRequest(r), reason)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	recordAudit(h.audit, r, audit.ActionRollback, model.ResourceKey(namespace, "groups", name), to, group.Version, latest, group)
+
+	writeJSON(w, r, http.StatusCreated, group)
+}
+
+// GET /groups/{name}/diff?from=4&to=7
+func (h ConfigGroupHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.Diff")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
+	name := mux.Vars(r)["name"]
+	from, to, err := parseVersionRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	diff, err := h.service.Diff(r.Context(), namespace, name, from, to)
+	if err != nil {
+		writeError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	if prefersText(r) {
+		writeText(w, r, diff.Text())
+		return
+	}
+	writeJSON(w, r, http.StatusOK, diff)
+}
+
 func (h ConfigGroupHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.GetConfig")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
 	vars := mux.Vars(r)
 	name := vars["name"]
 	version := vars["version"]
 	configName := vars["configName"]
-	
+
 	versionInt, err := strconv.Atoi(version)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	config, err := h.service.GetConfig(name, versionInt, configName)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
-	}
-
-	resp, err := json.Marshal(config)
+	config, err := h.service.GetConfig(r.Context(), namespace, name, versionInt, configName)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusNotFound)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(resp)
+	writeJSON(w, r, http.StatusOK, config)
 }
 
 func (h ConfigGroupHandler) AddConfig(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.AddConfig")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
 	vars := mux.Vars(r)
 	name := vars["name"]
 	version := vars["version"]
-	
+
 	versionInt, err := strconv.Atoi(version)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if !authorize(w, r, auth.ScopeGroupsWrite, namespace, "groups/"+name) {
+		return
+	}
+
 	var config model.GroupConfig
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	newGroup, err := h.service.CreateGroupWithConfig(name, versionInt, config)
+	base, ok := h.loadBase(w, r, namespace, name, versionInt)
+	if !ok {
+		return
+	}
+
+	newGroup, err := h.service.CreateGroupWithConfig(r.Context(), namespace, name, versionInt, config)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, err, http.StatusNotFound)
 		return
 	}
+	recordAudit(h.audit, r, audit.ActionAddConfig, model.ResourceKey(namespace, "groups", name), versionInt, newGroup.Version, base, newGroup)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(newGroup)
+	writeJSON(w, r, http.StatusCreated, newGroup)
 }
 
 func (h ConfigGroupHandler) RemoveConfig(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.RemoveConfig")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
 	vars := mux.Vars(r)
 	name := vars["name"]
 	version := vars["version"]
 	configName := vars["configName"]
-	
+
 	versionInt, err := strconv.Atoi(version)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !authorize(w, r, auth.ScopeGroupsWrite, namespace, "groups/"+name) {
+		return
+	}
+
+	base, ok := h.loadBase(w, r, namespace, name, versionInt)
+	if !ok {
+		return
+	}
 
-	newGroup, err := h.service.CreateGroupWithoutConfig(name, versionInt, configName)
+	newGroup, err := h.service.CreateGroupWithoutConfig(r.Context(), namespace, name, versionInt, configName)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, r, err, http.StatusNotFound)
 		return
 	}
+	recordAudit(h.audit, r, audit.ActionRemoveConfig, model.ResourceKey(namespace, "groups", name), versionInt, newGroup.Version, base, newGroup)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(newGroup)
+	writeJSON(w, r, http.StatusCreated, newGroup)
 }
 
-// GET /groups/{name}/{version}/configs?labels=k1:v1;k2:v2
+// GET /groups/{name}/{version}/configs?labels=<selector>, e.g. env=prod,team in (a,b)
 func (h ConfigGroupHandler) GetConfigsByLabels(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.GetConfigsByLabels")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
 	vars := mux.Vars(r)
 	name := vars["name"]
 	version := vars["version"]
@@ -185,24 +342,21 @@ func (h ConfigGroupHandler) GetConfigsByLabels(w http.ResponseWriter, r *http.Re
 	}
 
 	labels := r.URL.Query().Get("labels")
-	configs, err := h.service.FilterConfigsByLabels(name, versionInt, labels)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	resp, err := json.Marshal(configs)
+	configs, err := h.service.FilterConfigsByLabels(r.Context(), namespace, name, versionInt, labels)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(resp)
+	writeJSON(w, r, http.StatusOK, configs)
 }
 
-// DELETE /groups/{name}/{version}/configs?labels=k1:v1;k2:v2
+// DELETE /groups/{name}/{version}/configs?labels=<selector>
 func (h ConfigGroupHandler) DeleteConfigsByLabels(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ConfigGroupHandler.DeleteConfigsByLabels")
+	defer span.End()
+
+	namespace := namespaceFromRequest(r)
 	vars := mux.Vars(r)
 	name := vars["name"]
 	version := vars["version"]
@@ -211,14 +365,22 @@ func (h ConfigGroupHandler) DeleteConfigsByLabels(w http.ResponseWriter, r *http
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !authorize(w, r, auth.ScopeGroupsWrite, namespace, "groups/"+name) {
+		return
+	}
+
+	base, ok := h.loadBase(w, r, namespace, name, versionInt)
+	if !ok {
+		return
+	}
 
 	labels := r.URL.Query().Get("labels")
-	newGroup, err := h.service.CreateGroupWithoutConfigsByLabels(name, versionInt, labels)
+	newGroup, err := h.service.CreateGroupWithoutConfigsByLabels(r.Context(), namespace, name, versionInt, labels)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err, http.StatusBadRequest)
 		return
 	}
+	recordAudit(h.audit, r, audit.ActionDeleteConfigsByLabels, model.ResourceKey(namespace, "groups", name), versionInt, newGroup.Version, base, newGroup)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(newGroup)
-}
\ No newline at end of file
+	writeJSON(w, r, http.StatusCreated, newGroup)
+}
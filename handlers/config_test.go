@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"projekat/model"
+	"testing"
+)
+
+func TestLatestAndVersions(t *testing.T) {
+	api := newTestAPI(t)
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV1)
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV2)
+	api.must(http.StatusCreated, "POST", "/groups", testGroupV1)
+	api.must(http.StatusCreated, "POST", "/groups/web/1/configs", `{"name":"cache"}`)
+
+	var latest model.Config
+	decodeBody(t, api.must(http.StatusOK, "GET", "/configs/db/latest", ""), &latest)
+	if latest.Version != 2 {
+		t.Errorf("latest config is version %d, want 2", latest.Version)
+	}
+	var configs []model.Config
+	decodeBody(t, api.must(http.StatusOK, "GET", "/configs/db", ""), &configs)
+	if len(configs) != 2 || configs[0].Version != 2 || configs[1].Version != 1 {
+		t.Errorf("config versions = %+v, want 2 then 1", configs)
+	}
+
+	var latestGroup model.ConfigGroup
+	decodeBody(t, api.must(http.StatusOK, "GET", "/groups/web/latest", ""), &latestGroup)
+	if latestGroup.Version != 2 || len(latestGroup.Configs) != 2 {
+		t.Errorf("latest group = %+v, want version 2 with two configs", latestGroup)
+	}
+	var groups []model.ConfigGroup
+	decodeBody(t, api.must(http.StatusOK, "GET", "/groups/web", ""), &groups)
+	if len(groups) != 2 || groups[0].Version != 2 || groups[1].Version != 1 {
+		t.Errorf("group versions = %+v, want 2 then 1", groups)
+	}
+
+	// Deleting the newest version makes the one before it the latest.
+	api.must(http.StatusNoContent, "DELETE", "/configs/db/2", "")
+	decodeBody(t, api.must(http.StatusOK, "GET", "/configs/db/latest", ""), &latest)
+	if latest.Version != 1 {
+		t.Errorf("latest config after deleting version 2 is %d, want 1", latest.Version)
+	}
+
+	for _, target := range []string{"/configs/nope/latest", "/configs/nope", "/groups/nope/latest", "/groups/nope"} {
+		if rec := api.do("GET", target, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", target, rec.Code)
+		}
+	}
+}
+
+func TestRollbackStatusCodes(t *testing.T) {
+	api := newTestAPI(t)
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV1)
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV2)
+	api.must(http.StatusCreated, "POST", "/configs", `{"name":"db","version":3}`)
+	api.must(http.StatusNoContent, "DELETE", "/configs/db/2", "")
+
+	var rolledBack model.Config
+	decodeBody(t, api.must(http.StatusCreated, "POST", "/configs/db/rollback?to=1&reason=bad+deploy", ""), &rolledBack)
+	if rolledBack.Version != 4 || len(rolledBack.Parameters) != 1 || rolledBack.Parameters[0].Value != "a" {
+		t.Errorf("rollback created %+v, want version 4 with the parameters of version 1", rolledBack)
+	}
+	if rolledBack.Metadata[model.MetadataRollbackOf] != "1" || rolledBack.Metadata[model.MetadataReason] != "bad deploy" {
+		t.Errorf("rollback metadata = %v", rolledBack.Metadata)
+	}
+	// A deleted newest version is gone, not unknown, and its number is
+	// not handed out again.
+	api.must(http.StatusNoContent, "DELETE", "/configs/db/4", "")
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/configs/db/rollback", http.StatusBadRequest},
+		{"/configs/db/rollback?to=x", http.StatusBadRequest},
+		{"/configs/db/rollback?to=3", http.StatusBadRequest},
+		{"/configs/db/rollback?to=2", http.StatusGone},
+		{"/configs/db/rollback?to=4", http.StatusGone},
+		{"/configs/db/rollback?to=5", http.StatusNotFound},
+		{"/configs/db/rollback?to=0", http.StatusNotFound},
+		{"/configs/nope/rollback?to=1", http.StatusNotFound},
+		{"/groups/nope/rollback?to=1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := api.do("POST", tt.target, ""); rec.Code != tt.want {
+			t.Errorf("POST %s: status %d, want %d: %s", tt.target, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	decodeBody(t, api.must(http.StatusCreated, "POST", "/configs/db/rollback?to=1", ""), &rolledBack)
+	if rolledBack.Version != 5 {
+		t.Errorf("rollback after deleting version 4 created version %d, want 5", rolledBack.Version)
+	}
+
+	api.must(http.StatusCreated, "POST", "/groups", testGroupV1)
+	api.must(http.StatusCreated, "POST", "/groups/web/1/configs", `{"name":"cache"}`)
+	var group model.ConfigGroup
+	decodeBody(t, api.must(http.StatusCreated, "POST", "/groups/web/rollback?to=1", ""), &group)
+	if group.Version != 3 || len(group.Configs) != 1 {
+		t.Errorf("group rollback created %+v, want version 3 with the configs of version 1", group)
+	}
+	api.must(http.StatusBadRequest, "POST", "/groups/web/rollback?to=3", "")
+}
+
+func TestCreateIgnoresServerFields(t *testing.T) {
+	api := newTestAPI(t)
+	body := `{"name":"db","version":1,"createdAt":"2000-01-01T00:00:00Z","metadata":{"rollbackOf":"7","createdBy":"someone"}}`
+	var created model.Config
+	decodeBody(t, api.must(http.StatusCreated, "POST", "/configs", body), &created)
+	if len(created.Metadata) != 0 || created.CreatedAt.Year() == 2000 {
+		t.Errorf("created %+v, want the client's metadata and creation time ignored", created)
+	}
+
+	body = `{"name":"web","version":1,"createdAt":"2000-01-01T00:00:00Z","metadata":{"rollbackOf":"7"}}`
+	var group model.ConfigGroup
+	decodeBody(t, api.must(http.StatusCreated, "POST", "/groups", body), &group)
+	if len(group.Metadata) != 0 || group.CreatedAt.Year() == 2000 {
+		t.Errorf("created %+v, want the client's metadata and creation time ignored", group)
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Deadline bounds the time a handler may spend serving a request. Its
+// context, which services and repositories pass on to the backend, is
+// cancelled once the timeout passes, so a slow Consul call is abandoned and
+// answered with 504 Gateway Timeout.
+type Deadline struct {
+	timeout time.Duration
+}
+
+// NewDeadline returns a Deadline of timeout; zero or less disables it.
+func NewDeadline(timeout time.Duration) Deadline {
+	return Deadline{
+		timeout: timeout,
+	}
+}
+
+// Wrap applies the deadline to next. Wrap blocking reads as
+// blocking.Wrap(deadline.Wrap(h)) so the time spent waiting for a change
+// does not count.
+func (d Deadline) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	if d.timeout <= 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d.timeout)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"projekat/model"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigDiff(t *testing.T) {
+	api := newTestAPI(t)
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV1)
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV2)
+
+	var diff model.ConfigDiff
+	decodeBody(t, api.must(http.StatusOK, "GET", "/configs/db/diff?from=1&to=2", ""), &diff)
+	want := model.ConfigDiff{
+		Name: "db",
+		From: 1,
+		To:   2,
+		Parameters: model.ParameterDiff{
+			Added:   []model.ConfigParameter{{Key: "port", Value: "5432"}},
+			Removed: []model.ConfigParameter{},
+			Changed: []model.ParameterChange{{Key: "host", From: "a", To: "b"}},
+		},
+		LabelsAdded:   []model.Label{{Key: "env", Value: "prod"}},
+		LabelsRemoved: []model.Label{{Key: "env", Value: "dev"}},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diff = %+v, want %+v", diff, want)
+	}
+
+	rec := api.must(http.StatusOK, "GET", "/configs/db/diff?from=1&to=2", "", "Accept", "text/plain")
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	wantText := "--- configs/db@1\n+++ configs/db@2\n-host=a\n+host=b\n+port=5432\n-label env:dev\n+label env:prod\n"
+	if rec.Body.String() != wantText {
+		t.Errorf("text diff =\n%s\nwant\n%s", rec.Body, wantText)
+	}
+
+	for target, want := range map[string]int{
+		"/configs/db/diff?to=2":        http.StatusBadRequest,
+		"/configs/db/diff?from=1":      http.StatusBadRequest,
+		"/configs/db/diff?from=1&to=x": http.StatusBadRequest,
+		"/configs/db/diff?from=1&to=3": http.StatusNotFound,
+		"/configs/no/diff?from=1&to=2": http.StatusNotFound,
+	} {
+		if rec := api.do("GET", target, ""); rec.Code != want {
+			t.Errorf("GET %s: status %d, want %d", target, rec.Code, want)
+		}
+	}
+}
+
+func TestGroupDiff(t *testing.T) {
+	api := newTestAPI(t)
+	api.must(http.StatusCreated, "POST", "/groups", testGroupV1)
+	api.must(http.StatusCreated, "POST", "/groups/web/1/configs", `{"name":"cache","parameters":[{"key":"size","value":"1g"}]}`)
+
+	var diff model.GroupDiff
+	decodeBody(t, api.must(http.StatusOK, "GET", "/groups/web/diff?from=1&to=2", ""), &diff)
+	if len(diff.ConfigsAdded) != 1 || diff.ConfigsAdded[0].Name != "cache" || len(diff.ConfigsRemoved) != 0 || len(diff.ConfigsChanged) != 0 {
+		t.Errorf("diff = %+v, want only cache added", diff)
+	}
+
+	rec := api.must(http.StatusOK, "GET", "/groups/web/diff?from=2&to=1", "", "Accept", "text/plain")
+	if !strings.HasPrefix(rec.Body.String(), "--- groups/web@2\n+++ groups/web@1\n@@ -config cache\n") {
+		t.Errorf("text diff =\n%s", rec.Body)
+	}
+
+	for target, want := range map[string]int{
+		"/groups/web/diff?from=1":      http.StatusBadRequest,
+		"/groups/web/diff?from=1&to=9": http.StatusNotFound,
+	} {
+		if rec := api.do("GET", target, ""); rec.Code != want {
+			t.Errorf("GET %s: status %d, want %d", target, rec.Code, want)
+		}
+	}
+}
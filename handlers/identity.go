@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"projekat/auth"
+	"projekat/logging"
+)
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying the identity of the client that
+// issued the request.
+func WithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// identityFromRequest returns the authenticated principal, else the
+// identity stored by WithIdentity, falling back to the peer address.
+func identityFromRequest(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return principal.ID
+	}
+	if identity, ok := r.Context().Value(identityKey{}).(string); ok && identity != "" {
+		return identity
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requestIDFromRequest returns the ID assigned by logging.Middleware, or
+// the client's X-Request-ID outside of it.
+func requestIDFromRequest(r *http.Request) string {
+	if id := logging.RequestID(r.Context()); id != "" {
+		return id
+	}
+	return r.Header.Get("X-Request-ID")
+}
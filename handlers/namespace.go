@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"projekat/model"
+	"projekat/services"
+
+	"github.com/gorilla/mux"
+)
+
+// namespaceFromRequest returns the namespace of a /namespaces/{namespace}/...
+// route, or the default namespace for the un-namespaced routes.
+func namespaceFromRequest(r *http.Request) string {
+	if namespace := mux.Vars(r)["namespace"]; namespace != "" {
+		return namespace
+	}
+	return model.DefaultNamespace
+}
+
+type NamespaceHandler struct {
+	service services.NamespaceService
+}
+
+func NewNamespaceHandler(service services.NamespaceService) NamespaceHandler {
+	return NamespaceHandler{
+		service: service,
+	}
+}
+
+// GET /namespaces
+func (h NamespaceHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	namespaces, err := h.service.GetAll(r.Context())
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, namespaces)
+}
+
+// POST /namespaces
+func (h NamespaceHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req model.Namespace
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	namespace, err := h.service.Create(r.Context(), req.Name)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, namespace)
+}
+
+// GET /namespaces/{namespace}
+func (h NamespaceHandler) Get(w http.ResponseWriter, r *http.Request) {
+	namespace, err := h.service.Get(r.Context(), mux.Vars(r)["namespace"])
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, namespace)
+}
+
+// DELETE /namespaces/{namespace}
+//
+// Only empty namespaces can be deleted; the default namespace never.
+func (h NamespaceHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Delete(r.Context(), mux.Vars(r)["namespace"]); err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
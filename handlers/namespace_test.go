@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"projekat/model"
+	"testing"
+)
+
+func TestNamespaceRouting(t *testing.T) {
+	api := newTestAPI(t)
+	api.must(http.StatusCreated, "POST", "/namespaces", `{"name":"team-a"}`)
+
+	var created model.Config
+	decodeBody(t, api.must(http.StatusCreated, "POST", "/namespaces/team-a/configs", testConfigV1), &created)
+	if created.Namespace != "team-a" {
+		t.Errorf("config created in namespace %q, want team-a", created.Namespace)
+	}
+	api.must(http.StatusOK, "GET", "/namespaces/team-a/configs/db/1", "")
+	api.must(http.StatusOK, "GET", "/namespaces/team-a/configs/db/latest", "")
+
+	// The same name in the default namespace is a different config.
+	api.must(http.StatusNotFound, "GET", "/configs/db/1", "")
+	var configs []model.Config
+	decodeBody(t, api.must(http.StatusOK, "GET", "/configs", ""), &configs)
+	if len(configs) != 0 {
+		t.Errorf("default namespace lists %+v, want nothing", configs)
+	}
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV2)
+	decodeBody(t, api.must(http.StatusOK, "GET", "/namespaces/team-a/configs", ""), &configs)
+	if len(configs) != 1 || configs[0].Version != 1 {
+		t.Errorf("team-a lists %+v, want only its own version 1", configs)
+	}
+
+	// /namespaces/default is the same as the unprefixed routes.
+	api.must(http.StatusOK, "GET", "/namespaces/default/configs/db/2", "")
+
+	api.must(http.StatusCreated, "POST", "/namespaces/team-a/groups", testGroupV1)
+	api.must(http.StatusNotFound, "GET", "/groups/web/1", "")
+
+	for _, tt := range []struct{ method, target, body string }{
+		{"POST", "/namespaces/team-b/configs", testConfigV1},
+		{"POST", "/namespaces/team-b/groups", testGroupV1},
+		{"GET", "/namespaces/team-b", ""},
+	} {
+		if rec := api.do(tt.method, tt.target, tt.body); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: status %d, want 404 for an unknown namespace", tt.method, tt.target, rec.Code)
+		}
+	}
+
+	// Only an empty namespace can be deleted.
+	if rec := api.do("DELETE", "/namespaces/team-a", ""); rec.Code != http.StatusConflict {
+		t.Errorf("deleting a namespace in use: status %d, want 409", rec.Code)
+	}
+	api.must(http.StatusNoContent, "DELETE", "/namespaces/team-a/configs/db/1", "")
+	api.must(http.StatusNoContent, "DELETE", "/namespaces/team-a/groups/web/1", "")
+	api.must(http.StatusNoContent, "DELETE", "/namespaces/team-a", "")
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"projekat/auth"
+	"projekat/model"
+	"projekat/services"
+)
+
+type QuotaHandler struct {
+	service services.QuotaService
+}
+
+func NewQuotaHandler(service services.QuotaService) QuotaHandler {
+	return QuotaHandler{
+		service: service,
+	}
+}
+
+type usageResult struct {
+	Namespace string          `json:"namespace"`
+	Usage     model.Usage     `json:"usage"`
+	Limits    model.Quota     `json:"limits"`
+	Principal *principalUsage `json:"principal,omitempty"`
+}
+
+// principalUsage is what the caller created in the namespace.
+type principalUsage struct {
+	ID     string      `json:"id"`
+	Usage  model.Usage `json:"usage"`
+	Limits model.Quota `json:"limits"`
+}
+
+// GET [/namespaces/{namespace}]/usage
+//
+// Limits that are not set are omitted. Authenticated callers also get their
+// own usage.
+func (h QuotaHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	namespace := namespaceFromRequest(r)
+	usage, quota, err := h.service.Usage(r.Context(), namespace)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	result := usageResult{Namespace: namespace, Usage: usage, Limits: quota}
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		own, ownQuota, err := h.service.PrincipalUsage(r.Context(), namespace, principal.ID)
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		result.Principal = &principalUsage{ID: principal.ID, Usage: own, Limits: ownQuota}
+	}
+
+	writeJSON(w, r, http.StatusOK, result)
+}
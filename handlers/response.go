@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"projekat/model"
+	"projekat/tracing"
+	"strconv"
+	"strings"
+)
+
+// computeETag returns a strong entity tag derived from the JSON
+// representation of v, together with that representation.
+func computeETag(v interface{}) (string, []byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`, body, nil
+}
+
+// etagListMatches reports whether the If-Match/If-None-Match header value
+// contains tag. Weak tags never match because comparison is strong.
+func etagListMatches(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSON writes v as JSON with its ETag. Successful GET requests whose
+// If-None-Match matches the current tag get 304 Not Modified instead.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	tag, body, err := computeETag(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", tag)
+	if status == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		if inm := r.Header.Get("If-None-Match"); inm != "" && etagListMatches(inm, tag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// prefersText reports whether the Accept header ranks text/plain ahead of
+// JSON.
+func prefersText(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		switch mediaType {
+		case "text/plain":
+			return true
+		case "application/json", "*/*":
+			return false
+		}
+	}
+	return false
+}
+
+// writeText writes a plain-text body with its ETag.
+func writeText(w http.ResponseWriter, r *http.Request, text string) {
+	sum := sha256.Sum256([]byte(text))
+	tag := `"` + hex.EncodeToString(sum[:]) + `"`
+	w.Header().Set("ETag", tag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagListMatches(inm, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(text))
+}
+
+// parseVersionRange reads the from and to query parameters of a diff
+// request.
+func parseVersionRange(r *http.Request) (int, int, error) {
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		return 0, 0, errors.New("invalid or missing 'from' version")
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		return 0, 0, errors.New("invalid or missing 'to' version")
+	}
+	return from, to, nil
+}
+
+// checkIfMatch verifies the request's If-Match header against the current
+// state of the resource. It writes 412 Precondition Failed and returns false
+// when the precondition does not hold.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, current interface{}) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return true
+	}
+	tag, _, err := computeETag(current)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !etagListMatches(ifMatch, tag) {
+		w.Header().Set("ETag", tag)
+		http.Error(w, model.ErrPreconditionFailed.Error(), http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// writeError maps repository and service errors to HTTP status codes,
+// using fallback for errors it does not recognise.
+func writeError(w http.ResponseWriter, r *http.Request, err error, fallback int) {
+	status := fallback
+	switch {
+	case errors.Is(err, model.ErrConfigNotFound),
+		errors.Is(err, model.ErrGroupNotFound),
+		errors.Is(err, model.ErrConfigNotInGroup),
+		errors.Is(err, model.ErrNamespaceNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, model.ErrNamespaceExists),
+		errors.Is(err, model.ErrNamespaceNotEmpty),
+		errors.Is(err, model.ErrNamespaceProtected):
+		status = http.StatusConflict
+	case errors.Is(err, model.ErrInvalidNamespace),
+		errors.Is(err, model.ErrInvalidName):
+		status = http.StatusBadRequest
+	case errors.Is(err, model.ErrQuotaExceeded):
+		status = http.StatusForbidden
+	case errors.Is(err, model.ErrStorageFull):
+		status = http.StatusInsufficientStorage
+	case errors.Is(err, model.ErrConfigExists),
+		errors.Is(err, model.ErrGroupExists),
+		errors.Is(err, model.ErrConcurrentUpdate):
+		// A conditional request lost a race against another writer, so the
+		// state it was based on is no longer current.
+		if r.Header.Get("If-Match") != "" {
+			status = http.StatusPreconditionFailed
+		} else {
+			status = http.StatusConflict
+		}
+	case errors.Is(err, model.ErrPreconditionFailed):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, model.ErrVersionDeleted):
+		status = http.StatusGone
+	case errors.Is(err, model.ErrInvalidRollback):
+		status = http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		// The request's deadline passed while waiting on the repository.
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		// The client went away or the server is shutting down.
+		status = http.StatusServiceUnavailable
+	}
+	tracing.SpanFromContext(r.Context()).RecordError(err)
+	http.Error(w, err.Error(), status)
+}
+
+// startSpan begins the span of a handler method and returns the request
+// carrying it, so service and repository spans become its children.
+func startSpan(r *http.Request, name string) (*http.Request, *tracing.Span) {
+	ctx, span := tracing.Start(r.Context(), name)
+	return r.WithContext(ctx), span
+}
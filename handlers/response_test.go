@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestETagPreconditions(t *testing.T) {
+	api := newTestAPI(t)
+	api.must(http.StatusCreated, "POST", "/configs", testConfigV1)
+	created := api.must(http.StatusCreated, "POST", "/groups", testGroupV1)
+	groupTag := created.Header().Get("ETag")
+	configTag := api.must(http.StatusOK, "GET", "/configs/db/1", "").Header().Get("ETag")
+	const stale = `"0000"`
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		headers []string
+		want    int
+	}{
+		{"group unchanged", "GET", "/groups/web/1", "", []string{"If-None-Match", groupTag}, http.StatusNotModified},
+		{"group in a list of tags", "GET", "/groups/web/1", "", []string{"If-None-Match", stale + ", " + groupTag}, http.StatusNotModified},
+		{"group weak tag", "GET", "/groups/web/1", "", []string{"If-None-Match", "W/" + groupTag}, http.StatusOK},
+		{"group changed", "GET", "/groups/web/1", "", []string{"If-None-Match", stale}, http.StatusOK},
+		{"config unchanged", "GET", "/configs/db/1", "", []string{"If-None-Match", configTag}, http.StatusNotModified},
+		{"stale add config", "POST", "/groups/web/1/configs", `{"name":"cache"}`, []string{"If-Match", stale}, http.StatusPreconditionFailed},
+		{"stale remove config", "DELETE", "/groups/web/1/configs/server", "", []string{"If-Match", stale}, http.StatusPreconditionFailed},
+		{"stale group delete", "DELETE", "/groups/web/1", "", []string{"If-Match", stale}, http.StatusPreconditionFailed},
+		{"stale config delete", "DELETE", "/configs/db/1", "", []string{"If-Match", stale}, http.StatusPreconditionFailed},
+		{"stale rollback", "POST", "/groups/web/rollback?to=1", "", []string{"If-Match", stale}, http.StatusPreconditionFailed},
+		{"current add config", "POST", "/groups/web/1/configs", `{"name":"cache"}`, []string{"If-Match", groupTag}, http.StatusCreated},
+		// Version 1 still matches, but version 2 was created from it in
+		// the meantime: the request lost the race.
+		{"lost race", "POST", "/groups/web/1/configs", `{"name":"queue"}`, []string{"If-Match", groupTag}, http.StatusPreconditionFailed},
+		{"any version", "DELETE", "/configs/db/1", "", []string{"If-Match", "*"}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		rec := api.do(tt.method, tt.target, tt.body, tt.headers...)
+		if rec.Code != tt.want {
+			t.Errorf("%s: %s %s answered %d, want %d: %s", tt.name, tt.method, tt.target, rec.Code, tt.want, rec.Body)
+		}
+		switch rec.Code {
+		case http.StatusNotModified:
+			if rec.Body.Len() != 0 || rec.Header().Get("ETag") == "" {
+				t.Errorf("%s: 304 with body %q and ETag %q", tt.name, rec.Body, rec.Header().Get("ETag"))
+			}
+		case http.StatusPreconditionFailed:
+			if tt.name != "lost race" && rec.Header().Get("ETag") == "" {
+				t.Errorf("%s: 412 without the current ETag", tt.name)
+			}
+		}
+	}
+
+	// The ETag of a created version is that of the stored version.
+	latest := api.must(http.StatusOK, "GET", "/groups/web/2", "")
+	added := api.must(http.StatusCreated, "POST", "/groups/web/2/configs", `{"name":"queue"}`)
+	if stored := api.must(http.StatusOK, "GET", "/groups/web/3", ""); stored.Header().Get("ETag") != added.Header().Get("ETag") {
+		t.Errorf("created with ETag %s, stored with %s", added.Header().Get("ETag"), stored.Header().Get("ETag"))
+	}
+	if latest.Header().Get("ETag") == added.Header().Get("ETag") {
+		t.Error("a new version has the ETag of its base")
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"projekat/model"
+	"projekat/services"
+)
+
+type SearchHandler struct {
+	configs services.ConfigService
+	groups  services.ConfigGroupService
+}
+
+func NewSearchHandler(configs services.ConfigService, groups services.ConfigGroupService) SearchHandler {
+	return SearchHandler{
+		configs: configs,
+		groups:  groups,
+	}
+}
+
+type searchResult struct {
+	Configs      []model.Config           `json:"configs"`
+	GroupConfigs []model.GroupConfigMatch `json:"groupConfigs"`
+}
+
+// GET [/namespaces/{namespace}]/search?selector=env=prod,team in (backend)
+func (h SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	namespace := namespaceFromRequest(r)
+	selector, err := model.ParseSelector(r.URL.Query().Get("selector"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	configs, err := h.configs.Search(r.Context(), namespace, selector)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	groupConfigs, err := h.groups.Search(r.Context(), namespace, selector)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, searchResult{
+		Configs:      visibleConfigs(r.Context(), namespace, configs),
+		GroupConfigs: visibleGroupConfigs(r.Context(), namespace, groupConfigs),
+	})
+}
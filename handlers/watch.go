@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"projekat/events"
+	"strconv"
+	"time"
+)
+
+const watchKeepAlive = 15 * time.Second
+
+type WatchHandler struct {
+	broker *events.Broker
+}
+
+func NewWatchHandler(broker *events.Broker) WatchHandler {
+	return WatchHandler{
+		broker: broker,
+	}
+}
+
+// GET /watch?resource=groups&namespace=default&name=web_configs
+//
+// Streams change events as Server-Sent Events. All filters are optional.
+// Clients resume after a reconnect with the Last-Event-ID header; when the
+// requested events are no longer buffered a "reset" event is sent first so
+// the client knows to re-read current state. Events about resources the
+// caller's grants do not cover are left out.
+func (h WatchHandler) Watch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	resource := query.Get("resource")
+	if resource != "" && resource != events.ResourceConfigs && resource != events.ResourceGroups {
+		http.Error(w, "resource must be 'configs' or 'groups'", http.StatusBadRequest)
+		return
+	}
+	namespace := query.Get("namespace")
+	name := query.Get("name")
+
+	var lastID uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastID = id
+	} else {
+		lastID = h.broker.LastID()
+	}
+
+	sub, backlog, complete := h.broker.Subscribe(lastID)
+	defer h.broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	matches := func(e events.Event) bool {
+		return (resource == "" || e.Resource == resource) &&
+			(namespace == "" || e.Namespace == namespace) &&
+			(name == "" || e.Name == name) &&
+			eventVisible(r.Context(), e)
+	}
+
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range backlog {
+		if matches(e) {
+			writeEvent(w, e)
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if matches(e) {
+				writeEvent(w, e)
+				flusher.Flush()
+			}
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"projekat/auth"
+	"projekat/webhooks"
+
+	"github.com/gorilla/mux"
+)
+
+type WebhookHandler struct {
+	manager *webhooks.Manager
+}
+
+func NewWebhookHandler(manager *webhooks.Manager) WebhookHandler {
+	return WebhookHandler{
+		manager: manager,
+	}
+}
+
+func writeWebhookError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, webhooks.ErrWebhookNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// POST /webhooks
+func (h WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var webhook webhooks.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !webhookVisible(r.Context(), webhook) {
+		http.Error(w, "the filter covers namespaces you cannot read", http.StatusForbidden)
+		return
+	}
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		webhook.Principal = &principal
+	}
+
+	created, err := h.manager.Register(webhook)
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, created)
+}
+
+// GET /webhooks
+//
+// Only webhooks whose filter the caller could have registered are listed.
+func (h WebhookHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	all := h.manager.List()
+	visible := all[:0]
+	for _, webhook := range all {
+		if webhookVisible(r.Context(), webhook) {
+			visible = append(visible, webhook)
+		}
+	}
+	writeJSON(w, r, http.StatusOK, visible)
+}
+
+// get returns the webhook named in the path, treating webhooks the caller
+// may not see as missing.
+func (h WebhookHandler) get(r *http.Request) (webhooks.Webhook, error) {
+	webhook, err := h.manager.Get(mux.Vars(r)["id"])
+	if err != nil {
+		return webhooks.Webhook{}, err
+	}
+	if !webhookVisible(r.Context(), webhook) {
+		return webhooks.Webhook{}, webhooks.ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// GET /webhooks/{id}
+func (h WebhookHandler) Get(w http.ResponseWriter, r *http.Request) {
+	webhook, err := h.get(r)
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, webhook)
+}
+
+// DELETE /webhooks/{id}
+func (h WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	webhook, err := h.get(r)
+	if err == nil {
+		err = h.manager.Delete(webhook.ID)
+	}
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /webhooks/{id}/deliveries
+func (h WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook, err := h.get(r)
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+	deliveries, err := h.manager.Deliveries(webhook.ID)
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, deliveries)
+}
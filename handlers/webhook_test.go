@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"projekat/auth"
+	"projekat/events"
+	"projekat/webhooks"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestWebhooksAreLimitedToReadableNamespaces(t *testing.T) {
+	manager := webhooks.NewManager(events.NewBroker(10), webhooks.Options{})
+	handler := NewWebhookHandler(manager)
+	router := mux.NewRouter()
+	router.HandleFunc("/webhooks", handler.GetAll).Methods("GET")
+	router.HandleFunc("/webhooks", handler.Create).Methods("POST")
+	router.HandleFunc("/webhooks/{id}", handler.Get).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", handler.Delete).Methods("DELETE")
+
+	teamA := auth.Principal{ID: "apikey:team-a", Grants: []auth.Grant{{
+		Permissions: []string{auth.ScopeConfigsRead, auth.ScopeWebhooksRead, auth.ScopeWebhooksWrite},
+		Namespaces:  []string{"team-a"},
+	}}}
+	admin := auth.Principal{ID: "apikey:admin", Grants: []auth.Grant{{Permissions: []string{"*"}}}}
+	do := func(p auth.Principal, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req = req.WithContext(auth.WithPrincipal(context.Background(), p))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   int
+	}{
+		{"own namespace", `{"namespaces":["team-a"]}`, http.StatusCreated},
+		{"other namespace", `{"namespaces":["team-a","team-b"]}`, http.StatusForbidden},
+		{"every namespace", `{}`, http.StatusForbidden},
+		{"resource without read access", `{"namespaces":["team-a"],"resources":["groups"]}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rec := do(teamA, "POST", "/webhooks", `{"url":"http://example.com/hook","filter":`+tt.filter+`}`)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	rec := do(admin, "POST", "/webhooks", `{"url":"http://example.com/all"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("admin create: %d %s", rec.Code, rec.Body)
+	}
+	var global webhooks.Webhook
+	json.NewDecoder(rec.Body).Decode(&global)
+
+	var listed []webhooks.Webhook
+	json.NewDecoder(do(teamA, "GET", "/webhooks", "").Body).Decode(&listed)
+	if len(listed) != 1 || listed[0].URL != "http://example.com/hook" {
+		t.Errorf("team-a lists %+v, want only its own webhook", listed)
+	}
+	if rec := do(teamA, "GET", "/webhooks/"+global.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("team-a gets the global webhook: %d", rec.Code)
+	}
+	if rec := do(teamA, "DELETE", "/webhooks/"+global.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("team-a deletes the global webhook: %d", rec.Code)
+	}
+	json.NewDecoder(do(admin, "GET", "/webhooks", "").Body).Decode(&listed)
+	if len(listed) != 2 {
+		t.Errorf("admin lists %d webhooks, want 2", len(listed))
+	}
+}
@@ -0,0 +1,170 @@
+// Package health serves liveness and readiness probes and build
+// information.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"projekat/logging"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports whether a dependency is usable. It should return promptly
+// once ctx is done.
+type Check func(ctx context.Context) error
+
+// Registrar is implemented by components, such as repositories, that know
+// how to check the dependencies they rely on.
+type Registrar interface {
+	RegisterHealthChecks(c *Checker)
+}
+
+var (
+	errStarting = errors.New("starting")
+	errDraining = errors.New("shutting down")
+)
+
+// Checker decides readiness: the server has finished starting, is not
+// shutting down and every registered check passes.
+type Checker struct {
+	mu       sync.RWMutex
+	checks   map[string]Check
+	timeout  time.Duration
+	started  atomic.Bool
+	draining atomic.Bool
+}
+
+// NewChecker returns a checker that gives each check at most timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Checker{
+		checks:  make(map[string]Check),
+		timeout: timeout,
+	}
+}
+
+// Register adds a readiness check, replacing an earlier one of the same
+// name. Components sharing a dependency can therefore each register it.
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// RegisterFrom registers the checks of every component that provides
+// some; others are skipped.
+func (c *Checker) RegisterFrom(components ...interface{}) {
+	for _, component := range components {
+		if registrar, ok := component.(Registrar); ok {
+			registrar.RegisterHealthChecks(c)
+		}
+	}
+}
+
+// MarkStarted records that the repositories are open and replayed, and
+// the server is ready to take traffic.
+func (c *Checker) MarkStarted() {
+	c.started.Store(true)
+}
+
+// MarkDraining makes the server unready for good; it is called as soon as
+// shutdown begins.
+func (c *Checker) MarkDraining() {
+	c.draining.Store(true)
+}
+
+// Status is the readiness report, with the result of every check: "ok",
+// "starting" or "shutting down" for the server itself, and "failing" for a
+// dependency. Probes are unauthenticated, so errors, which may name internal
+// addresses, are only logged.
+type Status struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Ready runs the checks concurrently and reports whether all of them pass.
+func (c *Checker) Ready(ctx context.Context) (bool, Status) {
+	results := map[string]error{
+		"startup":  nil,
+		"shutdown": nil,
+	}
+	if !c.started.Load() {
+		results["startup"] = errStarting
+	}
+	if c.draining.Load() {
+		results["shutdown"] = errDraining
+	}
+
+	c.mu.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			err := check(ctx)
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	ready := true
+	status := Status{Status: "ready", Checks: make(map[string]string, len(results))}
+	for name, err := range results {
+		switch {
+		case err == errStarting || err == errDraining:
+			ready = false
+			status.Checks[name] = err.Error()
+		case err != nil:
+			ready = false
+			status.Checks[name] = "failing"
+			logging.FromContext(ctx).Warn("Readiness check failed", "check", name, "error", err)
+		default:
+			status.Checks[name] = "ok"
+		}
+	}
+	if !ready {
+		status.Status = "unavailable"
+	}
+	return ready, status
+}
+
+// ServeLive answers GET /healthz: 200 as long as the process can serve
+// requests at all.
+func (c *Checker) ServeLive(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ServeReady answers GET /readyz: 200 when Ready, 503 otherwise, with the
+// per-check results in both cases.
+func (c *Checker) ServeReady(w http.ResponseWriter, r *http.Request) {
+	ready, status := c.Ready(r.Context())
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
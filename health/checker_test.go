@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeReadyHidesErrors(t *testing.T) {
+	c := NewChecker(0)
+	c.MarkStarted()
+	c.Register("consul", func(context.Context) error {
+		return errors.New(`Get "http://consul.internal:8500/v1/status/leader": connection refused`)
+	})
+	c.Register("wal:configs", func(context.Context) error { return nil })
+
+	rec := httptest.NewRecorder()
+	c.ServeReady(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	want := `{"status":"unavailable","checks":{"consul":"failing","shutdown":"ok","startup":"ok","wal:configs":"ok"}}`
+	if got := strings.TrimSpace(string(body)); got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+func TestReadyReportsStartupAndShutdown(t *testing.T) {
+	c := NewChecker(0)
+	if ready, status := c.Ready(context.Background()); ready || status.Checks["startup"] != "starting" {
+		t.Errorf("before MarkStarted: ready = %v, status = %+v", ready, status)
+	}
+	c.MarkStarted()
+	if ready, _ := c.Ready(context.Background()); !ready {
+		t.Error("not ready after MarkStarted")
+	}
+	c.MarkDraining()
+	if ready, status := c.Ready(context.Background()); ready || status.Checks["shutdown"] != "shutting down" {
+		t.Errorf("after MarkDraining: ready = %v, status = %+v", ready, status)
+	}
+}
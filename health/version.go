@@ -0,0 +1,47 @@
+package health
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// BuildInfo describes the running binary for GET /version.
+type BuildInfo struct {
+	Commit    string            `json:"commit"`
+	BuildTime string            `json:"buildTime"`
+	GoVersion string            `json:"goVersion"`
+	Backends  map[string]string `json:"backends"`
+}
+
+// NewBuildInfo returns the build information of the binary. commit and
+// buildTime are normally set with -ldflags at build time; when they are
+// empty the VCS stamp the go tool embeds is used, if there is one.
+func NewBuildInfo(commit, buildTime string, backends map[string]string) BuildInfo {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && commit == "":
+				commit = setting.Value
+			case setting.Key == "vcs.time" && buildTime == "":
+				buildTime = setting.Value
+			}
+		}
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+	if buildTime == "" {
+		buildTime = "unknown"
+	}
+	return BuildInfo{
+		Commit:    commit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+		Backends:  backends,
+	}
+}
+
+func (b BuildInfo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, b)
+}
@@ -0,0 +1,41 @@
+// Package httpstatus records what a handler wrote to its response, for the
+// middlewares that log, measure and trace requests.
+package httpstatus
+
+import "net/http"
+
+// Recorder remembers the status code and body size written through it. It
+// forwards Flush so streaming handlers keep working.
+type Recorder struct {
+	http.ResponseWriter
+	// Status is the status code sent, 200 until the handler writes.
+	Status int
+	// Bytes counts the body bytes written.
+	Bytes       int
+	wroteHeader bool
+}
+
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.Status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.Bytes += n
+	return n, err
+}
+
+func (r *Recorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
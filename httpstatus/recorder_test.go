@@ -0,0 +1,47 @@
+package httpstatus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle func(w http.ResponseWriter)
+		status int
+		bytes  int
+	}{
+		{"nothing written", func(w http.ResponseWriter) {}, http.StatusOK, 0},
+		{"body only", func(w http.ResponseWriter) { w.Write([]byte("hello")) }, http.StatusOK, 5},
+		{"status and body", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("gone"))
+		}, http.StatusNotFound, 4},
+		{"second status ignored", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusCreated)
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusCreated, 0},
+		{"status after body ignored", func(w http.ResponseWriter) {
+			w.Write([]byte("x"))
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		recorder := NewRecorder(httptest.NewRecorder())
+		tt.handle(recorder)
+		if recorder.Status != tt.status || recorder.Bytes != tt.bytes {
+			t.Errorf("%s: recorded %d with %d bytes, want %d with %d", tt.name, recorder.Status, recorder.Bytes, tt.status, tt.bytes)
+		}
+	}
+}
+
+func TestRecorderForwardsFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	var flusher http.Flusher = NewRecorder(w)
+	flusher.Flush()
+	if !w.Flushed {
+		t.Error("Flush was not forwarded")
+	}
+}
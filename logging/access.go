@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"projekat/httpstatus"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware assigns every request an ID, keeping one sent by the client
+// in X-Request-ID, echoes it in the response and stores it together with a
+// logger tagged with it in the request context. When the request finishes
+// it writes one access log line: 5xx responses at error level, everything
+// else at info. Install it with Router.Use so the route template is known.
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = NewRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		logger := l.With("request_id", requestID)
+		access := &fields{m: make(map[string]interface{})}
+		ctx := WithRequestID(r.Context(), requestID)
+		ctx = NewContext(ctx, logger)
+		ctx = context.WithValue(ctx, fieldsKey, access)
+
+		recorder := httpstatus.NewRecorder(w)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+		level := LevelInfo
+		if recorder.Status >= 500 {
+			level = LevelError
+		}
+		kv := []interface{}{
+			"method", r.Method,
+			"route", route,
+			"path", r.URL.Path,
+			"status", recorder.Status,
+			"bytes", recorder.Bytes,
+			"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"ratelimit", rateLimitDecision(recorder),
+		}
+		logger.Log(level, "request", append(kv, access.pairs()...)...)
+	})
+}
+
+// rateLimitDecision reads the limiter's outcome from the response: it sets
+// RateLimit-* headers on every request it checks and answers 429 to those
+// it rejects.
+func rateLimitDecision(recorder *httpstatus.Recorder) string {
+	switch {
+	case recorder.Status == http.StatusTooManyRequests:
+		return "rejected"
+	case recorder.Header().Get("RateLimit-Limit") != "":
+		return "allowed"
+	}
+	return "none"
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMiddlewareAccessLine(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, LevelInfo, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	router.Use(logger.Middleware)
+	router.HandleFunc("/configs/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if RequestID(r.Context()) == "" {
+			t.Error("request ID missing from the context")
+		}
+		SetField(r.Context(), "config", mux.Vars(r)["name"])
+		w.Header().Set("RateLimit-Limit", "10")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})
+	router.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+	router.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	tests := []struct {
+		target, requestID string
+		want              map[string]interface{}
+	}{
+		{"/configs/db", "client-id", map[string]interface{}{
+			"level": "info", "route": "/configs/{name}", "path": "/configs/db", "status": 201.0,
+			"bytes": 5.0, "ratelimit": "allowed", "config": "db", "request_id": "client-id",
+		}},
+		{"/fail", "", map[string]interface{}{"level": "info", "route": "/fail", "status": 429.0, "ratelimit": "rejected"}},
+		{"/boom", "", map[string]interface{}{"level": "error", "status": 500.0, "bytes": 0.0, "ratelimit": "none"}},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.requestID != "" {
+			req.Header.Set("X-Request-ID", tt.requestID)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		requestID := rec.Header().Get("X-Request-ID")
+		if requestID == "" || tt.requestID != "" && requestID != tt.requestID {
+			t.Errorf("%s: X-Request-ID = %q, want %q or a generated one", tt.target, requestID, tt.requestID)
+		}
+		var line map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+			t.Fatalf("%s: access line %q: %v", tt.target, out.String(), err)
+		}
+		if line["msg"] != "request" || line["request_id"] != requestID {
+			t.Errorf("%s: access line %v is not tagged with request %s", tt.target, line, requestID)
+		}
+		for key, want := range tt.want {
+			if line[key] != want {
+				t.Errorf("%s: %s = %v, want %v", tt.target, key, line[key], want)
+			}
+		}
+		if strings.Count(out.String(), "\n") != 1 {
+			t.Errorf("%s: logged %q, want one line", tt.target, out.String())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+	fieldsKey
+)
+
+// NewContext returns a context carrying l.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the logger carried by ctx, which includes the request
+// ID for contexts derived from a request, or Default.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerKey).(*Logger); ok {
+		return l
+	}
+	return Default()
+}
+
+// WithRequestID returns a context carrying the ID that correlates a request
+// with its log and audit entries.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// fields collects values that inner middleware and handlers add to the
+// access log line of a request.
+type fields struct {
+	mu   sync.Mutex
+	keys []string
+	m    map[string]interface{}
+}
+
+// SetField sets a field on the access log line of the request ctx belongs
+// to. It does nothing outside requests served by Middleware.
+func SetField(ctx context.Context, key string, value interface{}) {
+	f, ok := ctx.Value(fieldsKey).(*fields)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.m[key]; !exists {
+		f.keys = append(f.keys, key)
+	}
+	f.m[key] = value
+}
+
+func (f *fields) pairs() []interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pairs := make([]interface{}, 0, 2*len(f.keys))
+	for _, key := range f.keys {
+		pairs = append(pairs, key, f.m[key])
+	}
+	return pairs
+}
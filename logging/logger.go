@@ -0,0 +1,183 @@
+// Package logging writes leveled, structured log lines as JSON or as
+// key=value text and carries request-scoped loggers in contexts.
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Logger writes one line per entry: time, level and message followed by
+// the logger's fields and the entry's key-value pairs. Loggers derived with
+// With share the output of their parent.
+type Logger struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	level  Level
+	format string
+	fields []interface{}
+}
+
+func New(out io.Writer, level Level, format string) (*Logger, error) {
+	if format != FormatJSON && format != FormatText {
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	return &Logger{
+		mu:     &sync.Mutex{},
+		out:    out,
+		level:  level,
+		format: format,
+	}, nil
+}
+
+var defaultLogger, _ = New(os.Stderr, LevelInfo, FormatText)
+
+// Default returns the logger used when a context carries none.
+func Default() *Logger {
+	return defaultLogger
+}
+
+// SetDefault replaces the logger returned by Default. It is meant to be
+// called once at startup.
+func SetDefault(l *Logger) {
+	defaultLogger = l
+}
+
+// With returns a logger that adds the key-value pairs kv to every entry.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	child := *l
+	child.fields = append(append([]interface{}(nil), l.fields...), kv...)
+	return &child
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.Log(LevelDebug, msg, kv...) }
+func (l *Logger) Info(msg string, kv ...interface{})  { l.Log(LevelInfo, msg, kv...) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { l.Log(LevelWarn, msg, kv...) }
+func (l *Logger) Error(msg string, kv ...interface{}) { l.Log(LevelError, msg, kv...) }
+
+// Fatal logs at error level and exits the process.
+func (l *Logger) Fatal(msg string, kv ...interface{}) {
+	l.Log(LevelError, msg, kv...)
+	os.Exit(1)
+}
+
+// Log writes an entry if level is enabled. kv alternates keys and values; a
+// trailing key without a value is logged with a null value.
+func (l *Logger) Log(level Level, msg string, kv ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	pairs := append(append([]interface{}{
+		"time", time.Now().UTC().Format(time.RFC3339Nano),
+		"level", level.String(),
+		"msg", msg,
+	}, l.fields...), kv...)
+
+	var buf bytes.Buffer
+	if l.format == FormatJSON {
+		writeJSON(&buf, pairs)
+	} else {
+		writeText(&buf, pairs)
+	}
+	buf.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(buf.Bytes())
+}
+
+// normalize turns values that do not marshal usefully into strings.
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case error:
+		return value.Error()
+	case time.Duration:
+		return value.String()
+	case fmt.Stringer:
+		return value.String()
+	}
+	return v
+}
+
+func writeJSON(buf *bytes.Buffer, pairs []interface{}) {
+	buf.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(fmt.Sprint(pairs[i]))
+		buf.Write(key)
+		buf.WriteByte(':')
+		var value interface{}
+		if i+1 < len(pairs) {
+			value = normalize(pairs[i+1])
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			encoded, _ = json.Marshal(fmt.Sprint(value))
+		}
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+}
+
+func writeText(buf *bytes.Buffer, pairs []interface{}) {
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(fmt.Sprint(pairs[i]))
+		buf.WriteByte('=')
+		value := "null"
+		if i+1 < len(pairs) {
+			value = fmt.Sprint(normalize(pairs[i+1]))
+		}
+		if value == "" || strings.ContainsAny(value, " \"=\t\n") {
+			value = strconv.Quote(value)
+		}
+		buf.WriteString(value)
+	}
+}
@@ -2,115 +2,178 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"projekat/audit"
+	"projekat/auth"
+	"projekat/clientip"
+	"projekat/events"
 	"projekat/handlers"
+	"projekat/health"
+	"projekat/logging"
+	"projekat/metrics"
 	"projekat/model"
+	"projekat/ratelimit"
 	"projekat/repositories"
 	"projekat/services"
+	"projekat/tracing"
+	"projekat/webhooks"
 	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"strings"
-	"sync"
 
 	"github.com/gorilla/mux"
 )
 
-type TokenBucket struct {
-	capacity   int
-	tokens     float64
-	refillRate float64
-	lastRefill time.Time
-	mu         sync.Mutex
-}
-
-func NewTokenBucket(refillRate float64, capacity int) *TokenBucket {
-	return &TokenBucket{
-		capacity:   capacity,
-		tokens:     float64(capacity),
-		refillRate: refillRate,
-		lastRefill: time.Now(),
+// registerRateLimitMetrics exports the bucket stores and rejections of the
+// limiters, labelled with the name each is registered under. Nil limiters
+// are skipped.
+func registerRateLimitMetrics(registry *metrics.Registry, limiters map[string]*ratelimit.Limiter) {
+	each := func(fn func(name string, stats ratelimit.Stats)) {
+		for name, limiter := range limiters {
+			if limiter != nil {
+				fn(name, limiter.Stats())
+			}
+		}
 	}
+	registry.NewGaugeFunc("ars_ratelimit_buckets", "Token buckets currently kept by the rate limiter.", []string{"limiter"},
+		func(emit metrics.Emit) {
+			each(func(name string, stats ratelimit.Stats) {
+				emit(float64(stats.Buckets), name)
+			})
+		})
+	registry.NewCounterFunc("ars_ratelimit_bucket_removals_total", "Buckets dropped because the store was full (lru) or idle (ttl).", []string{"limiter", "reason"},
+		func(emit metrics.Emit) {
+			each(func(name string, stats ratelimit.Stats) {
+				emit(float64(stats.Evicted), name, "lru")
+				emit(float64(stats.Expired), name, "ttl")
+			})
+		})
+	registry.NewCounterFunc("ars_ratelimit_rejections_total", "Requests rejected with 429 by rate limit policy.", []string{"limiter", "policy"},
+		func(emit metrics.Emit) {
+			each(func(name string, stats ratelimit.Stats) {
+				for policy, rejected := range stats.Rejected {
+					emit(float64(rejected), name, policy)
+				}
+			})
+		})
 }
 
-func (tb *TokenBucket) Allow() bool {
-	tb.mu.Lock()
-	defer tb.mu.Unlock()
-
-    now := time.Now()
-    // Discrete refill: add 1 token every 5 seconds
-    elapsed := now.Sub(tb.lastRefill)
-    if elapsed >= 5*time.Second {
-        increments := int(elapsed / (5 * time.Second))
-        tb.tokens += float64(increments)
-        if tb.tokens > float64(tb.capacity) {
-            tb.tokens = float64(tb.capacity)
-        }
-        // Advance lastRefill by the number of full intervals consumed
-        tb.lastRefill = tb.lastRefill.Add(time.Duration(increments) * 5 * time.Second)
-    }
+// registerRepositoryMetrics exports what every namespace stores. The values
+// are read from the repositories on each scrape.
+func registerRepositoryMetrics(registry *metrics.Registry, namespaces services.NamespaceService, configs services.ConfigService, groups services.ConfigGroupService) {
+	ctx := tracing.Untraced(context.Background())
+	// The four gauges share one scan of every namespace per scrape.
+	var counts atomic.Pointer[repositoryCounts]
+	registry.OnScrape(func() {
+		counts.Store(countRepositories(ctx, namespaces, configs, groups))
+	})
+	registry.NewGaugeFunc("ars_configs", "Distinct config names per namespace.", []string{"namespace"},
+		func(emit metrics.Emit) {
+			for namespace, versions := range counts.Load().configs {
+				emit(float64(len(versions)), namespace)
+			}
+		})
+	registry.NewGaugeFunc("ars_config_versions", "Stored versions per config.", []string{"namespace", "name"},
+		func(emit metrics.Emit) {
+			for namespace, versions := range counts.Load().configs {
+				for name, count := range versions {
+					emit(float64(count), namespace, name)
+				}
+			}
+		})
+	registry.NewGaugeFunc("ars_groups", "Distinct config group names per namespace.", []string{"namespace"},
+		func(emit metrics.Emit) {
+			for namespace, versions := range counts.Load().groups {
+				emit(float64(len(versions)), namespace)
+			}
+		})
+	registry.NewGaugeFunc("ars_group_versions", "Stored versions per config group.", []string{"namespace", "name"},
+		func(emit metrics.Emit) {
+			for namespace, versions := range counts.Load().groups {
+				for name, count := range versions {
+					emit(float64(count), namespace, name)
+				}
+			}
+		})
+}
 
-	if tb.tokens >= 1 {
-		tb.tokens -= 1
-		return true
-	}
-	return false
+// repositoryCounts holds the number of stored versions of every config and
+// group name, per namespace. Namespaces that could not be read are left out.
+type repositoryCounts struct {
+	configs map[string]map[string]int
+	groups  map[string]map[string]int
 }
 
-type RateLimiter struct {
-	buckets    sync.Map // key: client identifier -> *TokenBucket
-	refillRate float64
-	capacity   int
+func countRepositories(ctx context.Context, namespaces services.NamespaceService, configs services.ConfigService, groups services.ConfigGroupService) *repositoryCounts {
+	counts := &repositoryCounts{
+		configs: make(map[string]map[string]int),
+		groups:  make(map[string]map[string]int),
+	}
+	all, err := namespaces.GetAll(ctx)
+	if err != nil {
+		return counts
+	}
+	for _, namespace := range all {
+		if stored, err := configs.GetAll(ctx, namespace.Name); err == nil {
+			versions := make(map[string]int)
+			for _, config := range stored {
+				versions[config.Name]++
+			}
+			counts.configs[namespace.Name] = versions
+		}
+		if stored, err := groups.GetAll(ctx, namespace.Name); err == nil {
+			versions := make(map[string]int)
+			for _, group := range stored {
+				versions[group.Name]++
+			}
+			counts.groups[namespace.Name] = versions
+		}
+	}
+	return counts
 }
 
-func NewRateLimiter(rps float64, burst int) *RateLimiter {
-	return &RateLimiter{refillRate: rps, capacity: burst}
+// newRateLimitKey identifies a client for rate limiting: by API key or
+// token subject when authenticated, by IP address otherwise.
+func newRateLimitKey(clientIPs *clientip.Resolver) func(*http.Request) string {
+	return func(r *http.Request) string {
+		if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+			return principal.ID
+		}
+		return clientIPs.ClientIP(r)
+	}
 }
 
-func (rl *RateLimiter) getBucket(key string) *TokenBucket {
-	if v, ok := rl.buckets.Load(key); ok {
-		return v.(*TokenBucket)
+// newIdentityMiddleware records the client address used for audit metadata
+// such as the author of a rollback and names it in the access log.
+func newIdentityMiddleware(clientIPs *clientip.Resolver) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientIP := clientIPs.ClientIP(r)
+			logging.SetField(r.Context(), "client_ip", clientIP)
+			logging.SetField(r.Context(), "identity", clientIP)
+			next.ServeHTTP(w, r.WithContext(handlers.WithIdentity(r.Context(), clientIP)))
+		})
 	}
-	bucket := NewTokenBucket(rl.refillRate, rl.capacity)
-	actual, _ := rl.buckets.LoadOrStore(key, bucket)
-	return actual.(*TokenBucket)
 }
 
-func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+// principalLogMiddleware names the authenticated principal in the access
+// log. It runs after the authenticator.
+func principalLogMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		client := getClientIP(r)
-		bucket := rl.getBucket(client)
-		if !bucket.Allow() {
-			w.Header().Set("Retry-After", "1")
-			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
-			return
+		if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+			logging.SetField(r.Context(), "identity", principal.ID)
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
-func getClientIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		ip := strings.TrimSpace(parts[0])
-		if ip != "" {
-			return ip
-		}
-	}
-	if xr := r.Header.Get("X-Real-IP"); xr != "" {
-		return strings.TrimSpace(xr)
-	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return host
-}
-
 func getEnvFloat(name string, def float64) float64 {
 	v := os.Getenv(name)
 	if v == "" {
@@ -135,22 +198,495 @@ func getEnvInt(name string, def int) int {
 	return i
 }
 
+func getEnvDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+func getEnvString(name string, def string) string {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+func getEnvBool(name string, def bool) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// newAuthenticator returns nil when AUTH_ENABLED is off. Otherwise the
+// bootstrap admin key comes from AUTH_BOOTSTRAP_KEY; if neither that nor any
+// stored key exists, it is read from AUTH_BOOTSTRAP_KEY_FILE, which is
+// created with a random key on first start, so the first keys can be issued
+// without the key ever appearing in the log.
+func newAuthenticator(keys *auth.KeyStore) *auth.Authenticator {
+	if !getEnvBool("AUTH_ENABLED", false) {
+		return nil
+	}
+	bootstrap := getEnvString("AUTH_BOOTSTRAP_KEY", "")
+	if bootstrap == "" && keys.Len() == 0 {
+		file := getEnvString("AUTH_BOOTSTRAP_KEY_FILE", "bootstrap.key")
+		token, created, err := auth.BootstrapToken(file)
+		if err != nil {
+			logging.Default().Fatal("Could not read or create the bootstrap key", "file", file, "error", err)
+		}
+		if created {
+			logging.Default().Warn("No API keys configured, wrote a bootstrap admin key", "file", file)
+		}
+		bootstrap = token
+	}
+	if bootstrap != "" {
+		keys.AddStatic("bootstrap", bootstrap, []string{"*"})
+	}
+	authenticator := auth.NewAuthenticator(keys)
+
+	verifier, err := newJWTVerifier()
+	if err != nil {
+		logging.Default().Fatal("Invalid JWT configuration", "error", err)
+	}
+	if verifier != nil {
+		authenticator.UseJWT(verifier)
+	}
+	return authenticator
+}
+
+// newJWTVerifier returns nil unless JWT_JWKS or JWT_HS256_SECRET is set.
+// Roles and their permissions come from the policy file in RBAC_POLICY_FILE.
+func newJWTVerifier() (*auth.JWTVerifier, error) {
+	source := getEnvString("JWT_JWKS", "")
+	secret := os.Getenv("JWT_HS256_SECRET")
+	if source == "" && secret == "" {
+		return nil, nil
+	}
+
+	policyFile := getEnvString("RBAC_POLICY_FILE", "")
+	if policyFile == "" {
+		return nil, errors.New("RBAC_POLICY_FILE is required when JWT authentication is enabled")
+	}
+	policy, err := auth.LoadPolicy(policyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys *auth.JWKS
+	if source != "" {
+		keys, err = auth.NewJWKS(source)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return auth.NewJWTVerifier(keys, policy, auth.JWTOptions{
+		Issuer:     getEnvString("JWT_ISSUER", ""),
+		Audience:   getEnvString("JWT_AUDIENCE", ""),
+		Leeway:     getEnvDuration("JWT_LEEWAY", 30*time.Second),
+		HMACSecret: []byte(secret),
+	}), nil
+}
+
+func fileOptionsFromEnv() (repositories.FileOptions, error) {
+	policy, err := repositories.ParseFsyncPolicy(getEnvString("WAL_FSYNC", "always"))
+	if err != nil {
+		return repositories.FileOptions{}, err
+	}
+	interval, err := time.ParseDuration(getEnvString("WAL_FSYNC_INTERVAL", "1s"))
+	if err != nil {
+		return repositories.FileOptions{}, err
+	}
+	return repositories.FileOptions{
+		Dir:           getEnvString("DATA_DIR", "./data"),
+		Fsync:         policy,
+		FsyncInterval: interval,
+		SnapshotEvery: getEnvInt("SNAPSHOT_EVERY", 1000),
+	}, nil
+}
+
+// closeRepository releases repositories that hold resources such as open
+// log files.
+func closeRepository(repo interface{}) {
+	if closer, ok := repo.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logging.Default().Error("Error closing repository", "error", err)
+		}
+	}
+}
+
+// newRepositories builds the config, group and namespace repositories for
+// the backend selected through REPOSITORY_BACKEND. Config and group
+// repositories share the given change index.
+func newRepositories(index *repositories.ChangeIndex) (model.ConfigRepository, model.ConfigGroupRepository, model.NamespaceRepository) {
+	backend := getEnvString("REPOSITORY_BACKEND", "inmem")
+	switch backend {
+	case "inmem":
+		return repositories.NewConfigInMemRepository(index), repositories.NewConfigGroupInMemRepository(index), repositories.NewNamespaceInMemRepository()
+	case "file":
+		opts, err := fileOptionsFromEnv()
+		if err != nil {
+			logging.Default().Fatal("Invalid file backend configuration", "error", err)
+		}
+		logging.Default().Info("Using file repository backend", "dir", opts.Dir)
+		configRepo, err := repositories.NewConfigFileRepository(opts, index)
+		if err != nil {
+			logging.Default().Fatal("Could not open config repository", "error", err)
+		}
+		groupRepo, err := repositories.NewConfigGroupFileRepository(opts, index)
+		if err != nil {
+			logging.Default().Fatal("Could not open config group repository", "error", err)
+		}
+		namespaceRepo, err := repositories.NewNamespaceFileRepository(opts)
+		if err != nil {
+			logging.Default().Fatal("Could not open namespace repository", "error", err)
+		}
+		return configRepo, groupRepo, namespaceRepo
+	case "consul":
+		address := getEnvString("CONSUL_ADDR", "http://localhost:8500")
+		logging.Default().Info("Using Consul repository backend", "address", address)
+		return repositories.NewConfigConsulRepository(address, index), repositories.NewConfigGroupConsulRepository(address, index), repositories.NewNamespaceConsulRepository(address)
+	default:
+		logging.Default().Fatal("Unknown REPOSITORY_BACKEND", "backend", backend)
+		return nil, nil, nil
+	}
+}
+
+// registerResourceRoutes registers the config, group, search and usage routes
+// on r. They are mounted both at the root, for the default namespace, and
+// under /namespaces/{namespace}.
+func registerResourceRoutes(r *mux.Router, configHandler handlers.ConfigHandler, groupHandler handlers.ConfigGroupHandler, searchHandler handlers.SearchHandler, quotaHandler handlers.QuotaHandler, blocking handlers.BlockingQuery, deadline handlers.Deadline) {
+	// Every handler runs under the request deadline. Blocking reads wait
+	// for a change first and the deadline starts once they are served.
+	timed := deadline.Wrap
+	blockingRead := func(h http.HandlerFunc) http.HandlerFunc { return blocking.Wrap(deadline.Wrap(h)) }
+
+	r.HandleFunc("/configs", blockingRead(configHandler.GetAll)).Methods("GET")
+	r.HandleFunc("/configs", timed(configHandler.Create)).Methods("POST")
+	// "latest" and "diff" must be registered before the numeric version route
+	r.HandleFunc("/configs/{name}/latest", blockingRead(configHandler.GetLatest)).Methods("GET")
+	r.HandleFunc("/configs/{name}/diff", blockingRead(configHandler.Diff)).Methods("GET")
+	r.HandleFunc("/configs/{name}", blockingRead(configHandler.GetVersions)).Methods("GET")
+	r.HandleFunc("/configs/{name}/rollback", timed(configHandler.Rollback)).Methods("POST")
+	r.HandleFunc("/configs/{name}/{version}", blockingRead(configHandler.Get)).Methods("GET")
+	r.HandleFunc("/configs/{name}/{version}", timed(configHandler.Delete)).Methods("DELETE")
+	
+	r.HandleFunc("/groups", blockingRead(groupHandler.GetAll)).Methods("GET")
+	r.HandleFunc("/groups", timed(groupHandler.Create)).Methods("POST")
+	r.HandleFunc("/groups/{name}/latest", blockingRead(groupHandler.GetLatest)).Methods("GET")
+	r.HandleFunc("/groups/{name}/diff", blockingRead(groupHandler.Diff)).Methods("GET")
+	r.HandleFunc("/groups/{name}", blockingRead(groupHandler.GetVersions)).Methods("GET")
+	r.HandleFunc("/groups/{name}/rollback", timed(groupHandler.Rollback)).Methods("POST")
+	r.HandleFunc("/groups/{name}/{version}", blockingRead(groupHandler.Get)).Methods("GET")
+	r.HandleFunc("/groups/{name}/{version}", timed(groupHandler.Delete)).Methods("DELETE")
+	
+	r.HandleFunc("/groups/{name}/{version}/configs", timed(groupHandler.AddConfig)).Methods("POST")
+	r.HandleFunc("/groups/{name}/{version}/configs/{configName}", blockingRead(groupHandler.GetConfig)).Methods("GET")
+	r.HandleFunc("/groups/{name}/{version}/configs/{configName}", timed(groupHandler.RemoveConfig)).Methods("DELETE")
+	// labels-based operations
+	r.HandleFunc("/groups/{name}/{version}/configs", blockingRead(groupHandler.GetConfigsByLabels)).Methods("GET").Queries("labels", "{labels}")
+	r.HandleFunc("/groups/{name}/{version}/configs", timed(groupHandler.DeleteConfigsByLabels)).Methods("DELETE").Queries("labels", "{labels}")
+
+	r.HandleFunc("/search", timed(searchHandler.Search)).Methods("GET")
+	r.HandleFunc("/usage", timed(quotaHandler.Usage)).Methods("GET")
+}
+
+// newLogger builds the process logger from LOG_LEVEL and LOG_FORMAT.
+func newLogger() *logging.Logger {
+	level, err := logging.ParseLevel(getEnvString("LOG_LEVEL", "info"))
+	if err != nil {
+		logging.Default().Fatal("Invalid LOG_LEVEL", "error", err)
+	}
+	logger, err := logging.New(os.Stdout, level, getEnvString("LOG_FORMAT", logging.FormatJSON))
+	if err != nil {
+		logging.Default().Fatal("Invalid LOG_FORMAT", "error", err)
+	}
+	return logger
+}
+
+// newTracer builds the tracer selected through TRACING_EXPORTER, or returns
+// nil when tracing is off.
+func newTracer() *tracing.Tracer {
+	var exporter tracing.Exporter
+	switch name := getEnvString("TRACING_EXPORTER", "none"); name {
+	case "none":
+		return nil
+	case "otlp":
+		endpoint := getEnvString("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+		exporter = tracing.NewOTLPExporter(endpoint, parseHeaders(getEnvString("OTEL_EXPORTER_OTLP_HEADERS", "")))
+		logging.Default().Info("Exporting traces over OTLP/HTTP", "endpoint", endpoint)
+	case "file":
+		path := getEnvString("TRACING_FILE", "traces.jsonl")
+		fileExporter, err := tracing.NewFileExporter(path)
+		if err != nil {
+			logging.Default().Fatal("Could not open trace file", "error", err)
+		}
+		exporter = fileExporter
+		logging.Default().Info("Exporting traces to file", "path", path)
+	default:
+		logging.Default().Fatal("Unknown TRACING_EXPORTER", "exporter", name)
+	}
+	return tracing.New(exporter, tracing.Options{
+		ServiceName:   getEnvString("OTEL_SERVICE_NAME", "ars"),
+		SampleRatio:   getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+		FlushInterval: getEnvDuration("TRACING_FLUSH_INTERVAL", 5*time.Second),
+	})
+}
+
+// parseHeaders reads a comma-separated list of key=value pairs, the format
+// of OTEL_EXPORTER_OTLP_HEADERS.
+func parseHeaders(s string) http.Header {
+	headers := http.Header{}
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(key) != "" {
+			headers.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+	return headers
+}
+
+// registerTracingMetrics exports what became of the recorded spans.
+func registerTracingMetrics(registry *metrics.Registry, tracer *tracing.Tracer) {
+	registry.NewCounterFunc("ars_tracing_spans_total", "Finished spans by outcome: exported, failed (export error) or dropped (queue full).", []string{"outcome"},
+		func(emit metrics.Emit) {
+			stats := tracer.Stats()
+			emit(float64(stats.Exported), "exported")
+			emit(float64(stats.Failed), "failed")
+			emit(float64(stats.Dropped), "dropped")
+		})
+}
+
+// commit and buildTime are set at build time:
+//
+//	go build -ldflags "-X main.commit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	commit    string
+	buildTime string
+)
+
+// enabledBackends lists the storage, tracing, audit and authentication
+// backends selected through the environment, for /version.
+func enabledBackends() map[string]string {
+	audit := "memory"
+	if getEnvString("AUDIT_LOG_FILE", "") != "" {
+		audit = "file"
+	}
+	authBackend := "none"
+	if getEnvBool("AUTH_ENABLED", false) {
+		authBackend = "apikey"
+		if getEnvString("JWT_JWKS", "") != "" || os.Getenv("JWT_HS256_SECRET") != "" {
+			authBackend = "apikey,jwt"
+		}
+	}
+	return map[string]string{
+		"repository": getEnvString("REPOSITORY_BACKEND", "inmem"),
+		"tracing":    getEnvString("TRACING_EXPORTER", "none"),
+		"audit":      audit,
+		"auth":       authBackend,
+	}
+}
+
+// startupHandler serves the probes from the moment the server listens and
+// hands every request to the router once it is installed. Until then, while
+// the repositories are opened and their logs replayed, other requests get
+// 503.
+type startupHandler struct {
+	probes *http.ServeMux
+	router atomic.Value
+}
+
+func newStartupHandler(checker *health.Checker, info health.BuildInfo) *startupHandler {
+	probes := http.NewServeMux()
+	probes.HandleFunc("/healthz", checker.ServeLive)
+	probes.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		// Probes arrive every few seconds; keep their backend calls out of traces.
+		checker.ServeReady(w, r.WithContext(tracing.Untraced(r.Context())))
+	})
+	probes.Handle("/version", info)
+	probes.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "server is starting", http.StatusServiceUnavailable)
+	})
+	return &startupHandler{probes: probes}
+}
+
+// setRouter starts routing API requests; the probes keep being served
+// directly so they bypass authentication, rate limiting and access logs.
+func (h *startupHandler) setRouter(router http.Handler) {
+	h.router.Store(router)
+}
+
+func (h *startupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/healthz", "/readyz", "/version":
+		h.probes.ServeHTTP(w, r)
+		return
+	}
+	if router, ok := h.router.Load().(http.Handler); ok {
+		router.ServeHTTP(w, r)
+		return
+	}
+	h.probes.ServeHTTP(w, r)
+}
+
 func main() {
+	logger := newLogger()
+	logging.SetDefault(logger)
+
+	tracer := newTracer()
+	tracerCtx, stopTracer := context.WithCancel(context.Background())
+	tracerDone := make(chan struct{})
+	if tracer != nil {
+		tracing.SetDefault(tracer)
+		go func() {
+			tracer.Run(tracerCtx)
+			close(tracerDone)
+		}()
+	} else {
+		close(tracerDone)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	// The server listens before the repositories are opened so that
+	// /healthz and /readyz answer while a large log is being replayed.
+	checker := health.NewChecker(getEnvDuration("READY_CHECK_TIMEOUT", 2*time.Second))
+	app := newStartupHandler(checker, health.NewBuildInfo(commit, buildTime, enabledBackends()))
+
+	// Request contexts derive from baseCtx so blocking queries and watch
+	// streams are released as soon as shutdown begins.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	server := &http.Server{
+		Addr:        "0.0.0.0:8000",
+		Handler:     app,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	server.RegisterOnShutdown(cancelRequests)
+
+	go func() {
+		logger.Info("Starting server", "addr", ":8000")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Could not listen on :8000", "error", err)
+		}
+	}()
+
+	clientIPs, err := clientip.NewResolver(strings.Split(getEnvString("TRUSTED_PROXIES", ""), ","))
+	if err != nil {
+		logger.Fatal("Invalid TRUSTED_PROXIES", "error", err)
+	}
+
 	rps := getEnvFloat("RATE_LIMIT_RPS", 5)
 	burst := getEnvInt("RATE_LIMIT_BURST", 10)
-	limiter := NewRateLimiter(rps, burst)
+	var policies []ratelimit.Policy
+	if file := getEnvString("RATE_LIMIT_POLICY_FILE", ""); file != "" {
+		var err error
+		policies, err = ratelimit.LoadPolicies(file)
+		if err != nil {
+			logger.Fatal("Could not load rate limit policies", "error", err)
+		}
+	}
+	limiterOpts := ratelimit.Options{
+		MaxBuckets: getEnvInt("RATE_LIMIT_MAX_BUCKETS", 100000),
+		IdleTTL:    getEnvDuration("RATE_LIMIT_BUCKET_TTL", 10*time.Minute),
+	}
+	limiter, err := ratelimit.New(ratelimit.Limit{Rate: rps, Burst: burst}, policies, newRateLimitKey(clientIPs), limiterOpts)
+	if err != nil {
+		logger.Fatal("Invalid rate limit", "error", err)
+	}
+	limiterCtx, stopLimiter := context.WithCancel(context.Background())
+	go limiter.Run(limiterCtx)
+	limiter.Exempt("/watch")
+	limiter.Exempt("/metrics")
 
-	configRepo := repositories.NewConfigInMemRepository()
-	groupRepo := repositories.NewConfigGroupInMemRepository()
+	changeIndex := repositories.NewChangeIndex()
+	configRepo, groupRepo, namespaceRepo := newRepositories(changeIndex)
+	checker.RegisterFrom(configRepo, groupRepo, namespaceRepo)
+	if tracer != nil {
+		backend := getEnvString("REPOSITORY_BACKEND", "inmem")
+		configRepo = repositories.NewTracedConfigRepository(configRepo, backend)
+		groupRepo = repositories.NewTracedConfigGroupRepository(groupRepo, backend)
+		namespaceRepo = repositories.NewTracedNamespaceRepository(namespaceRepo, backend)
+	}
 	
-	configService := services.NewConfigService(configRepo)
-	groupService := services.NewConfigGroupService(groupRepo)
+	broker := events.NewBroker(getEnvInt("WATCH_BUFFER_SIZE", 1000))
+
+	webhookManager := webhooks.NewManager(broker, webhooks.Options{
+		MaxAttempts:    getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		InitialBackoff: getEnvDuration("WEBHOOK_INITIAL_BACKOFF", time.Second),
+		QueueSize:      getEnvInt("WEBHOOK_QUEUE_SIZE", 1000),
+	})
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	webhooksDone := make(chan struct{})
+	go func() {
+		webhookManager.Run(webhookCtx)
+		close(webhooksDone)
+	}()
+
+	auditLog := audit.NewLog(getEnvInt("AUDIT_MAX_ENTRIES", 10000))
+	if path := getEnvString("AUDIT_LOG_FILE", ""); path != "" {
+		if err := auditLog.OpenFile(path); err != nil {
+			logger.Fatal("Could not open audit log", "error", err)
+		}
+	}
+
+	keyStore, err := auth.NewKeyStore(getEnvString("API_KEYS_FILE", ""))
+	if err != nil {
+		logger.Fatal("Could not load API keys", "error", err)
+	}
+	authenticator := newAuthenticator(keyStore)
+
+	// With authentication on, clients are first limited by address so that
+	// requests with bad credentials are throttled before they are checked;
+	// the limiter above then applies per API key or token subject.
+	var ipLimiter *ratelimit.Limiter
+	if authenticator != nil {
+		ipLimit := ratelimit.Limit{
+			Rate:  getEnvFloat("RATE_LIMIT_IP_RPS", 4*rps),
+			Burst: getEnvInt("RATE_LIMIT_IP_BURST", 4*burst),
+		}
+		ipLimiter, err = ratelimit.New(ipLimit, nil, clientIPs.ClientIP, limiterOpts)
+		if err != nil {
+			logger.Fatal("Invalid per-address rate limit", "error", err)
+		}
+		go ipLimiter.Run(limiterCtx)
+		ipLimiter.Exempt("/watch")
+		ipLimiter.Exempt("/metrics")
+	}
+
+	namespaceService := services.NewNamespaceService(namespaceRepo, configRepo, groupRepo)
+	if err := namespaceService.EnsureDefault(context.Background()); err != nil {
+		logger.Fatal("Could not create the default namespace", "error", err)
+	}
+	quotaPolicy := model.QuotaPolicy{}
+	if file := getEnvString("QUOTAS_FILE", ""); file != "" {
+		quotaPolicy, err = services.LoadQuotaPolicy(file)
+		if err != nil {
+			logger.Fatal("Could not load quotas", "error", err)
+		}
+	}
+	quotaService := services.NewQuotaService(quotaPolicy, namespaceService, configRepo, groupRepo)
+	configService := services.NewConfigService(configRepo, namespaceService, quotaService, broker)
+	groupService := services.NewConfigGroupService(groupRepo, namespaceService, quotaService, broker)
 	
 	config := model.NewConfig("db_config", 2)
 	config.AddParameter("username", "pera")
 	config.AddParameter("port", "5432")
 	config.AddParameter("host", "localhost")
-	_ = configService.Add(config)
+	config.AddLabel("team", "backend")
+	_, _ = configService.Add(context.Background(), config)
 	
 	group := model.NewConfigGroup("web_configs", 1)
 	
@@ -161,55 +697,99 @@ func main() {
 	webConfig.AddLabel("team", "backend")
 	
 	group.AddConfig(webConfig)
-	_ = groupService.Add(group)
+	_, _ = groupService.Add(context.Background(), group)
 	
-	configHandler := handlers.NewConfigHandler(configService)
-	groupHandler := handlers.NewConfigGroupHandler(groupService)
+	configHandler := handlers.NewConfigHandler(configService, auditLog)
+	groupHandler := handlers.NewConfigGroupHandler(groupService, auditLog)
+	auditHandler := handlers.NewAuditHandler(auditLog)
+	namespaceHandler := handlers.NewNamespaceHandler(namespaceService)
+	quotaHandler := handlers.NewQuotaHandler(quotaService)
+	keyHandler := handlers.NewKeyHandler(keyStore)
+	searchHandler := handlers.NewSearchHandler(configService, groupService)
+	watchHandler := handlers.NewWatchHandler(broker)
+	webhookHandler := handlers.NewWebhookHandler(webhookManager)
+	blocking := handlers.NewBlockingQuery(changeIndex)
+	deadline := handlers.NewDeadline(getEnvDuration("REQUEST_TIMEOUT", 30*time.Second))
 	
+	registry := metrics.NewRegistry()
+	registerRateLimitMetrics(registry, map[string]*ratelimit.Limiter{"client": limiter, "ip": ipLimiter})
+	registerRepositoryMetrics(registry, namespaceService, configService, groupService)
+	if tracer != nil {
+		registerTracingMetrics(registry, tracer)
+	}
+
 	router := mux.NewRouter()
+	router.Use(metrics.NewHTTPMetrics(registry).Middleware)
+	router.Use(logger.Middleware)
+	if tracer != nil {
+		router.Use(tracer.Middleware)
+	}
+	router.Use(newIdentityMiddleware(clientIPs))
+	if authenticator != nil {
+		router.Use(ipLimiter.Middleware)
+		router.Use(authenticator.Middleware)
+		router.Use(principalLogMiddleware)
+	}
 	router.Use(limiter.Middleware)
 	
-	router.HandleFunc("/configs", configHandler.GetAll).Methods("GET")
-	router.HandleFunc("/configs", configHandler.Create).Methods("POST")
-	router.HandleFunc("/configs/{name}/{version}", configHandler.Get).Methods("GET")
-	router.HandleFunc("/configs/{name}/{version}", configHandler.Delete).Methods("DELETE")
-	
-	router.HandleFunc("/groups", groupHandler.GetAll).Methods("GET")
-	router.HandleFunc("/groups", groupHandler.Create).Methods("POST")
-	router.HandleFunc("/groups/{name}/{version}", groupHandler.Get).Methods("GET")
-	router.HandleFunc("/groups/{name}/{version}", groupHandler.Delete).Methods("DELETE")
-	
-	router.HandleFunc("/groups/{name}/{version}/configs", groupHandler.AddConfig).Methods("POST")
-	router.HandleFunc("/groups/{name}/{version}/configs/{configName}", groupHandler.GetConfig).Methods("GET")
-	router.HandleFunc("/groups/{name}/{version}/configs/{configName}", groupHandler.RemoveConfig).Methods("DELETE")
-	// labels-based operations
-	router.HandleFunc("/groups/{name}/{version}/configs", groupHandler.GetConfigsByLabels).Methods("GET").Queries("labels", "{labels}")
-	router.HandleFunc("/groups/{name}/{version}/configs", groupHandler.DeleteConfigsByLabels).Methods("DELETE").Queries("labels", "{labels}")
+	registerResourceRoutes(router, configHandler, groupHandler, searchHandler, quotaHandler, blocking, deadline)
 
-	server := &http.Server{
-		Addr:    "0.0.0.0:8000",
-		Handler: router,
-	}
+	router.HandleFunc("/namespaces", deadline.Wrap(namespaceHandler.GetAll)).Methods("GET")
+	router.HandleFunc("/namespaces", deadline.Wrap(namespaceHandler.Create)).Methods("POST")
+	router.HandleFunc("/namespaces/{namespace}", deadline.Wrap(namespaceHandler.Get)).Methods("GET")
+	router.HandleFunc("/namespaces/{namespace}", deadline.Wrap(namespaceHandler.Delete)).Methods("DELETE")
+	registerResourceRoutes(router.PathPrefix("/namespaces/{namespace}").Subrouter(), configHandler, groupHandler, searchHandler, quotaHandler, blocking, deadline)
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	router.HandleFunc("/watch", watchHandler.Watch).Methods("GET")
+	router.HandleFunc("/audit", auditHandler.GetAll).Methods("GET")
+	router.Handle("/metrics", registry).Methods("GET")
 
-	go func() {
-		log.Println("Starting server on :8000")
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Could not listen on :8000: %v\n", err)
-		}
-	}()
+	router.HandleFunc("/admin/keys", keyHandler.GetAll).Methods("GET")
+	router.HandleFunc("/admin/keys", keyHandler.Create).Methods("POST")
+	router.HandleFunc("/admin/keys/{id}", keyHandler.Delete).Methods("DELETE")
+
+	router.HandleFunc("/webhooks", webhookHandler.GetAll).Methods("GET")
+	router.HandleFunc("/webhooks", webhookHandler.Create).Methods("POST")
+	router.HandleFunc("/webhooks/{id}", webhookHandler.Get).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", webhookHandler.Delete).Methods("DELETE")
+	router.HandleFunc("/webhooks/{id}/deliveries", webhookHandler.GetDeliveries).Methods("GET")
+
+	server.RegisterOnShutdown(broker.Close)
+	app.setRouter(router)
+	checker.MarkStarted()
+	logger.Info("Server ready")
 
 	<-stop
-	log.Println("Shutting down server...")
+	// Fail readiness first so load balancers stop sending new requests,
+	// and give them SHUTDOWN_DRAIN_DELAY to notice before closing.
+	checker.MarkDraining()
+	logger.Info("Shutting down server")
+	time.Sleep(getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		logger.Fatal("Server forced to shutdown", "error", err)
+	}
+
+	stopWebhooks()
+	<-webhooksDone
+	stopLimiter()
+
+	closeRepository(configRepo)
+	closeRepository(groupRepo)
+	closeRepository(namespaceRepo)
+	if err := auditLog.Close(); err != nil {
+		logger.Error("Error closing audit log", "error", err)
+	}
+	stopTracer()
+	<-tracerDone
+	if tracer != nil {
+		if err := tracer.Close(); err != nil {
+			logger.Error("Error closing trace exporter", "error", err)
+		}
 	}
 
-	log.Println("Server gracefully stopped")
+	logger.Info("Server gracefully stopped")
 }
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"net/http"
+	"projekat/httpstatus"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// HTTPMetrics counts requests and their latency by method, route template
+// and status code.
+type HTTPMetrics struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+func NewHTTPMetrics(r *Registry) HTTPMetrics {
+	return HTTPMetrics{
+		requests: r.NewCounterVec("ars_http_requests_total",
+			"HTTP requests by method, route template and status code.", "method", "route", "code"),
+		duration: r.NewHistogramVec("ars_http_request_duration_seconds",
+			"HTTP request latency by method and route template.", DefaultBuckets, "method", "route"),
+	}
+}
+
+// Middleware must be installed with Router.Use so the matched route is
+// known. Using the route template keeps the number of series bounded.
+func (m HTTPMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := httpstatus.NewRecorder(w)
+		next.ServeHTTP(recorder, r)
+
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		m.requests.Inc(r.Method, route, strconv.Itoa(recorder.Status))
+		m.duration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	return rec.Body.String()
+}
+
+func TestMiddlewareCountsByRouteTemplate(t *testing.T) {
+	registry := NewRegistry()
+	router := mux.NewRouter()
+	router.Use(NewHTTPMetrics(registry).Middleware)
+	router.HandleFunc("/configs/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["name"] == "missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	for _, target := range []string{"/configs/a", "/configs/b", "/configs/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	body := scrape(t, registry)
+	for _, line := range []string{
+		"# TYPE ars_http_requests_total counter\n",
+		`ars_http_requests_total{method="GET",route="/configs/{name}",code="200"} 2` + "\n",
+		`ars_http_requests_total{method="GET",route="/configs/{name}",code="404"} 1` + "\n",
+		"# TYPE ars_http_request_duration_seconds histogram\n",
+		`ars_http_request_duration_seconds_bucket{method="GET",route="/configs/{name}",le="+Inf"} 3` + "\n",
+		`ars_http_request_duration_seconds_count{method="GET",route="/configs/{name}"} 3` + "\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("exposition lacks %q:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "/configs/a") {
+		t.Error("exposition has a series per path instead of per route template")
+	}
+}
+
+func TestRegistryExposition(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounterVec("test_total", "A counter\nwith two lines.", "name")
+	counter.Add(2, `quote"d`)
+	counter.Inc("b")
+	histogram := registry.NewHistogramVec("test_seconds", "A histogram.", []float64{1, 5})
+	histogram.Observe(0.5)
+	histogram.Observe(3)
+	histogram.Observe(7)
+	scrapes := 0
+	registry.OnScrape(func() { scrapes++ })
+	registry.NewGaugeFunc("test_items", "A gauge.", []string{"kind"}, func(emit Emit) {
+		emit(float64(scrapes), "configs")
+	})
+
+	want := `# HELP test_total A counter\nwith two lines.
+# TYPE test_total counter
+test_total{name="b"} 1
+test_total{name="quote\"d"} 2
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="1"} 1
+test_seconds_bucket{le="5"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 10.5
+test_seconds_count 3
+# HELP test_items A gauge.
+# TYPE test_items gauge
+test_items{kind="configs"} 1
+`
+	if got := scrape(t, registry); got != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", got, want)
+	}
+}
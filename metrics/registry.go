@@ -0,0 +1,188 @@
+// Package metrics implements the subset of Prometheus metric types and the
+// text exposition format the server needs, without extra dependencies.
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// sample is one line of a metric family: name suffix, labels and value.
+type sample struct {
+	suffix string
+	labels []string // alternating names and values
+	value  float64
+}
+
+// family is a named metric whose samples are produced at scrape time.
+type family struct {
+	name    string
+	help    string
+	kind    string
+	collect func() []sample
+}
+
+// Registry holds metric families and serves them in the Prometheus text
+// format.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	hooks    []func()
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(f *family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families = append(r.families, f)
+}
+
+// OnScrape registers fn to run at the start of every scrape, before any
+// family is collected, so several function metrics can share one pass over
+// their source.
+func (r *Registry) OnScrape(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, fn)
+}
+
+// Emit records one value of a collected metric with label values in the
+// order the labels were declared.
+type Emit func(value float64, labelValues ...string)
+
+// NewGaugeFunc registers a gauge whose values are read by fn on every
+// scrape.
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, fn func(emit Emit)) {
+	r.newFunc(name, help, typeGauge, labels, fn)
+}
+
+// NewCounterFunc registers a counter whose values are read by fn on every
+// scrape, for counts kept elsewhere.
+func (r *Registry) NewCounterFunc(name, help string, labels []string, fn func(emit Emit)) {
+	r.newFunc(name, help, typeCounter, labels, fn)
+}
+
+func (r *Registry) newFunc(name, help, kind string, labels []string, fn func(emit Emit)) {
+	r.register(&family{
+		name: name,
+		help: help,
+		kind: kind,
+		collect: func() []sample {
+			var samples []sample
+			fn(func(value float64, labelValues ...string) {
+				samples = append(samples, sample{labels: pairLabels(labels, labelValues), value: value})
+			})
+			return samples
+		},
+	})
+}
+
+// pairLabels zips label names with values into alternating pairs.
+func pairLabels(names, values []string) []string {
+	pairs := make([]string, 0, 2*len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, name, value)
+	}
+	return pairs
+}
+
+// ServeHTTP writes every family in the text exposition format, families in
+// registration order and samples sorted by labels.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	hooks := append([]func(){}, r.hooks...)
+	r.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out := bufio.NewWriter(w)
+	for _, f := range families {
+		samples := f.collect()
+		sort.SliceStable(samples, func(i, j int) bool {
+			return labelKey(samples[i].labels) < labelKey(samples[j].labels)
+		})
+		out.WriteString("# HELP " + f.name + " " + escapeHelp(f.help) + "\n")
+		out.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+		for _, s := range samples {
+			out.WriteString(f.name + s.suffix)
+			writeLabels(out, s.labels)
+			out.WriteString(" " + formatValue(s.value) + "\n")
+		}
+	}
+	out.Flush()
+}
+
+func writeLabels(out *bufio.Writer, labels []string) {
+	if len(labels) == 0 {
+		return
+	}
+	out.WriteByte('{')
+	for i := 0; i < len(labels); i += 2 {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		out.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+	}
+	out.WriteByte('}')
+}
+
+// labelKey orders the samples of a family. "le" is left out so the stable
+// sort keeps each histogram's buckets, sum and count in emission order.
+func labelKey(labels []string) string {
+	var b strings.Builder
+	for i := 0; i < len(labels); i += 2 {
+		if labels[i] == "le" {
+			continue
+		}
+		b.WriteString(labels[i+1])
+		b.WriteByte(0xff)
+	}
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
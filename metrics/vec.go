@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency buckets in seconds, matching the Prometheus
+// client defaults.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// vec keeps one series per combination of label values.
+type vec struct {
+	mu     sync.Mutex
+	labels []string
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64  // counter value, or histogram sum
+	count       uint64   // histogram observations
+	buckets     []uint64 // histogram counts per upper bound, not cumulative
+}
+
+func newVec(labels []string) vec {
+	return vec{labels: labels, series: make(map[string]*series)}
+}
+
+// with returns the series for labelValues, creating it if needed. The
+// caller holds v.mu.
+func (v *vec) with(labelValues []string, buckets int) *series {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...), buckets: make([]uint64, buckets)}
+		v.series[key] = s
+	}
+	return s
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	vec
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vec: newVec(labels)}
+	r.register(&family{name: name, help: help, kind: typeCounter, collect: c.collect})
+	return c
+}
+
+// Add increases the counter of labelValues by delta, which must not be
+// negative.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.with(labelValues, 0).value += delta
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) collect() []sample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	samples := make([]sample, 0, len(c.series))
+	for _, s := range c.series {
+		samples = append(samples, sample{labels: pairLabels(c.labels, s.labelValues), value: s.value})
+	}
+	return samples
+}
+
+// HistogramVec counts observations into buckets, partitioned by labels.
+type HistogramVec struct {
+	vec
+	upperBounds []float64
+}
+
+// NewHistogramVec registers a histogram with the given ascending bucket
+// upper bounds; +Inf is implied.
+func (r *Registry) NewHistogramVec(name, help string, upperBounds []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{vec: newVec(labels), upperBounds: upperBounds}
+	r.register(&family{name: name, help: help, kind: typeHistogram, collect: h.collect})
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.with(labelValues, len(h.upperBounds))
+	for i, bound := range h.upperBounds {
+		if value <= bound {
+			s.buckets[i]++
+			break
+		}
+	}
+	s.value += value
+	s.count++
+}
+
+func (h *HistogramVec) collect() []sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	samples := make([]sample, 0, len(h.series)*(len(h.upperBounds)+3))
+	for _, s := range h.series {
+		labels := pairLabels(h.labels, s.labelValues)
+		var cumulative uint64
+		for i, bound := range h.upperBounds {
+			cumulative += s.buckets[i]
+			samples = append(samples, sample{
+				suffix: "_bucket",
+				labels: append(labels[:len(labels):len(labels)], "le", formatValue(bound)),
+				value:  float64(cumulative),
+			})
+		}
+		samples = append(samples,
+			sample{suffix: "_bucket", labels: append(labels[:len(labels):len(labels)], "le", "+Inf"), value: float64(s.count)},
+			sample{suffix: "_sum", labels: labels, value: s.value},
+			sample{suffix: "_count", labels: labels, value: float64(s.count)},
+		)
+	}
+	return samples
+}
@@ -1,5 +1,10 @@
 package model
 
+import (
+	"context"
+	"time"
+)
+
 type ConfigParameter struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
@@ -11,9 +16,13 @@ type Label struct {
 }
 
 type Config struct {
+	Namespace  string            `json:"namespace"`
 	Name       string            `json:"name"`
 	Version    int               `json:"version"`
 	Parameters []ConfigParameter `json:"parameters"`
+	Labels     []Label           `json:"labels"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
 }
 
 type GroupConfig struct {
@@ -23,21 +32,77 @@ type GroupConfig struct {
 }
 
 type ConfigGroup struct {
+	Namespace    string        `json:"namespace"`
 	Name         string        `json:"name"`
 	Version      int           `json:"version"`
 	Configs      []GroupConfig `json:"configs"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// GroupConfigMatch is a config found inside a specific group version.
+type GroupConfigMatch struct {
+	Group        string      `json:"group"`
+	GroupVersion int         `json:"groupVersion"`
+	Config       GroupConfig `json:"config"`
 }
 
+// Metadata keys recorded on versions created by a rollback.
+const (
+	MetadataRollbackOf = "rollbackOf"
+	MetadataAuthor     = "author"
+	MetadataReason     = "reason"
+)
+
+// MetadataCreatedBy records the principal that stored a version, when
+// authentication is enabled.
+const MetadataCreatedBy = "createdBy"
+
+// ConfigRepository stores config versions. Names are unique per namespace;
+// Add stores the config in its Namespace field.
 type ConfigRepository interface {
-	Add(config Config) error
-	Get(name string, version int) (Config, error)
-	GetAll() ([]Config, error)
-	Delete(name string, version int) error
+	Add(ctx context.Context, config Config) error
+	Get(ctx context.Context, namespace, name string, version int) (Config, error)
+	// GetAll returns every config version in the namespace.
+	GetAll(ctx context.Context, namespace string) ([]Config, error)
+	// GetVersions returns every version of the named config, newest first.
+	GetVersions(ctx context.Context, namespace, name string) ([]Config, error)
+	// Search returns every config version in the namespace whose labels
+	// match the selector.
+	Search(ctx context.Context, namespace string, selector Selector) ([]Config, error)
+	Delete(ctx context.Context, namespace, name string, version int) error
+	// HighestVersion returns the highest version of the named config ever
+	// stored, including deleted ones, or 0 if there was none.
+	HighestVersion(ctx context.Context, namespace, name string) (int, error)
 }
 
 type ConfigGroupRepository interface {
-	Add(group ConfigGroup) error
-	Get(name string, version int) (ConfigGroup, error)
-	GetAll() ([]ConfigGroup, error)
-	Delete(name string, version int) error
+	Add(ctx context.Context, group ConfigGroup) error
+	Get(ctx context.Context, namespace, name string, version int) (ConfigGroup, error)
+	GetAll(ctx context.Context, namespace string) ([]ConfigGroup, error)
+	// GetVersions returns every version of the named group, newest first.
+	GetVersions(ctx context.Context, namespace, name string) ([]ConfigGroup, error)
+	// Search returns every config of every group version in the namespace
+	// whose labels match the selector.
+	Search(ctx context.Context, namespace string, selector Selector) ([]GroupConfigMatch, error)
+	Delete(ctx context.Context, namespace, name string, version int) error
+	// HighestVersion returns the highest version of the named group ever
+	// stored, including deleted ones, or 0 if there was none.
+	HighestVersion(ctx context.Context, namespace, name string) (int, error)
+}
+
+type NamespaceRepository interface {
+	Add(ctx context.Context, namespace Namespace) error
+	Get(ctx context.Context, name string) (Namespace, error)
+	GetAll(ctx context.Context) ([]Namespace, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// IndexWaiter exposes the global modification index maintained by the
+// repositories, used for blocking queries.
+type IndexWaiter interface {
+	Index() uint64
+	// WaitForIndex blocks until the index exceeds after or ctx is done and
+	// returns the current index.
+	WaitForIndex(ctx context.Context, after uint64) uint64
 }
@@ -1,10 +1,13 @@
 package model
 
+import "time"
+
 func NewConfigGroup(name string, version int) ConfigGroup {
 	return ConfigGroup{
-		Name:    name,
-		Version: version,
-		Configs: make([]GroupConfig, 0),
+		Name:      name,
+		Version:   version,
+		Configs:   make([]GroupConfig, 0),
+		CreatedAt: time.Now().UTC(),
 	}
 }
 
@@ -1,10 +1,14 @@
 package model
 
+import "time"
+
 func NewConfig(name string, version int) Config {
 	return Config{
 		Name:       name,
 		Version:    version,
 		Parameters: make([]ConfigParameter, 0),
+		Labels:     make([]Label, 0),
+		CreatedAt:  time.Now().UTC(),
 	}
 }
 
@@ -13,6 +17,20 @@ func (c *Config) AddParameter(key, value string) {
 	c.Parameters = append(c.Parameters, param)
 }
 
+func (c *Config) AddLabel(key, value string) {
+	label := NewLabel(key, value)
+	c.Labels = append(c.Labels, label)
+}
+
+func (c Config) GetLabel(key string) (string, bool) {
+	for _, label := range c.Labels {
+		if label.Key == key {
+			return label.Value, true
+		}
+	}
+	return "", false
+}
+
 func (c Config) GetParameter(key string) (string, bool) {
 	for _, param := range c.Parameters {
 		if param.Key == key {
@@ -0,0 +1,274 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type ParameterChange struct {
+	Key  string `json:"key"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ParameterDiff struct {
+	Added   []ConfigParameter `json:"added"`
+	Removed []ConfigParameter `json:"removed"`
+	Changed []ParameterChange `json:"changed"`
+}
+
+type ConfigDiff struct {
+	Name          string        `json:"name"`
+	From          int           `json:"from"`
+	To            int           `json:"to"`
+	Parameters    ParameterDiff `json:"parameters"`
+	LabelsAdded   []Label       `json:"labelsAdded"`
+	LabelsRemoved []Label       `json:"labelsRemoved"`
+}
+
+type GroupConfigDiff struct {
+	Name          string        `json:"name"`
+	Parameters    ParameterDiff `json:"parameters"`
+	LabelsAdded   []Label       `json:"labelsAdded"`
+	LabelsRemoved []Label       `json:"labelsRemoved"`
+}
+
+type GroupDiff struct {
+	Name           string            `json:"name"`
+	From           int               `json:"from"`
+	To             int               `json:"to"`
+	ConfigsAdded   []GroupConfig     `json:"configsAdded"`
+	ConfigsRemoved []GroupConfig     `json:"configsRemoved"`
+	ConfigsChanged []GroupConfigDiff `json:"configsChanged"`
+}
+
+func (d ParameterDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d GroupConfigDiff) IsEmpty() bool {
+	return d.Parameters.IsEmpty() && len(d.LabelsAdded) == 0 && len(d.LabelsRemoved) == 0
+}
+
+// DiffParameters compares two parameter lists by key. Entries in every
+// result list are sorted by key.
+func DiffParameters(from, to []ConfigParameter) ParameterDiff {
+	diff := ParameterDiff{
+		Added:   make([]ConfigParameter, 0),
+		Removed: make([]ConfigParameter, 0),
+		Changed: make([]ParameterChange, 0),
+	}
+	old := parameterMap(from)
+	cur := parameterMap(to)
+	for _, key := range sortedKeys(old) {
+		newValue, ok := cur[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, NewConfigParameter(key, old[key]))
+		} else if newValue != old[key] {
+			diff.Changed = append(diff.Changed, ParameterChange{Key: key, From: old[key], To: newValue})
+		}
+	}
+	for _, key := range sortedKeys(cur) {
+		if _, ok := old[key]; !ok {
+			diff.Added = append(diff.Added, NewConfigParameter(key, cur[key]))
+		}
+	}
+	return diff
+}
+
+// DiffLabels compares two label lists; a changed value shows up as one
+// removed and one added label.
+func DiffLabels(from, to []Label) (added []Label, removed []Label) {
+	added = make([]Label, 0)
+	removed = make([]Label, 0)
+	old := labelSet(from)
+	cur := labelSet(to)
+	for _, l := range sortedLabels(from) {
+		if !cur[l] {
+			removed = append(removed, l)
+		}
+	}
+	for _, l := range sortedLabels(to) {
+		if !old[l] {
+			added = append(added, l)
+		}
+	}
+	return added, removed
+}
+
+func DiffConfigs(from, to Config) ConfigDiff {
+	added, removed := DiffLabels(from.Labels, to.Labels)
+	return ConfigDiff{
+		Name:          to.Name,
+		From:          from.Version,
+		To:            to.Version,
+		Parameters:    DiffParameters(from.Parameters, to.Parameters),
+		LabelsAdded:   added,
+		LabelsRemoved: removed,
+	}
+}
+
+func diffGroupConfig(from, to GroupConfig) GroupConfigDiff {
+	added, removed := DiffLabels(from.Labels, to.Labels)
+	return GroupConfigDiff{
+		Name:          to.Name,
+		Parameters:    DiffParameters(from.Parameters, to.Parameters),
+		LabelsAdded:   added,
+		LabelsRemoved: removed,
+	}
+}
+
+// DiffGroups compares two versions of a group. Configs are matched by name.
+func DiffGroups(from, to ConfigGroup) GroupDiff {
+	diff := GroupDiff{
+		Name:           to.Name,
+		From:           from.Version,
+		To:             to.Version,
+		ConfigsAdded:   make([]GroupConfig, 0),
+		ConfigsRemoved: make([]GroupConfig, 0),
+		ConfigsChanged: make([]GroupConfigDiff, 0),
+	}
+	old := groupConfigMap(from.Configs)
+	cur := groupConfigMap(to.Configs)
+	for _, name := range sortedConfigNames(old) {
+		newConfig, ok := cur[name]
+		if !ok {
+			diff.ConfigsRemoved = append(diff.ConfigsRemoved, old[name])
+			continue
+		}
+		if changed := diffGroupConfig(old[name], newConfig); !changed.IsEmpty() {
+			diff.ConfigsChanged = append(diff.ConfigsChanged, changed)
+		}
+	}
+	for _, name := range sortedConfigNames(cur) {
+		if _, ok := old[name]; !ok {
+			diff.ConfigsAdded = append(diff.ConfigsAdded, cur[name])
+		}
+	}
+	return diff
+}
+
+// Text renders the diff in a unified-diff like format.
+func (d ConfigDiff) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- configs/%s@%d\n", d.Name, d.From)
+	fmt.Fprintf(&b, "+++ configs/%s@%d\n", d.Name, d.To)
+	writeParameterDiff(&b, d.Parameters)
+	writeLabelDiff(&b, d.LabelsAdded, d.LabelsRemoved)
+	return b.String()
+}
+
+// Text renders the diff in a unified-diff like format with one hunk per
+// added, removed or changed config.
+func (d GroupDiff) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- groups/%s@%d\n", d.Name, d.From)
+	fmt.Fprintf(&b, "+++ groups/%s@%d\n", d.Name, d.To)
+	for _, cfg := range d.ConfigsRemoved {
+		fmt.Fprintf(&b, "@@ -config %s\n", cfg.Name)
+		writeGroupConfig(&b, "-", cfg)
+	}
+	for _, cfg := range d.ConfigsAdded {
+		fmt.Fprintf(&b, "@@ +config %s\n", cfg.Name)
+		writeGroupConfig(&b, "+", cfg)
+	}
+	for _, cfg := range d.ConfigsChanged {
+		fmt.Fprintf(&b, "@@ config %s\n", cfg.Name)
+		writeParameterDiff(&b, cfg.Parameters)
+		writeLabelDiff(&b, cfg.LabelsAdded, cfg.LabelsRemoved)
+	}
+	return b.String()
+}
+
+func writeParameterDiff(b *strings.Builder, d ParameterDiff) {
+	for _, p := range d.Removed {
+		fmt.Fprintf(b, "-%s\n", p)
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(b, "-%s=%s\n", c.Key, c.From)
+		fmt.Fprintf(b, "+%s=%s\n", c.Key, c.To)
+	}
+	for _, p := range d.Added {
+		fmt.Fprintf(b, "+%s\n", p)
+	}
+}
+
+func writeLabelDiff(b *strings.Builder, added, removed []Label) {
+	for _, l := range removed {
+		fmt.Fprintf(b, "-label %s\n", l)
+	}
+	for _, l := range added {
+		fmt.Fprintf(b, "+label %s\n", l)
+	}
+}
+
+func writeGroupConfig(b *strings.Builder, sign string, cfg GroupConfig) {
+	params := parameterMap(cfg.Parameters)
+	for _, key := range sortedKeys(params) {
+		fmt.Fprintf(b, "%s%s=%s\n", sign, key, params[key])
+	}
+	for _, l := range sortedLabels(cfg.Labels) {
+		fmt.Fprintf(b, "%slabel %s\n", sign, l)
+	}
+}
+
+func parameterMap(params []ConfigParameter) map[string]string {
+	m := make(map[string]string, len(params))
+	for _, p := range params {
+		m[p.Key] = p.Value
+	}
+	return m
+}
+
+func labelSet(labels []Label) map[Label]bool {
+	m := make(map[Label]bool, len(labels))
+	for _, l := range labels {
+		m[l] = true
+	}
+	return m
+}
+
+func groupConfigMap(configs []GroupConfig) map[string]GroupConfig {
+	m := make(map[string]GroupConfig, len(configs))
+	for _, c := range configs {
+		m[c.Name] = c
+	}
+	return m
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedConfigNames(m map[string]GroupConfig) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedLabels(labels []Label) []Label {
+	sorted := make([]Label, 0, len(labels))
+	seen := make(map[Label]bool, len(labels))
+	for _, l := range labels {
+		if !seen[l] {
+			seen[l] = true
+			sorted = append(sorted, l)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Key != sorted[j].Key {
+			return sorted[i].Key < sorted[j].Key
+		}
+		return sorted[i].Value < sorted[j].Value
+	})
+	return sorted
+}
@@ -0,0 +1,23 @@
+package model
+
+import "errors"
+
+var (
+	ErrConfigNotFound     = errors.New("config not found")
+	ErrConfigExists       = errors.New("config already exists")
+	ErrGroupNotFound      = errors.New("config group not found")
+	ErrGroupExists        = errors.New("config group already exists")
+	ErrConfigNotInGroup   = errors.New("config not found in group")
+	ErrConcurrentUpdate   = errors.New("resource was modified concurrently")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrVersionDeleted     = errors.New("target version has been deleted")
+	ErrInvalidRollback    = errors.New("rollback target must be older than the latest version")
+	ErrNamespaceNotFound  = errors.New("namespace not found")
+	ErrNamespaceExists    = errors.New("namespace already exists")
+	ErrNamespaceNotEmpty  = errors.New("namespace still contains configs or groups")
+	ErrNamespaceProtected = errors.New("the default namespace cannot be deleted")
+	ErrInvalidNamespace   = errors.New("namespace names must be lowercase DNS labels of at most 63 characters")
+	ErrInvalidName        = errors.New("config and group names must be non-empty and must not contain '/'")
+	ErrQuotaExceeded      = errors.New("quota exceeded")
+	ErrStorageFull        = errors.New("storage quota exceeded")
+)
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultNamespace holds everything created through the un-namespaced
+// routes. It always exists and cannot be deleted.
+const DefaultNamespace = "default"
+
+type Namespace struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+var namespaceNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// ValidNamespaceName reports whether name is a lowercase DNS label of at
+// most 63 characters.
+func ValidNamespaceName(name string) bool {
+	return len(name) <= 63 && namespaceNamePattern.MatchString(name)
+}
+
+// NamespaceOrDefault maps the empty namespace of data written before
+// namespaces existed to DefaultNamespace.
+func NamespaceOrDefault(namespace string) string {
+	if namespace == "" {
+		return DefaultNamespace
+	}
+	return namespace
+}
+
+// ValidResourceName reports whether name can name a config or group. A "/"
+// would make resource keys and storage paths ambiguous: the versions of
+// "db" would include those of "db/x".
+func ValidResourceName(name string) bool {
+	return name != "" && !strings.Contains(name, "/")
+}
+
+// ResourceKey identifies a config or group across namespaces, e.g.
+// "groups/web_configs" in the default namespace and
+// "namespaces/team-a/groups/web_configs" elsewhere. kind is "configs" or
+// "groups". Audit entries and access policies use these keys.
+func ResourceKey(namespace, kind, name string) string {
+	if NamespaceOrDefault(namespace) == DefaultNamespace {
+		return kind + "/" + name
+	}
+	return "namespaces/" + namespace + "/" + kind + "/" + name
+}
+
+// ParseResourceKey splits a key made by ResourceKey. It reports false for
+// keys of any other form.
+func ParseResourceKey(key string) (namespace, kind, name string, ok bool) {
+	namespace = DefaultNamespace
+	if rest := strings.TrimPrefix(key, "namespaces/"); rest != key {
+		var found bool
+		if namespace, key, found = strings.Cut(rest, "/"); !found {
+			return "", "", "", false
+		}
+	}
+	kind, name, found := strings.Cut(key, "/")
+	if !found || (kind != "configs" && kind != "groups") || !ValidResourceName(name) {
+		return "", "", "", false
+	}
+	return namespace, kind, name, true
+}
@@ -0,0 +1,95 @@
+package model
+
+// Quota limits what a namespace, or one principal in a namespace, may store.
+// Zero fields are unlimited.
+type Quota struct {
+	// MaxConfigs and MaxGroups count distinct names, not versions.
+	MaxConfigs         int `json:"maxConfigs,omitempty"`
+	MaxGroups          int `json:"maxGroups,omitempty"`
+	MaxVersionsPerName int `json:"maxVersionsPerName,omitempty"`
+	// MaxParameters applies to every Config and to every GroupConfig.
+	MaxParameters int `json:"maxParameters,omitempty"`
+	// MaxValueBytes applies to every parameter and label value.
+	MaxValueBytes int `json:"maxValueBytes,omitempty"`
+	// MaxTotalBytes bounds the Size of every stored config and group
+	// version together.
+	MaxTotalBytes int64 `json:"maxTotalBytes,omitempty"`
+}
+
+// QuotaPolicy holds the quota of every namespace: Namespaces overrides
+// Default for the namespaces it lists. Principals limits what an API key or
+// JWT subject, keyed by principal ID, stores in each namespace.
+type QuotaPolicy struct {
+	Default    Quota            `json:"default"`
+	Namespaces map[string]Quota `json:"namespaces"`
+	Principals map[string]Quota `json:"principals"`
+}
+
+func (p QuotaPolicy) For(namespace string) Quota {
+	if quota, ok := p.Namespaces[NamespaceOrDefault(namespace)]; ok {
+		return quota
+	}
+	return p.Default
+}
+
+// Empty reports whether the policy sets no limit at all.
+func (p QuotaPolicy) Empty() bool {
+	if p.Default != (Quota{}) {
+		return false
+	}
+	for _, quota := range p.Namespaces {
+		if quota != (Quota{}) {
+			return false
+		}
+	}
+	for _, quota := range p.Principals {
+		if quota != (Quota{}) {
+			return false
+		}
+	}
+	return true
+}
+
+// ForPrincipal returns the quota of a principal; writes without a principal
+// have none.
+func (p QuotaPolicy) ForPrincipal(id string) Quota {
+	return p.Principals[id]
+}
+
+// Usage is what a namespace currently stores.
+type Usage struct {
+	Configs        int   `json:"configs"`
+	ConfigVersions int   `json:"configVersions"`
+	Groups         int   `json:"groups"`
+	GroupVersions  int   `json:"groupVersions"`
+	TotalBytes     int64 `json:"totalBytes"`
+}
+
+// parametersSize is the number of bytes taken by the keys and values of
+// parameters and labels.
+func parametersSize(parameters []ConfigParameter, labels []Label) int64 {
+	var size int64
+	for _, p := range parameters {
+		size += int64(len(p.Key) + len(p.Value))
+	}
+	for _, l := range labels {
+		size += int64(len(l.Key) + len(l.Value))
+	}
+	return size
+}
+
+// Size approximates the storage a config version takes: its name plus the
+// keys and values of its parameters and labels.
+func (c Config) Size() int64 {
+	return int64(len(c.Name)) + parametersSize(c.Parameters, c.Labels)
+}
+
+// Size approximates the storage a group version takes, counted like
+// Config.Size for the group and each of its configs.
+func (g ConfigGroup) Size() int64 {
+	size := int64(len(g.Name))
+	for _, config := range g.Configs {
+		size += int64(len(config.Name)) + parametersSize(config.Parameters, config.Labels)
+	}
+	return size
+}
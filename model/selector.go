@@ -0,0 +1,327 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type SelectorOperator string
+
+const (
+	SelectorEquals       SelectorOperator = "="
+	SelectorNotEquals    SelectorOperator = "!="
+	SelectorIn           SelectorOperator = "in"
+	SelectorNotIn        SelectorOperator = "notin"
+	SelectorExists       SelectorOperator = "exists"
+	SelectorDoesNotExist SelectorOperator = "!"
+)
+
+// Requirement is a single condition of a label selector.
+type Requirement struct {
+	Key      string
+	Operator SelectorOperator
+	Values   []string
+}
+
+// Selector is a conjunction of requirements. An empty selector matches
+// everything.
+//
+// The syntax follows Kubernetes set-based selectors, with requirements
+// separated by ',' or ';':
+//
+//	env=prod, tier!=cache, team in (backend,infra), zone notin (a), canary, !legacy
+//
+// "key:value" is accepted as a synonym for "key=value". Only the first ':'
+// of a requirement is an operator, so values such as "url=http://x" or
+// "url:http://x" keep their colons.
+type Selector []Requirement
+
+// SelectorError reports a selector syntax error at a byte offset.
+type SelectorError struct {
+	Pos   int
+	Token string
+	Msg   string
+}
+
+func (e *SelectorError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("invalid selector at position %d: %s", e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("invalid selector at position %d near %q: %s", e.Pos, e.Token, e.Msg)
+}
+
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case SelectorExists:
+		return ok
+	case SelectorDoesNotExist:
+		return !ok
+	case SelectorEquals, SelectorIn:
+		return ok && containsString(r.Values, value)
+	case SelectorNotEquals, SelectorNotIn:
+		return !ok || !containsString(r.Values, value)
+	}
+	return false
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case SelectorExists:
+		return r.Key
+	case SelectorDoesNotExist:
+		return "!" + r.Key
+	case SelectorEquals, SelectorNotEquals:
+		return r.Key + string(r.Operator) + r.Values[0]
+	}
+	return r.Key + " " + string(r.Operator) + " (" + strings.Join(r.Values, ",") + ")"
+}
+
+// Matches reports whether the labels satisfy every requirement.
+func (s Selector) Matches(labels []Label) bool {
+	if len(s) == 0 {
+		return true
+	}
+	m := make(map[string]string, len(labels))
+	for _, l := range labels {
+		m[l.Key] = l.Value
+	}
+	for _, r := range s {
+		if !r.Matches(m) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	parts := make([]string, 0, len(s))
+	for _, r := range s {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func containsString(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+type selectorTokenKind int
+
+const (
+	tokenEOF selectorTokenKind = iota
+	tokenIdent
+	tokenSeparator
+	tokenOpenParen
+	tokenCloseParen
+	tokenNot
+	tokenEquals
+	tokenNotEquals
+)
+
+type selectorToken struct {
+	kind selectorTokenKind
+	text string
+	pos  int
+}
+
+// isSelectorIdentChar reports whether c continues a key or value. ':' only
+// ends keys, so values such as "http://x" need no quoting.
+func isSelectorIdentChar(c byte, inValue bool) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', ',', ';', '(', ')', '!', '=':
+		return false
+	case ':':
+		return inValue
+	}
+	return true
+}
+
+func lexSelector(input string) []selectorToken {
+	tokens := make([]selectorToken, 0)
+	// inValue is set once a requirement has its operator, from where on
+	// ':' is part of the values rather than the legacy "key:value" operator.
+	// Separators inside a set do not end the requirement.
+	inValue := false
+	depth := 0
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == ',' || c == ';':
+			tokens = append(tokens, selectorToken{tokenSeparator, string(c), i})
+			inValue = depth > 0
+			i++
+		case c == '(':
+			tokens = append(tokens, selectorToken{tokenOpenParen, "(", i})
+			inValue = true
+			depth++
+			i++
+		case c == ')':
+			tokens = append(tokens, selectorToken{tokenCloseParen, ")", i})
+			if depth > 0 {
+				depth--
+			}
+			i++
+		case c == '!':
+			if i+1 < len(input) && input[i+1] == '=' {
+				tokens = append(tokens, selectorToken{tokenNotEquals, "!=", i})
+				inValue = true
+				i += 2
+			} else {
+				tokens = append(tokens, selectorToken{tokenNot, "!", i})
+				i++
+			}
+		case c == '=':
+			if i+1 < len(input) && input[i+1] == '=' {
+				tokens = append(tokens, selectorToken{tokenEquals, "==", i})
+				i += 2
+			} else {
+				tokens = append(tokens, selectorToken{tokenEquals, "=", i})
+				i++
+			}
+			inValue = true
+		case c == ':' && !inValue:
+			tokens = append(tokens, selectorToken{tokenEquals, ":", i})
+			inValue = true
+			i++
+		default:
+			start := i
+			for i < len(input) && isSelectorIdentChar(input[i], inValue) {
+				i++
+			}
+			tokens = append(tokens, selectorToken{tokenIdent, input[start:i], start})
+		}
+	}
+	tokens = append(tokens, selectorToken{tokenEOF, "", len(input)})
+	return tokens
+}
+
+type selectorParser struct {
+	tokens []selectorToken
+	pos    int
+}
+
+func (p *selectorParser) peek() selectorToken {
+	return p.tokens[p.pos]
+}
+
+func (p *selectorParser) next() selectorToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *selectorParser) errorAt(t selectorToken, msg string) error {
+	return &SelectorError{Pos: t.pos, Token: t.text, Msg: msg}
+}
+
+// ParseSelector parses a label selector. Syntax errors are returned as
+// *SelectorError.
+func ParseSelector(input string) (Selector, error) {
+	p := &selectorParser{tokens: lexSelector(input)}
+	selector := make(Selector, 0)
+	for {
+		t := p.peek()
+		if t.kind == tokenEOF {
+			break
+		}
+		if t.kind == tokenSeparator {
+			// tolerate empty requirements such as a trailing ';'
+			p.next()
+			continue
+		}
+		req, err := p.parseRequirement()
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, req)
+
+		t = p.next()
+		if t.kind != tokenEOF && t.kind != tokenSeparator {
+			return nil, p.errorAt(t, "expected ',' or ';' between requirements")
+		}
+		if t.kind == tokenEOF {
+			break
+		}
+	}
+	return selector, nil
+}
+
+func (p *selectorParser) parseRequirement() (Requirement, error) {
+	t := p.next()
+	if t.kind == tokenNot {
+		key := p.next()
+		if key.kind != tokenIdent {
+			return Requirement{}, p.errorAt(key, "expected label key after '!'")
+		}
+		return Requirement{Key: key.text, Operator: SelectorDoesNotExist}, nil
+	}
+	if t.kind != tokenIdent {
+		return Requirement{}, p.errorAt(t, "expected label key")
+	}
+	key := t.text
+
+	op := p.peek()
+	switch {
+	case op.kind == tokenEOF || op.kind == tokenSeparator:
+		return Requirement{Key: key, Operator: SelectorExists}, nil
+	case op.kind == tokenEquals || op.kind == tokenNotEquals:
+		p.next()
+		value := p.next()
+		if value.kind != tokenIdent {
+			return Requirement{}, p.errorAt(value, "expected label value")
+		}
+		operator := SelectorEquals
+		if op.kind == tokenNotEquals {
+			operator = SelectorNotEquals
+		}
+		return Requirement{Key: key, Operator: operator, Values: []string{value.text}}, nil
+	case op.kind == tokenIdent && (op.text == "in" || op.text == "notin"):
+		p.next()
+		values, err := p.parseValueSet()
+		if err != nil {
+			return Requirement{}, err
+		}
+		operator := SelectorIn
+		if op.text == "notin" {
+			operator = SelectorNotIn
+		}
+		return Requirement{Key: key, Operator: operator, Values: values}, nil
+	}
+	return Requirement{}, p.errorAt(op, "expected operator (=, ==, !=, in, notin) or end of requirement")
+}
+
+func (p *selectorParser) parseValueSet() ([]string, error) {
+	open := p.next()
+	if open.kind != tokenOpenParen {
+		return nil, p.errorAt(open, "expected '('")
+	}
+	values := make([]string, 0)
+	for {
+		value := p.next()
+		if value.kind != tokenIdent {
+			return nil, p.errorAt(value, "expected value in set")
+		}
+		values = append(values, value.text)
+
+		t := p.next()
+		if t.kind == tokenCloseParen {
+			break
+		}
+		if t.kind != tokenSeparator || t.text != "," {
+			return nil, p.errorAt(t, "expected ',' or ')'")
+		}
+	}
+	sort.Strings(values)
+	return values, nil
+}
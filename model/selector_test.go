@@ -0,0 +1,53 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Selector
+	}{
+		{"env=prod", Selector{{Key: "env", Operator: SelectorEquals, Values: []string{"prod"}}}},
+		{"env==prod, tier!=cache", Selector{
+			{Key: "env", Operator: SelectorEquals, Values: []string{"prod"}},
+			{Key: "tier", Operator: SelectorNotEquals, Values: []string{"cache"}},
+		}},
+		{"k1:v1;k2:v2", Selector{
+			{Key: "k1", Operator: SelectorEquals, Values: []string{"v1"}},
+			{Key: "k2", Operator: SelectorEquals, Values: []string{"v2"}},
+		}},
+		{"url:http://x", Selector{{Key: "url", Operator: SelectorEquals, Values: []string{"http://x"}}}},
+		{"url=http://x:8080", Selector{{Key: "url", Operator: SelectorEquals, Values: []string{"http://x:8080"}}}},
+		{"url!=a:b;env:prod", Selector{
+			{Key: "url", Operator: SelectorNotEquals, Values: []string{"a:b"}},
+			{Key: "env", Operator: SelectorEquals, Values: []string{"prod"}},
+		}},
+		{"zone in (eu:1, us:2), canary, !legacy", Selector{
+			{Key: "zone", Operator: SelectorIn, Values: []string{"eu:1", "us:2"}},
+			{Key: "canary", Operator: SelectorExists},
+			{Key: "legacy", Operator: SelectorDoesNotExist},
+		}},
+		{"team notin (a);", Selector{{Key: "team", Operator: SelectorNotIn, Values: []string{"a"}}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSelector(tt.input)
+		if err != nil {
+			t.Errorf("ParseSelector(%q): %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSelector(%q) = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, input := range []string{"=prod", ":prod", "env=", "env in a", "env in (a", "a b"} {
+		if _, err := ParseSelector(input); err == nil {
+			t.Errorf("ParseSelector(%q) succeeded", input)
+		}
+	}
+}
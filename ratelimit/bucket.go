@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket holds up to capacity tokens and refills continuously at rate
+// tokens per second. Every request takes one token.
+type TokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func NewTokenBucket(rate float64, capacity int) *TokenBucket {
+	return newTokenBucket(rate, capacity, time.Now())
+}
+
+// newTokenBucket returns a full bucket that starts refilling at now.
+func newTokenBucket(rate float64, capacity int, now time.Time) *TokenBucket {
+	return &TokenBucket{
+		rate:     rate,
+		capacity: float64(capacity),
+		tokens:   float64(capacity),
+		last:     now,
+	}
+}
+
+// Result describes a bucket right after a request tried to take a token.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token is available. It is zero
+	// for allowed requests.
+	RetryAfter time.Duration
+}
+
+// Take refills the bucket up to now and takes a token if one is available.
+func (b *TokenBucket) Take(now time.Time) Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+
+	result := Result{Limit: int(b.capacity)}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = b.until(1)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = b.until(b.capacity)
+	return result
+}
+
+func (b *TokenBucket) Allow() bool {
+	return b.Take(time.Now()).Allowed
+}
+
+// until returns how long the bucket needs to refill to level tokens.
+func (b *TokenBucket) until(level float64) time.Duration {
+	if b.tokens >= level {
+		return 0
+	}
+	return time.Duration((level - b.tokens) / b.rate * float64(time.Second))
+}
@@ -0,0 +1,187 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPolicyName labels requests that match no policy.
+const DefaultPolicyName = "default"
+
+// Options bound the memory used for buckets. Zero values select the
+// defaults.
+type Options struct {
+	// MaxBuckets caps the number of buckets kept; the least recently used
+	// one is evicted to make room. Defaults to 100000.
+	MaxBuckets int
+	// IdleTTL is how long a bucket is kept after its last request. It is
+	// raised to the time the slowest bucket needs to refill completely,
+	// because only a full bucket can be dropped without handing its client
+	// extra tokens. Defaults to 10 minutes.
+	IdleTTL time.Duration
+}
+
+// Stats describes the bucket store and how many requests each policy
+// rejected.
+type Stats struct {
+	Buckets  int               `json:"buckets"`
+	Evicted  uint64            `json:"evicted"`
+	Expired  uint64            `json:"expired"`
+	Rejected map[string]uint64 `json:"rejected"`
+}
+
+// Limiter rate limits each client with token buckets. A request is charged
+// to the first policy it matches, or to the default limit, so every client
+// has one bucket per policy.
+type Limiter struct {
+	def      Limit
+	policies []Policy
+	key      func(*http.Request) string
+	buckets  *bucketStore
+	exempt   map[string]bool
+	// rejected counts 429s per policy; the last entry is the default.
+	rejected []atomic.Uint64
+	// now is the clock, replaced in tests.
+	now func() time.Time
+}
+
+type bucketKey struct {
+	// policy is an index into Limiter.policies, or -1 for the default.
+	policy int
+	client string
+}
+
+// New returns a limiter that identifies clients with key. Idle buckets are
+// only dropped while Run is running.
+func New(def Limit, policies []Policy, key func(*http.Request) string, opts Options) (*Limiter, error) {
+	if err := def.validate(); err != nil {
+		return nil, err
+	}
+	if opts.MaxBuckets <= 0 {
+		opts.MaxBuckets = 100000
+	}
+	if opts.IdleTTL <= 0 {
+		opts.IdleTTL = 10 * time.Minute
+	}
+	for _, limit := range append([]Limit{def}, policyLimits(policies)...) {
+		if refill := limit.refillTime(); refill > opts.IdleTTL {
+			opts.IdleTTL = refill
+		}
+	}
+	policies = append([]Policy(nil), policies...)
+	for i := range policies {
+		if policies[i].Name == "" {
+			policies[i].Name = "policy-" + strconv.Itoa(i)
+		}
+	}
+	return &Limiter{
+		def:      def,
+		policies: policies,
+		key:      key,
+		buckets:  newBucketStore(opts.MaxBuckets, opts.IdleTTL),
+		exempt:   make(map[string]bool),
+		rejected: make([]atomic.Uint64, len(policies)+1),
+		now:      time.Now,
+	}, nil
+}
+
+func policyLimits(policies []Policy) []Limit {
+	limits := make([]Limit, 0, len(policies))
+	for _, policy := range policies {
+		limits = append(limits, policy.Limit)
+	}
+	return limits
+}
+
+// Run drops idle buckets until ctx is done.
+func (l *Limiter) Run(ctx context.Context) {
+	interval := l.buckets.ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			l.buckets.sweep(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (l *Limiter) Stats() Stats {
+	stats := l.buckets.stats()
+	stats.Rejected = make(map[string]uint64, len(l.rejected))
+	for i, policy := range l.policies {
+		stats.Rejected[policy.Name] = l.rejected[i].Load()
+	}
+	stats.Rejected[DefaultPolicyName] = l.rejected[len(l.policies)].Load()
+	return stats
+}
+
+// Exempt excludes a path from rate limiting, e.g. long-lived streams that
+// would otherwise hold a token for their whole lifetime.
+func (l *Limiter) Exempt(path string) {
+	l.exempt[path] = true
+}
+
+// bucket returns the client's bucket for the first matching policy, and the
+// index of that policy in rejected.
+func (l *Limiter) bucket(r *http.Request, now time.Time) (*TokenBucket, int) {
+	key := bucketKey{policy: -1, client: l.key(r)}
+	limit := l.def
+	for i, policy := range l.policies {
+		if policy.matches(r) {
+			key.policy = i
+			limit = policy.Limit
+			break
+		}
+	}
+	counter := key.policy
+	if counter < 0 {
+		counter = len(l.policies)
+	}
+	return l.buckets.get(key, limit, now), counter
+}
+
+// Middleware rejects requests over the limit with 429 Too Many Requests and
+// a Retry-After header. Every limited response carries RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset, in whole seconds.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.exempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now := l.now()
+		bucket, counter := l.bucket(r, now)
+		result := bucket.Take(now)
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+		if !result.Allowed {
+			retryAfter := seconds(result.RetryAfter)
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			h.Set("Retry-After", strconv.Itoa(retryAfter))
+			l.rejected[counter].Add(1)
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,185 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const benchMaxBuckets = 10000
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestTokenBucketRefill(t *testing.T) {
+	// 2 tokens per second, up to 3.
+	bucket := newTokenBucket(2, 3, testStart)
+	steps := []struct {
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		reset      time.Duration
+		retryAfter time.Duration
+	}{
+		{0, true, 2, 500 * time.Millisecond, 0},
+		{0, true, 1, time.Second, 0},
+		{0, true, 0, 1500 * time.Millisecond, 0},
+		{0, false, 0, 1500 * time.Millisecond, 500 * time.Millisecond},
+		{250 * time.Millisecond, false, 0, 1250 * time.Millisecond, 250 * time.Millisecond},
+		{500 * time.Millisecond, true, 0, 1500 * time.Millisecond, 0},
+		// A long pause refills no more than the capacity.
+		{10 * time.Second, true, 2, 500 * time.Millisecond, 0},
+	}
+	for i, step := range steps {
+		got := bucket.Take(testStart.Add(step.at))
+		if got.Allowed != step.allowed || got.Limit != 3 || got.Remaining != step.remaining ||
+			got.Reset != step.reset || got.RetryAfter != step.retryAfter {
+			t.Errorf("step %d at %v: got %+v, want allowed=%v remaining=%d reset=%v retryAfter=%v",
+				i, step.at, got, step.allowed, step.remaining, step.reset, step.retryAfter)
+		}
+	}
+}
+
+func TestPolicyMatching(t *testing.T) {
+	policies := []Policy{
+		{Name: "writes", Methods: []string{"POST", "DELETE"}, Limit: Limit{Rate: 1, Burst: 1}},
+		{Name: "search", Paths: []string{"/search"}, Limit: Limit{Rate: 1, Burst: 1}},
+		{Name: "config-reads", Methods: []string{"GET"}, Paths: []string{"/configs/"}, Limit: Limit{Rate: 1, Burst: 1}},
+	}
+	limiter, err := New(Limit{Rate: 1, Burst: 1}, policies, func(*http.Request) string { return "client" }, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method, target string
+		want           string
+	}{
+		{"POST", "/configs", "writes"},
+		{"DELETE", "/namespaces/team-a/groups/web/1", "writes"},
+		{"GET", "/search?labels=env:prod", "search"},
+		{"POST", "/search", "writes"},
+		{"GET", "/searchable", DefaultPolicyName},
+		{"GET", "/configs", "config-reads"},
+		{"GET", "/namespaces/team-a/configs/db/1", "config-reads"},
+		{"GET", "/configsx", DefaultPolicyName},
+		{"HEAD", "/configs", DefaultPolicyName},
+		{"GET", "/groups", DefaultPolicyName},
+	}
+	for _, tt := range tests {
+		_, counter := limiter.bucket(httptest.NewRequest(tt.method, tt.target, nil), testStart)
+		got := DefaultPolicyName
+		if counter < len(policies) {
+			got = policies[counter].Name
+		}
+		if got != tt.want {
+			t.Errorf("%s %s matched %q, want %q", tt.method, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	// One token every 2 seconds, up to 2.
+	limiter, err := New(Limit{Rate: 0.5, Burst: 2}, nil, func(*http.Request) string { return "client" }, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := testStart
+	limiter.now = func() time.Time { return now }
+	handler := limiter.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	steps := []struct {
+		advance    time.Duration
+		status     int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{0, http.StatusOK, "1", "2", ""},
+		{0, http.StatusOK, "0", "4", ""},
+		{0, http.StatusTooManyRequests, "0", "4", "2"},
+		{1500 * time.Millisecond, http.StatusTooManyRequests, "0", "3", "1"},
+		// Retry-After was accurate: the token arrives 2s after the first 429.
+		{500 * time.Millisecond, http.StatusOK, "0", "4", ""},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs", nil))
+		h := w.Header()
+		if w.Code != step.status || h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Remaining") != step.remaining ||
+			h.Get("RateLimit-Reset") != step.reset || h.Get("Retry-After") != step.retryAfter {
+			t.Errorf("step %d: status %d, headers %v; want %d with remaining=%s reset=%s retry-after=%q",
+				i, w.Code, h, step.status, step.remaining, step.reset, step.retryAfter)
+		}
+	}
+	if rejected := limiter.Stats().Rejected[DefaultPolicyName]; rejected != 2 {
+		t.Errorf("%d requests counted as rejected, want 2", rejected)
+	}
+}
+
+// discardWriter is a ResponseWriter that keeps nothing but its headers, so
+// the benchmarks measure the limiter rather than a recorder.
+type discardWriter struct{ header http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func benchLimiter(b *testing.B, key func(*http.Request) string) http.Handler {
+	b.Helper()
+	limiter, err := New(Limit{Rate: 5, Burst: 10}, nil, key, Options{MaxBuckets: benchMaxBuckets})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return limiter.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+}
+
+// BenchmarkLimiterSameClient measures the common case: a client whose
+// bucket already exists.
+func BenchmarkLimiterSameClient(b *testing.B) {
+	handler := benchLimiter(b, func(*http.Request) string { return "client" })
+	r := httptest.NewRequest(http.MethodGet, "/configs", nil)
+	w := &discardWriter{header: make(http.Header)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(w, r)
+	}
+}
+
+// BenchmarkLimiterDistinctClients sends every request from a new client,
+// as a scan from many addresses would, and checks that the store stays
+// within MaxBuckets. Run it with millions of keys, e.g.
+//
+//	go test -run=^$ -bench=DistinctClients -benchtime=5000000x ./ratelimit
+func BenchmarkLimiterDistinctClients(b *testing.B) {
+	var next atomic.Uint64
+	limiter, err := New(Limit{Rate: 5, Burst: 10}, nil, func(*http.Request) string {
+		return strconv.FormatUint(next.Add(1), 10)
+	}, Options{MaxBuckets: benchMaxBuckets})
+	if err != nil {
+		b.Fatal(err)
+	}
+	handler := limiter.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	r := httptest.NewRequest(http.MethodGet, "/configs", nil)
+	w := &discardWriter{header: make(http.Header)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(w, r)
+	}
+	b.StopTimer()
+
+	stats := limiter.Stats()
+	if stats.Buckets > benchMaxBuckets {
+		b.Fatalf("%d buckets kept, want at most %d", stats.Buckets, benchMaxBuckets)
+	}
+	if want := uint64(b.N - stats.Buckets); stats.Evicted != want {
+		b.Fatalf("%d buckets evicted, want %d", stats.Evicted, want)
+	}
+	b.ReportMetric(float64(stats.Buckets), "buckets")
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Limit is a sustained rate in requests per second and the burst a client
+// may spend at once.
+type Limit struct {
+	Rate  float64 `json:"rps"`
+	Burst int     `json:"burst"`
+}
+
+// refillTime is how long an empty bucket takes to fill up.
+func (l Limit) refillTime() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+func (l Limit) validate() error {
+	if l.Rate <= 0 {
+		return fmt.Errorf("rps must be positive, got %v", l.Rate)
+	}
+	if l.Burst < 1 {
+		return fmt.Errorf("burst must be at least 1, got %d", l.Burst)
+	}
+	return nil
+}
+
+// Policy applies its Limit to requests with one of Methods whose path
+// starts with one of Paths. Empty lists match everything. Paths are
+// matched after stripping a leading /namespaces/{namespace}, so "/configs"
+// also covers the configs of every namespace. Name labels the policy in
+// metrics and defaults to "policy-<index>".
+type Policy struct {
+	Name    string   `json:"name"`
+	Methods []string `json:"methods"`
+	Paths   []string `json:"paths"`
+	Limit
+}
+
+// LoadPolicies reads an ordered list of policies from a JSON file:
+//
+//	[
+//	  {"name": "writes", "methods": ["POST", "DELETE"], "rps": 1, "burst": 5},
+//	  {"paths": ["/search"], "rps": 2, "burst": 4}
+//	]
+func LoadPolicies(file string) ([]Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var policies []Policy
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("parse rate limit policies: %w", err)
+	}
+	for i := range policies {
+		if err := policies[i].validate(); err != nil {
+			return nil, fmt.Errorf("rate limit policy %d: %w", i, err)
+		}
+		for j, method := range policies[i].Methods {
+			policies[i].Methods[j] = strings.ToUpper(method)
+		}
+	}
+	return policies, nil
+}
+
+func (p Policy) matches(r *http.Request) bool {
+	if len(p.Methods) > 0 && !containsString(p.Methods, r.Method) {
+		return false
+	}
+	if len(p.Paths) == 0 {
+		return true
+	}
+	path := relativePath(r.URL.Path)
+	for _, prefix := range p.Paths {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// relativePath strips the /namespaces/{namespace} prefix of namespaced
+// routes.
+func relativePath(path string) string {
+	segments := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	if len(segments) == 3 && segments[0] == "namespaces" {
+		return "/" + segments[2]
+	}
+	return path
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
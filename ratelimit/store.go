@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// bucketStore keeps the buckets of recently seen clients. When it is full
+// the least recently used bucket is evicted, and sweep drops buckets that
+// have been idle for longer than ttl.
+type bucketStore struct {
+	mu      sync.Mutex
+	max     int
+	ttl     time.Duration
+	entries map[bucketKey]*list.Element
+	// lru holds *storeEntry values, most recently used first.
+	lru     *list.List
+	evicted uint64
+	expired uint64
+}
+
+type storeEntry struct {
+	key      bucketKey
+	bucket   *TokenBucket
+	lastUsed time.Time
+}
+
+func newBucketStore(max int, ttl time.Duration) *bucketStore {
+	return &bucketStore{
+		max:     max,
+		ttl:     ttl,
+		entries: make(map[bucketKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get returns the bucket for key, creating it with limit if needed.
+func (s *bucketStore) get(key bucketKey, limit Limit, now time.Time) *TokenBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*storeEntry)
+		entry.lastUsed = now
+		s.lru.MoveToFront(elem)
+		return entry.bucket
+	}
+	for s.max > 0 && s.lru.Len() >= s.max {
+		s.remove(s.lru.Back())
+		s.evicted++
+	}
+	entry := &storeEntry{key: key, bucket: newTokenBucket(limit.Rate, limit.Burst, now), lastUsed: now}
+	s.entries[key] = s.lru.PushFront(entry)
+	return entry.bucket
+}
+
+func (s *bucketStore) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.entries, elem.Value.(*storeEntry).key)
+}
+
+// sweep drops the buckets idle since before now-ttl. The list is ordered by
+// last use, so it stops at the first bucket that is still in use.
+func (s *bucketStore) sweep(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := now.Add(-s.ttl)
+	for elem := s.lru.Back(); elem != nil; elem = s.lru.Back() {
+		if elem.Value.(*storeEntry).lastUsed.After(cutoff) {
+			return
+		}
+		s.remove(elem)
+		s.expired++
+	}
+}
+
+func (s *bucketStore) stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{
+		Buckets: s.lru.Len(),
+		Evicted: s.evicted,
+		Expired: s.expired,
+	}
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+)
+
+// ChangeIndex is a global modification counter shared by the config and
+// group repositories. Every successful Add or Delete advances it, and
+// WaitForIndex lets callers block until that happens. A nil *ChangeIndex
+// is valid and never advances.
+type ChangeIndex struct {
+	mu      sync.Mutex
+	index   uint64
+	changed chan struct{}
+}
+
+func NewChangeIndex() *ChangeIndex {
+	return &ChangeIndex{
+		changed: make(chan struct{}),
+	}
+}
+
+// Index implements model.IndexWaiter.
+func (c *ChangeIndex) Index() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.index
+}
+
+func (c *ChangeIndex) bump() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// WaitForIndex implements model.IndexWaiter. It blocks until the index is
+// greater than after or ctx is done, and returns the index at that point.
+func (c *ChangeIndex) WaitForIndex(ctx context.Context, after uint64) uint64 {
+	if c == nil {
+		<-ctx.Done()
+		return 0
+	}
+	for {
+		c.mu.Lock()
+		index, changed := c.index, c.changed
+		c.mu.Unlock()
+		if index > after {
+			return index
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return c.Index()
+		}
+	}
+}
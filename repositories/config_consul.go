@@ -1,12 +1,181 @@
 package repositories
 
-// import "projekat/model"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"projekat/health"
+	"projekat/model"
+)
 
-// type ConfigConsulRepository struct {
-// }
+// ConfigConsulRepository stores configs in Consul's KV store under
+// configs/{name}/{version} for the default namespace and
+// namespaces/{namespace}/configs/{name}/{version} for the others. The change
+// index only reflects writes made through this process. Consul unescapes
+// "%2F" in key paths, so names containing "/" are refused rather than
+// stored under a nested key.
+type ConfigConsulRepository struct {
+	kv    *consulKV
+	index *ChangeIndex
+}
 
-// // todo: dodaj implementaciju metoda iz interfejsa ConfigRepository
+func NewConfigConsulRepository(address string, index *ChangeIndex) model.ConfigRepository {
+	return &ConfigConsulRepository{
+		kv:    newConsulKV(address),
+		index: index,
+	}
+}
 
-// func NewConfigConsulRepository() model.ConfigRepository {
-// 	return ConfigConsulRepository{}
-// }
+func configConsulPrefix(namespace string) string {
+	return consulNamespacePrefix(namespace) + "configs/"
+}
+
+func configConsulKey(namespace, name string, version int) string {
+	return fmt.Sprintf("%s%s/%d", configConsulPrefix(namespace), url.PathEscape(name), version)
+}
+
+// configConsulMarkKey holds the highest version of name ever stored, outside the
+// configs prefix so that listings do not pick it up.
+func configConsulMarkKey(namespace, name string) string {
+	return consulNamespacePrefix(namespace) + "highest/configs/" + url.PathEscape(name)
+}
+
+// decodeConsulConfig reads a stored config. Values written before namespaces
+// existed have no namespace and belong to the default one.
+func decodeConsulConfig(value []byte) (model.Config, error) {
+	var config model.Config
+	if err := json.Unmarshal(value, &config); err != nil {
+		return model.Config{}, err
+	}
+	config.Namespace = model.NamespaceOrDefault(config.Namespace)
+	return config, nil
+}
+
+// Add implements model.ConfigRepository.
+func (c *ConfigConsulRepository) Add(ctx context.Context, config model.Config) error {
+	if !model.ValidResourceName(config.Name) {
+		return model.ErrInvalidName
+	}
+	config.Namespace = model.NamespaceOrDefault(config.Namespace)
+	value, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	key := configConsulKey(config.Namespace, config.Name, config.Version)
+	created, err := c.kv.createVersion(ctx, key, configConsulMarkKey(config.Namespace, config.Name), config.Version, value)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return model.ErrConfigExists
+	}
+	c.index.bump()
+	return nil
+}
+
+// Get implements model.ConfigRepository.
+func (c *ConfigConsulRepository) Get(ctx context.Context, namespace, name string, version int) (model.Config, error) {
+	pair, ok, err := c.kv.get(ctx, configConsulKey(namespace, name, version))
+	if err != nil {
+		return model.Config{}, err
+	}
+	if !ok {
+		return model.Config{}, model.ErrConfigNotFound
+	}
+	return decodeConsulConfig(pair.Value)
+}
+
+func (c *ConfigConsulRepository) GetAll(ctx context.Context, namespace string) ([]model.Config, error) {
+	pairs, err := c.kv.list(ctx, configConsulPrefix(namespace))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.Config, 0, len(pairs))
+	for _, pair := range pairs {
+		config, err := decodeConsulConfig(pair.Value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, config)
+	}
+	sortConfigs(result)
+	return result, nil
+}
+
+// GetVersions implements model.ConfigRepository by listing only the
+// .../configs/{name}/ prefix.
+func (c *ConfigConsulRepository) GetVersions(ctx context.Context, namespace, name string) ([]model.Config, error) {
+	pairs, err := c.kv.listChildren(ctx, configConsulPrefix(namespace)+url.PathEscape(name)+"/")
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, model.ErrConfigNotFound
+	}
+	result := make([]model.Config, 0, len(pairs))
+	for _, pair := range pairs {
+		config, err := decodeConsulConfig(pair.Value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, config)
+	}
+	sortConfigVersions(result)
+	return result, nil
+}
+
+// Search implements model.ConfigRepository. Consul has no secondary
+// indexes, so this scans every config in the namespace: one recursive read
+// of its prefix, filtered here. Label index keys are deliberately not kept
+// next to the configs. To stay correct they would have to change in the
+// same transaction as the config, and a transaction holds at most 64
+// operations, one per label value; groups, indexed per config, easily need
+// more. An index written outside the transaction can drift after a failed
+// write and then hides versions from Search, which is worse than a slow
+// scan. Deployments that search large namespaces should use another backend.
+func (c *ConfigConsulRepository) Search(ctx context.Context, namespace string, selector model.Selector) ([]model.Config, error) {
+	configs, err := c.GetAll(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.Config, 0)
+	for _, config := range configs {
+		if selector.Matches(config.Labels) {
+			result = append(result, config)
+		}
+	}
+	return result, nil
+}
+
+func (c *ConfigConsulRepository) Delete(ctx context.Context, namespace, name string, version int) error {
+	key := configConsulKey(namespace, name, version)
+	pair, ok, err := c.kv.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return model.ErrConfigNotFound
+	}
+	deleted, err := c.kv.deleteCAS(ctx, key, pair.ModifyIndex)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return model.ErrConcurrentUpdate
+	}
+	c.index.bump()
+	return nil
+}
+
+// HighestVersion implements model.ConfigRepository. Versions stored before the
+// mark existed are not counted.
+func (c *ConfigConsulRepository) HighestVersion(ctx context.Context, namespace, name string) (int, error) {
+	return c.kv.highestVersion(ctx, configConsulMarkKey(namespace, name))
+}
+
+// RegisterHealthChecks implements health.Registrar: the server is not ready
+// while Consul is unreachable or has no leader.
+func (r *ConfigConsulRepository) RegisterHealthChecks(c *health.Checker) {
+	c.Register("consul", r.kv.ping)
+}
@@ -0,0 +1,286 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"projekat/model"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeConsulKV implements the parts of Consul's KV API the repositories
+// use, including check-and-set. Like Consul it stores keys unescaped.
+type fakeConsulKV struct {
+	mu    sync.Mutex
+	pairs map[string]consulKVPair
+	index uint64
+	// beforeDelete, if set, runs before a DELETE is applied, e.g. to
+	// simulate a concurrent writer.
+	beforeDelete func(key string)
+}
+
+func newFakeConsul(t *testing.T) (*fakeConsulKV, string) {
+	fake := &fakeConsulKV{pairs: make(map[string]consulKVPair)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server.URL
+}
+
+func (f *fakeConsulKV) put(key string, value []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.index++
+	f.pairs[key] = consulKVPair{Key: key, Value: value, ModifyIndex: f.index}
+}
+
+func (f *fakeConsulKV) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.pairs))
+	for key := range f.pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeConsulKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/status/leader" {
+		fmt.Fprint(w, `"127.0.0.1:8300"`)
+		return
+	}
+	if r.URL.Path == "/v1/txn" {
+		f.serveTxn(w, r)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	query := r.URL.Query()
+
+	if r.Method == http.MethodDelete && f.beforeDelete != nil {
+		f.beforeDelete(key)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		var pairs []consulKVPair
+		for k, pair := range f.pairs {
+			if k == key || (query.Has("recurse") && strings.HasPrefix(k, key)) {
+				pairs = append(pairs, pair)
+			}
+		}
+		if len(pairs) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(pairs)
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		pair, exists := f.pairs[key]
+		if cas := query.Get("cas"); cas != "" && !casMatches(cas, pair, exists) {
+			fmt.Fprint(w, "false")
+			return
+		}
+		f.index++
+		f.pairs[key] = consulKVPair{Key: key, Value: body, ModifyIndex: f.index}
+		fmt.Fprint(w, "true")
+	case http.MethodDelete:
+		pair, exists := f.pairs[key]
+		if cas := query.Get("cas"); cas != "" && !casMatches(cas, pair, exists) {
+			fmt.Fprint(w, "false")
+			return
+		}
+		delete(f.pairs, key)
+		fmt.Fprint(w, "true")
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// serveTxn applies a transaction of cas operations, all or none of them.
+func (f *fakeConsulKV) serveTxn(w http.ResponseWriter, r *http.Request) {
+	var ops []struct{ KV consulTxnOp }
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, op := range ops {
+		pair, exists := f.pairs[op.KV.Key]
+		if !casMatches(strconv.FormatUint(op.KV.Index, 10), pair, exists) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+	}
+	for _, op := range ops {
+		f.index++
+		f.pairs[op.KV.Key] = consulKVPair{Key: op.KV.Key, Value: op.KV.Value, ModifyIndex: f.index}
+	}
+	fmt.Fprint(w, "{}")
+}
+
+// casMatches applies Consul's check-and-set rule: cas=0 only creates, any
+// other index must equal the key's current ModifyIndex.
+func casMatches(cas string, pair consulKVPair, exists bool) bool {
+	index, err := strconv.ParseUint(cas, 10, 64)
+	if err != nil {
+		return false
+	}
+	if index == 0 {
+		return !exists
+	}
+	return exists && pair.ModifyIndex == index
+}
+
+func TestConfigConsulCreateConflict(t *testing.T) {
+	_, address := newFakeConsul(t)
+	repo := NewConfigConsulRepository(address, NewChangeIndex())
+	ctx := context.Background()
+
+	config := model.NewConfig("db", 1)
+	config.AddParameter("host", "a")
+	if err := repo.Add(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	config.Parameters[0].Value = "b"
+	if err := repo.Add(ctx, config); !errors.Is(err, model.ErrConfigExists) {
+		t.Fatalf("second Add returned %v, want ErrConfigExists", err)
+	}
+	stored, err := repo.Get(ctx, model.DefaultNamespace, "db", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Parameters[0].Value != "a" {
+		t.Errorf("the conflicting Add overwrote the stored version")
+	}
+}
+
+func TestConfigConsulDeleteConflict(t *testing.T) {
+	fake, address := newFakeConsul(t)
+	repo := NewConfigConsulRepository(address, NewChangeIndex())
+	ctx := context.Background()
+
+	if err := repo.Add(ctx, model.NewConfig("db", 1)); err != nil {
+		t.Fatal(err)
+	}
+	// Another writer replaces the version between the read and the delete.
+	fake.beforeDelete = func(key string) {
+		fake.put(key, []byte(`{"name":"db","version":1}`))
+	}
+	if err := repo.Delete(ctx, model.DefaultNamespace, "db", 1); !errors.Is(err, model.ErrConcurrentUpdate) {
+		t.Fatalf("Delete returned %v, want ErrConcurrentUpdate", err)
+	}
+	if _, err := repo.Get(ctx, model.DefaultNamespace, "db", 1); err != nil {
+		t.Errorf("the version was deleted despite the conflict: %v", err)
+	}
+
+	fake.beforeDelete = nil
+	if err := repo.Delete(ctx, model.DefaultNamespace, "db", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, model.DefaultNamespace, "db", 1); !errors.Is(err, model.ErrConfigNotFound) {
+		t.Errorf("deleting again returned %v, want ErrConfigNotFound", err)
+	}
+}
+
+func TestConfigConsulHighestVersion(t *testing.T) {
+	_, address := newFakeConsul(t)
+	repo := NewConfigConsulRepository(address, NewChangeIndex())
+	ctx := context.Background()
+
+	for _, v := range []int{1, 3, 2} {
+		if err := repo.Add(ctx, model.NewConfig("db", v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Delete(ctx, model.DefaultNamespace, "db", 3); err != nil {
+		t.Fatal(err)
+	}
+	highest, err := repo.HighestVersion(ctx, model.DefaultNamespace, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if highest != 3 {
+		t.Errorf("HighestVersion() = %d after deleting version 3, want 3", highest)
+	}
+	if highest, _ := repo.HighestVersion(ctx, model.DefaultNamespace, "cache"); highest != 0 {
+		t.Errorf("HighestVersion() = %d for a name never stored, want 0", highest)
+	}
+}
+
+func TestConfigConsulLegacyPrefix(t *testing.T) {
+	fake, address := newFakeConsul(t)
+	repo := NewConfigConsulRepository(address, NewChangeIndex())
+	ctx := context.Background()
+
+	// Written before namespaces existed: no namespace in key or value.
+	fake.put("configs/db/1", []byte(`{"name":"db","version":1}`))
+	team := model.NewConfig("db", 2)
+	team.Namespace = "team-a"
+	if err := repo.Add(ctx, team); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"configs/db/1", "namespaces/team-a/configs/db/2", "namespaces/team-a/highest/configs/db"}
+	if keys := fake.keys(); strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("stored keys %v, want %v", keys, want)
+	}
+	legacy, err := repo.Get(ctx, model.DefaultNamespace, "db", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Namespace != model.DefaultNamespace {
+		t.Errorf("legacy config has namespace %q, want %q", legacy.Namespace, model.DefaultNamespace)
+	}
+	for namespace, version := range map[string]int{model.DefaultNamespace: 1, "team-a": 2} {
+		all, err := repo.GetAll(ctx, namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 1 || all[0].Version != version {
+			t.Errorf("GetAll(%s) = %+v, want only version %d", namespace, all, version)
+		}
+	}
+}
+
+func TestConfigConsulRejectsSlashInName(t *testing.T) {
+	fake, address := newFakeConsul(t)
+	repo := NewConfigConsulRepository(address, NewChangeIndex())
+	ctx := context.Background()
+
+	for _, name := range []string{"db", "db x"} {
+		if err := repo.Add(ctx, model.NewConfig(name, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Add(ctx, model.NewConfig("db/x", 1)); !errors.Is(err, model.ErrInvalidName) {
+		t.Fatalf("Add(db/x) returned %v, want ErrInvalidName", err)
+	}
+	// A key nested under db/ by some other writer is not a version of db.
+	fake.put("configs/db/x/1", []byte(`{"name":"db/x","version":1}`))
+	versions, err := repo.GetVersions(ctx, model.DefaultNamespace, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0].Name != "db" {
+		t.Errorf("GetVersions(db) = %+v, want only db version 1", versions)
+	}
+	// Names that are escaped in the URL still match the unescaped keys.
+	versions, err = repo.GetVersions(ctx, model.DefaultNamespace, "db x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0].Name != "db x" {
+		t.Errorf("GetVersions(db x) = %+v, want only db x version 1", versions)
+	}
+}
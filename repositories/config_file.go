@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"projekat/model"
+)
+
+// ConfigFileRepository keeps configs in memory and makes them durable with a
+// write-ahead log in FileOptions.Dir that is replayed on startup.
+type ConfigFileRepository struct {
+	*walStore[model.Config]
+	inner *ConfigInMemRepository
+}
+
+func NewConfigFileRepository(opts FileOptions, index *ChangeIndex) (*ConfigFileRepository, error) {
+	inner := newConfigInMemRepository(index)
+	store, err := openWALStore[model.Config]("configs", opts, inner, model.ErrConfigExists)
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigFileRepository{walStore: store, inner: inner}, nil
+}
+
+// Add implements model.ConfigRepository.
+func (r *ConfigFileRepository) Add(ctx context.Context, config model.Config) error {
+	config.Namespace = model.NamespaceOrDefault(config.Namespace)
+	return r.add(ctx, config.Namespace, config.Name, config.Version, config)
+}
+
+// Get implements model.ConfigRepository.
+func (r *ConfigFileRepository) Get(ctx context.Context, namespace, name string, version int) (model.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.Get(ctx, namespace, name, version)
+}
+
+func (r *ConfigFileRepository) GetAll(ctx context.Context, namespace string) ([]model.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.GetAll(ctx, namespace)
+}
+
+func (r *ConfigFileRepository) GetVersions(ctx context.Context, namespace, name string) ([]model.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.GetVersions(ctx, namespace, name)
+}
+
+func (r *ConfigFileRepository) Search(ctx context.Context, namespace string, selector model.Selector) ([]model.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.Search(ctx, namespace, selector)
+}
+
+func (r *ConfigFileRepository) Delete(ctx context.Context, namespace, name string, version int) error {
+	return r.delete(ctx, namespace, name, version)
+}
+
+// HighestVersion implements model.ConfigRepository.
+func (r *ConfigFileRepository) HighestVersion(ctx context.Context, namespace, name string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.HighestVersion(ctx, namespace, name)
+}
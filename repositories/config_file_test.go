@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"projekat/model"
+	"testing"
+)
+
+func TestConfigFileRepositoryReplay(t *testing.T) {
+	for _, snapshotEvery := range []int{0, 3, 8} {
+		dir := t.TempDir()
+		opts := FileOptions{Dir: dir, SnapshotEvery: snapshotEvery}
+		ctx := context.Background()
+
+		repo, err := NewConfigFileRepository(opts, NewChangeIndex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for v := 1; v <= 5; v++ {
+			config := model.NewConfig("db", v)
+			config.Namespace = "team-a"
+			config.Labels = []model.Label{{Key: "env", Value: "prod"}}
+			if err := repo.Add(ctx, config); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := repo.Add(ctx, model.NewConfig("cache", 1)); err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []int{2, 5} {
+			if err := repo.Delete(ctx, "team-a", "db", v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := repo.Add(ctx, model.NewConfig("cache", 1)); !errors.Is(err, model.ErrConfigExists) {
+			t.Errorf("adding a version twice: %v, want ErrConfigExists", err)
+		}
+		if err := repo.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = os.Stat(filepath.Join(dir, "configs.snapshot"))
+		if compacted := err == nil; compacted != (snapshotEvery > 0) {
+			t.Errorf("SnapshotEvery=%d: snapshot written = %v", snapshotEvery, compacted)
+		}
+
+		repo, err = NewConfigFileRepository(opts, NewChangeIndex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions, err := repo.GetVersions(ctx, "team-a", "db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 3 || versions[0].Version != 4 {
+			t.Errorf("SnapshotEvery=%d: replayed %d versions of db, latest %d", snapshotEvery, len(versions), versions[0].Version)
+		}
+		if _, err := repo.Get(ctx, "team-a", "db", 2); err == nil {
+			t.Errorf("SnapshotEvery=%d: deleted version came back", snapshotEvery)
+		}
+		if _, err := repo.Get(ctx, model.DefaultNamespace, "cache", 1); err != nil {
+			t.Errorf("SnapshotEvery=%d: %v", snapshotEvery, err)
+		}
+		if highest, _ := repo.HighestVersion(ctx, "team-a", "db"); highest != 5 {
+			t.Errorf("SnapshotEvery=%d: highest version %d after deleting 5, want 5", snapshotEvery, highest)
+		}
+		selector, _ := model.ParseSelector("env=prod")
+		if found, _ := repo.Search(ctx, "team-a", selector); len(found) != 3 {
+			t.Errorf("SnapshotEvery=%d: search after replay found %d versions", snapshotEvery, len(found))
+		}
+		repo.Close()
+	}
+}
+
+func TestConfigGroupFileRepositoryReplay(t *testing.T) {
+	opts := FileOptions{Dir: t.TempDir(), SnapshotEvery: 2}
+	ctx := context.Background()
+
+	repo, err := NewConfigGroupFileRepository(opts, NewChangeIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v := 1; v <= 3; v++ {
+		group := model.NewConfigGroup("web", v)
+		group.AddConfig(model.GroupConfig{Name: "db", Labels: []model.Label{{Key: "env", Value: "dev"}}})
+		if err := repo.Add(ctx, group); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Delete(ctx, model.DefaultNamespace, "web", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Add(ctx, model.NewConfigGroup("web", 1)); !errors.Is(err, model.ErrGroupExists) {
+		t.Errorf("adding a version twice: %v, want ErrGroupExists", err)
+	}
+	repo.Close()
+
+	repo, err = NewConfigGroupFileRepository(opts, NewChangeIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	versions, err := repo.GetVersions(ctx, model.DefaultNamespace, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 {
+		t.Errorf("replayed %d versions, latest %d; want 2 and 2", len(versions), versions[0].Version)
+	}
+	selector, _ := model.ParseSelector("env=dev")
+	if matches, _ := repo.Search(ctx, model.DefaultNamespace, selector); len(matches) != 2 {
+		t.Errorf("search after replay found %d matches, want 2", len(matches))
+	}
+}
@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"projekat/health"
+	"projekat/model"
+)
+
+// ConfigGroupConsulRepository stores config groups in Consul's KV store under
+// groups/{name}/{version} for the default namespace and
+// namespaces/{namespace}/groups/{name}/{version} for the others.
+type ConfigGroupConsulRepository struct {
+	kv    *consulKV
+	index *ChangeIndex
+}
+
+func NewConfigGroupConsulRepository(address string, index *ChangeIndex) model.ConfigGroupRepository {
+	return &ConfigGroupConsulRepository{
+		kv:    newConsulKV(address),
+		index: index,
+	}
+}
+
+func groupConsulPrefix(namespace string) string {
+	return consulNamespacePrefix(namespace) + "groups/"
+}
+
+func groupConsulKey(namespace, name string, version int) string {
+	return fmt.Sprintf("%s%s/%d", groupConsulPrefix(namespace), url.PathEscape(name), version)
+}
+
+// groupConsulMarkKey holds the highest version of name ever stored, outside the
+// groups prefix so that listings do not pick it up.
+func groupConsulMarkKey(namespace, name string) string {
+	return consulNamespacePrefix(namespace) + "highest/groups/" + url.PathEscape(name)
+}
+
+// decodeConsulGroup reads a stored group. Values written before namespaces
+// existed have no namespace and belong to the default one.
+func decodeConsulGroup(value []byte) (model.ConfigGroup, error) {
+	var group model.ConfigGroup
+	if err := json.Unmarshal(value, &group); err != nil {
+		return model.ConfigGroup{}, err
+	}
+	group.Namespace = model.NamespaceOrDefault(group.Namespace)
+	return group, nil
+}
+
+// Add implements model.ConfigGroupRepository.
+func (r *ConfigGroupConsulRepository) Add(ctx context.Context, group model.ConfigGroup) error {
+	if !model.ValidResourceName(group.Name) {
+		return model.ErrInvalidName
+	}
+	group.Namespace = model.NamespaceOrDefault(group.Namespace)
+	value, err := json.Marshal(group)
+	if err != nil {
+		return err
+	}
+	key := groupConsulKey(group.Namespace, group.Name, group.Version)
+	created, err := r.kv.createVersion(ctx, key, groupConsulMarkKey(group.Namespace, group.Name), group.Version, value)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return model.ErrGroupExists
+	}
+	r.index.bump()
+	return nil
+}
+
+// Get implements model.ConfigGroupRepository.
+func (r *ConfigGroupConsulRepository) Get(ctx context.Context, namespace, name string, version int) (model.ConfigGroup, error) {
+	pair, ok, err := r.kv.get(ctx, groupConsulKey(namespace, name, version))
+	if err != nil {
+		return model.ConfigGroup{}, err
+	}
+	if !ok {
+		return model.ConfigGroup{}, model.ErrGroupNotFound
+	}
+	return decodeConsulGroup(pair.Value)
+}
+
+func (r *ConfigGroupConsulRepository) GetAll(ctx context.Context, namespace string) ([]model.ConfigGroup, error) {
+	pairs, err := r.kv.list(ctx, groupConsulPrefix(namespace))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.ConfigGroup, 0, len(pairs))
+	for _, pair := range pairs {
+		group, err := decodeConsulGroup(pair.Value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, group)
+	}
+	sortGroups(result)
+	return result, nil
+}
+
+// GetVersions implements model.ConfigGroupRepository by listing only the
+// .../groups/{name}/ prefix.
+func (r *ConfigGroupConsulRepository) GetVersions(ctx context.Context, namespace, name string) ([]model.ConfigGroup, error) {
+	pairs, err := r.kv.listChildren(ctx, groupConsulPrefix(namespace)+url.PathEscape(name)+"/")
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, model.ErrGroupNotFound
+	}
+	result := make([]model.ConfigGroup, 0, len(pairs))
+	for _, pair := range pairs {
+		group, err := decodeConsulGroup(pair.Value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, group)
+	}
+	sortGroupVersions(result)
+	return result, nil
+}
+
+// Search implements model.ConfigGroupRepository. Like
+// ConfigConsulRepository.Search it scans every group in the namespace
+// rather than keeping label index keys, which could not be updated
+// atomically with a group whose configs carry more than 64 labels in all.
+func (r *ConfigGroupConsulRepository) Search(ctx context.Context, namespace string, selector model.Selector) ([]model.GroupConfigMatch, error) {
+	groups, err := r.GetAll(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.GroupConfigMatch, 0)
+	for _, group := range groups {
+		for _, config := range group.Configs {
+			if selector.Matches(config.Labels) {
+				result = append(result, model.GroupConfigMatch{
+					Group:        group.Name,
+					GroupVersion: group.Version,
+					Config:       config,
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (r *ConfigGroupConsulRepository) Delete(ctx context.Context, namespace, name string, version int) error {
+	key := groupConsulKey(namespace, name, version)
+	pair, ok, err := r.kv.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return model.ErrGroupNotFound
+	}
+	deleted, err := r.kv.deleteCAS(ctx, key, pair.ModifyIndex)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return model.ErrConcurrentUpdate
+	}
+	r.index.bump()
+	return nil
+}
+
+// HighestVersion implements model.ConfigGroupRepository. Versions stored before the
+// mark existed are not counted.
+func (r *ConfigGroupConsulRepository) HighestVersion(ctx context.Context, namespace, name string) (int, error) {
+	return r.kv.highestVersion(ctx, groupConsulMarkKey(namespace, name))
+}
+
+// RegisterHealthChecks implements health.Registrar: the server is not ready
+// while Consul is unreachable or has no leader.
+func (r *ConfigGroupConsulRepository) RegisterHealthChecks(c *health.Checker) {
+	c.Register("consul", r.kv.ping)
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"projekat/model"
+)
+
+// ConfigGroupFileRepository keeps config groups in memory and makes them
+// durable with a write-ahead log in FileOptions.Dir that is replayed on startup.
+type ConfigGroupFileRepository struct {
+	*walStore[model.ConfigGroup]
+	inner *ConfigGroupInMemRepository
+}
+
+func NewConfigGroupFileRepository(opts FileOptions, index *ChangeIndex) (*ConfigGroupFileRepository, error) {
+	inner := newConfigGroupInMemRepository(index)
+	store, err := openWALStore[model.ConfigGroup]("groups", opts, inner, model.ErrGroupExists)
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigGroupFileRepository{walStore: store, inner: inner}, nil
+}
+
+// Add implements model.ConfigGroupRepository.
+func (r *ConfigGroupFileRepository) Add(ctx context.Context, group model.ConfigGroup) error {
+	group.Namespace = model.NamespaceOrDefault(group.Namespace)
+	return r.add(ctx, group.Namespace, group.Name, group.Version, group)
+}
+
+// Get implements model.ConfigGroupRepository.
+func (r *ConfigGroupFileRepository) Get(ctx context.Context, namespace, name string, version int) (model.ConfigGroup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.Get(ctx, namespace, name, version)
+}
+
+func (r *ConfigGroupFileRepository) GetAll(ctx context.Context, namespace string) ([]model.ConfigGroup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.GetAll(ctx, namespace)
+}
+
+func (r *ConfigGroupFileRepository) GetVersions(ctx context.Context, namespace, name string) ([]model.ConfigGroup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.GetVersions(ctx, namespace, name)
+}
+
+func (r *ConfigGroupFileRepository) Search(ctx context.Context, namespace string, selector model.Selector) ([]model.GroupConfigMatch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.Search(ctx, namespace, selector)
+}
+
+func (r *ConfigGroupFileRepository) Delete(ctx context.Context, namespace, name string, version int) error {
+	return r.delete(ctx, namespace, name, version)
+}
+
+// HighestVersion implements model.ConfigGroupRepository.
+func (r *ConfigGroupFileRepository) HighestVersion(ctx context.Context, namespace, name string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.HighestVersion(ctx, namespace, name)
+}
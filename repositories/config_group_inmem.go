@@ -1,53 +1,188 @@
 package repositories
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"projekat/model"
+	"sync"
 )
 
+// ConfigGroupInMemRepository is safe for concurrent use. Groups are indexed
+// by namespace and name and then by version, and the configs they contain
+// by label per namespace.
 type ConfigGroupInMemRepository struct {
-	groups map[string]model.ConfigGroup
+	mu      sync.RWMutex
+	groups  map[resourceKey]map[int]model.ConfigGroup
+	labels  map[string]*labelIndex
+	highest highestVersions
+	index   *ChangeIndex
 }
 
-func NewConfigGroupInMemRepository() model.ConfigGroupRepository {
+func NewConfigGroupInMemRepository(index *ChangeIndex) model.ConfigGroupRepository {
+	return newConfigGroupInMemRepository(index)
+}
+
+func newConfigGroupInMemRepository(index *ChangeIndex) *ConfigGroupInMemRepository {
 	return &ConfigGroupInMemRepository{
-		groups: make(map[string]model.ConfigGroup),
+		groups:  make(map[resourceKey]map[int]model.ConfigGroup),
+		labels:  make(map[string]*labelIndex),
+		highest: make(highestVersions),
+		index:   index,
 	}
 }
 
-func (r *ConfigGroupInMemRepository) Add(group model.ConfigGroup) error {
-	key := fmt.Sprintf("%s/%d", group.Name, group.Version)
-	if _, exists := r.groups[key]; exists {
-		return errors.New("config group already exists")
+func (r *ConfigGroupInMemRepository) Add(ctx context.Context, group model.ConfigGroup) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	group.Namespace = model.NamespaceOrDefault(group.Namespace)
+	key := resourceKey{namespace: group.Namespace, name: group.Name}
+	versions, ok := r.groups[key]
+	if !ok {
+		versions = make(map[int]model.ConfigGroup)
+		r.groups[key] = versions
+	}
+	if _, exists := versions[group.Version]; exists {
+		return model.ErrGroupExists
+	}
+	versions[group.Version] = group
+	r.highest.raise(key, group.Version)
+
+	labels, ok := r.labels[group.Namespace]
+	if !ok {
+		labels = newLabelIndex()
+		r.labels[group.Namespace] = labels
+	}
+	for _, config := range group.Configs {
+		labels.add(indexRef{name: group.Name, version: group.Version, config: config.Name}, config.Labels)
 	}
-	r.groups[key] = group
+	r.index.bump()
 	return nil
 }
 
-func (r *ConfigGroupInMemRepository) Get(name string, version int) (model.ConfigGroup, error) {
-	key := fmt.Sprintf("%s/%d", name, version)
-	group, ok := r.groups[key]
+func (r *ConfigGroupInMemRepository) Get(ctx context.Context, namespace, name string, version int) (model.ConfigGroup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	group, ok := r.groups[resourceKey{namespace: namespace, name: name}][version]
 	if !ok {
-		return model.ConfigGroup{}, errors.New("config group not found")
+		return model.ConfigGroup{}, model.ErrGroupNotFound
 	}
 	return group, nil
 }
 
-func (r *ConfigGroupInMemRepository) GetAll() ([]model.ConfigGroup, error) {
-	groups := make([]model.ConfigGroup, 0, len(r.groups))
-	for _, group := range r.groups {
+func (r *ConfigGroupInMemRepository) GetAll(ctx context.Context, namespace string) ([]model.ConfigGroup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	groups := make([]model.ConfigGroup, 0)
+	for key, versions := range r.groups {
+		if key.namespace != namespace {
+			continue
+		}
+		for _, group := range versions {
+			groups = append(groups, group)
+		}
+	}
+	sortGroups(groups)
+	return groups, nil
+}
+
+// all returns the groups of every namespace, for snapshots.
+func (r *ConfigGroupInMemRepository) all() []model.ConfigGroup {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	groups := make([]model.ConfigGroup, 0)
+	for _, versions := range r.groups {
+		for _, group := range versions {
+			groups = append(groups, group)
+		}
+	}
+	sortGroups(groups)
+	return groups
+}
+
+func (r *ConfigGroupInMemRepository) GetVersions(ctx context.Context, namespace, name string) ([]model.ConfigGroup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions, ok := r.groups[resourceKey{namespace: namespace, name: name}]
+	if !ok {
+		return nil, model.ErrGroupNotFound
+	}
+	groups := make([]model.ConfigGroup, 0, len(versions))
+	for _, group := range versions {
 		groups = append(groups, group)
 	}
+	sortGroupVersions(groups)
 	return groups, nil
 }
 
+// Search implements model.ConfigGroupRepository using the label index.
+func (r *ConfigGroupInMemRepository) Search(ctx context.Context, namespace string, selector model.Selector) ([]model.GroupConfigMatch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]model.GroupConfigMatch, 0)
+	labels, ok := r.labels[namespace]
+	if !ok {
+		return result, nil
+	}
+	for _, ref := range labels.candidates(selector) {
+		group := r.groups[resourceKey{namespace: namespace, name: ref.name}][ref.version]
+		config, ok := group.GetConfig(ref.config)
+		if ok && selector.Matches(config.Labels) {
+			result = append(result, model.GroupConfigMatch{
+				Group:        ref.name,
+				GroupVersion: ref.version,
+				Config:       config,
+			})
+		}
+	}
+	return result, nil
+}
+
+func (r *ConfigGroupInMemRepository) Delete(ctx context.Context, namespace, name string, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-func (r *ConfigGroupInMemRepository) Delete(name string, version int) error {
-	key := fmt.Sprintf("%s/%d", name, version)
-	if _, exists := r.groups[key]; !exists {
-		return errors.New("config group not found")
+	key := resourceKey{namespace: namespace, name: name}
+	versions := r.groups[key]
+	group, exists := versions[version]
+	if !exists {
+		return model.ErrGroupNotFound
+	}
+	delete(versions, version)
+	for _, config := range group.Configs {
+		r.labels[namespace].remove(indexRef{name: name, version: version, config: config.Name}, config.Labels)
+	}
+	if len(versions) == 0 {
+		delete(r.groups, key)
 	}
-	delete(r.groups, key)
+	r.index.bump()
 	return nil
 }
+
+// HighestVersion implements model.ConfigGroupRepository.
+func (r *ConfigGroupInMemRepository) HighestVersion(ctx context.Context, namespace, name string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.highest[resourceKey{namespace: namespace, name: name}], nil
+}
+
+// marks returns the highest versions that snapshots must keep.
+func (r *ConfigGroupInMemRepository) marks() []versionMark {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.highest.marks(func(key resourceKey, version int) bool {
+		_, ok := r.groups[key][version]
+		return ok
+	})
+}
+
+// raiseHighest restores a mark read from a snapshot.
+func (r *ConfigGroupInMemRepository) raiseHighest(mark versionMark) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.highest.raise(resourceKey{namespace: mark.Namespace, name: mark.Name}, mark.Version)
+}
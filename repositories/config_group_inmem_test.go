@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"projekat/model"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// TestConfigGroupInMemConcurrentAccess is the group counterpart of
+// TestConfigInMemConcurrentAccess.
+func TestConfigGroupInMemConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	repo := NewConfigGroupInMemRepository(NewChangeIndex())
+	selector, err := model.ParseSelector("parity=even")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*stressWorkers)
+	for w := 0; w < stressWorkers; w++ {
+		name := fmt.Sprintf("group-%d", w%2)
+		namespace := fmt.Sprintf("ns-%d", w%2)
+		first := w / 2 * stressVersions
+
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for v := first; v < first+stressVersions; v++ {
+				group := model.NewConfigGroup(name, v)
+				group.Namespace = namespace
+				config := model.NewGroupConfig("app")
+				config.AddParameter("version", fmt.Sprint(v))
+				config.AddLabel("parity", parity(v))
+				group.AddConfig(config)
+				if err := repo.Add(ctx, group); err != nil {
+					errs <- fmt.Errorf("add %s/%d: %w", name, v, err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for v := first; v < first+stressVersions; v++ {
+				if _, err := repo.Get(ctx, namespace, name, v); err != nil && err != model.ErrGroupNotFound {
+					errs <- err
+					return
+				}
+				if _, err := repo.GetAll(ctx, namespace); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := repo.GetVersions(ctx, namespace, name); err != nil && err != model.ErrGroupNotFound {
+					errs <- err
+					return
+				}
+				if _, err := repo.Search(ctx, namespace, selector); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			// Odd versions are deleted as soon as they exist.
+			for v := first + 1; v < first+stressVersions; v += 2 {
+				for repo.Delete(ctx, namespace, name, v) == model.ErrGroupNotFound {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for _, namespace := range []string{"ns-0", "ns-1"} {
+		all, err := repo.GetAll(ctx, namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := stressWorkers / 2 * stressVersions / 2
+		if len(all) != want {
+			t.Errorf("GetAll(%s) returned %d groups, want %d", namespace, len(all), want)
+		}
+		for _, group := range all {
+			if group.Version%2 != 0 {
+				t.Errorf("deleted version %d of %s is still listed", group.Version, group.Name)
+			}
+		}
+		matches, err := repo.Search(ctx, namespace, selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != want {
+			t.Errorf("Search(%s) returned %d matches, want %d", namespace, len(matches), want)
+		}
+	}
+}
@@ -1,58 +1,188 @@
 package repositories
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"projekat/model"
+	"sync"
 )
 
+// resourceKey identifies all versions of a config or group.
+type resourceKey struct {
+	namespace string
+	name      string
+}
+
+// ConfigInMemRepository is safe for concurrent use. Configs are indexed by
+// namespace and name and then by version so all versions of a name can be
+// listed cheaply, and by label per namespace for Search.
 type ConfigInMemRepository struct {
-	configs map[string]model.Config
+	mu      sync.RWMutex
+	configs map[resourceKey]map[int]model.Config
+	labels  map[string]*labelIndex
+	highest highestVersions
+	index   *ChangeIndex
 }
 
-// todo: dodaj implementaciju metoda iz interfejsa ConfigRepository
+func NewConfigInMemRepository(index *ChangeIndex) model.ConfigRepository {
+	return newConfigInMemRepository(index)
+}
 
-func NewConfigInMemRepository() model.ConfigRepository {
+func newConfigInMemRepository(index *ChangeIndex) *ConfigInMemRepository {
 	return &ConfigInMemRepository{
-		configs: make(map[string]model.Config),
+		configs: make(map[resourceKey]map[int]model.Config),
+		labels:  make(map[string]*labelIndex),
+		highest: make(highestVersions),
+		index:   index,
 	}
 }
 
 // Add implements model.ConfigRepository.
-func (c *ConfigInMemRepository) Add(config model.Config) error {
-	key := fmt.Sprintf("%s/%d", config.Name, config.Version)
-	if _, exists := c.configs[key]; exists {
-		return errors.New("config already exists")
+func (c *ConfigInMemRepository) Add(ctx context.Context, config model.Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	config.Namespace = model.NamespaceOrDefault(config.Namespace)
+	key := resourceKey{namespace: config.Namespace, name: config.Name}
+	versions, ok := c.configs[key]
+	if !ok {
+		versions = make(map[int]model.Config)
+		c.configs[key] = versions
+	}
+	if _, exists := versions[config.Version]; exists {
+		return model.ErrConfigExists
+	}
+	versions[config.Version] = config
+	c.highest.raise(key, config.Version)
+
+	labels, ok := c.labels[config.Namespace]
+	if !ok {
+		labels = newLabelIndex()
+		c.labels[config.Namespace] = labels
 	}
-	c.configs[key] = config
+	labels.add(indexRef{name: config.Name, version: config.Version}, config.Labels)
+	c.index.bump()
 	return nil
 }
 
 // Get implements model.ConfigRepository.
-func (c *ConfigInMemRepository) Get(name string, version int) (model.Config, error) {
-	key := fmt.Sprintf("%s/%d", name, version)
-	config, ok := c.configs[key]
+func (c *ConfigInMemRepository) Get(ctx context.Context, namespace, name string, version int) (model.Config, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	config, ok := c.configs[resourceKey{namespace: namespace, name: name}][version]
 	if !ok {
-		return model.Config{}, errors.New("config not found")
+		return model.Config{}, model.ErrConfigNotFound
 	}
 	return config, nil
 }
 
-func (c *ConfigInMemRepository) GetAll() ([]model.Config, error) {
-	result := make([]model.Config, 0, len(c.configs))
-	for _, cfg := range c.configs {
+func (c *ConfigInMemRepository) GetAll(ctx context.Context, namespace string) ([]model.Config, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]model.Config, 0)
+	for key, versions := range c.configs {
+		if key.namespace != namespace {
+			continue
+		}
+		for _, cfg := range versions {
+			result = append(result, cfg)
+		}
+	}
+	sortConfigs(result)
+	return result, nil
+}
+
+// all returns the configs of every namespace, for snapshots.
+func (c *ConfigInMemRepository) all() []model.Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]model.Config, 0)
+	for _, versions := range c.configs {
+		for _, cfg := range versions {
+			result = append(result, cfg)
+		}
+	}
+	sortConfigs(result)
+	return result
+}
+
+// GetVersions implements model.ConfigRepository.
+func (c *ConfigInMemRepository) GetVersions(ctx context.Context, namespace, name string) ([]model.Config, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	versions, ok := c.configs[resourceKey{namespace: namespace, name: name}]
+	if !ok {
+		return nil, model.ErrConfigNotFound
+	}
+	result := make([]model.Config, 0, len(versions))
+	for _, cfg := range versions {
 		result = append(result, cfg)
 	}
+	sortConfigVersions(result)
 	return result, nil
 }
 
+// Search implements model.ConfigRepository using the label index.
+func (c *ConfigInMemRepository) Search(ctx context.Context, namespace string, selector model.Selector) ([]model.Config, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
+	result := make([]model.Config, 0)
+	labels, ok := c.labels[namespace]
+	if !ok {
+		return result, nil
+	}
+	for _, ref := range labels.candidates(selector) {
+		config := c.configs[resourceKey{namespace: namespace, name: ref.name}][ref.version]
+		if selector.Matches(config.Labels) {
+			result = append(result, config)
+		}
+	}
+	return result, nil
+}
 
-func (c *ConfigInMemRepository) Delete(name string, version int) error {
-	key := fmt.Sprintf("%s/%d", name, version)
-	if _, exists := c.configs[key]; !exists {
-		return errors.New("config not found")
+func (c *ConfigInMemRepository) Delete(ctx context.Context, namespace, name string, version int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := resourceKey{namespace: namespace, name: name}
+	versions := c.configs[key]
+	config, exists := versions[version]
+	if !exists {
+		return model.ErrConfigNotFound
+	}
+	delete(versions, version)
+	c.labels[namespace].remove(indexRef{name: name, version: version}, config.Labels)
+	if len(versions) == 0 {
+		delete(c.configs, key)
 	}
-	delete(c.configs, key)
+	c.index.bump()
 	return nil
 }
+
+// HighestVersion implements model.ConfigRepository.
+func (c *ConfigInMemRepository) HighestVersion(ctx context.Context, namespace, name string) (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.highest[resourceKey{namespace: namespace, name: name}], nil
+}
+
+// marks returns the highest versions that snapshots must keep.
+func (c *ConfigInMemRepository) marks() []versionMark {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.highest.marks(func(key resourceKey, version int) bool {
+		_, ok := c.configs[key][version]
+		return ok
+	})
+}
+
+// raiseHighest restores a mark read from a snapshot.
+func (c *ConfigInMemRepository) raiseHighest(mark versionMark) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.highest.raise(resourceKey{namespace: mark.Namespace, name: mark.Name}, mark.Version)
+}
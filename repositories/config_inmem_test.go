@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"projekat/model"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+const (
+	stressWorkers  = 8
+	stressVersions = 200
+)
+
+// TestConfigInMemConcurrentAccess runs writers, readers and deleters against
+// one repository at once. Run it with -race; afterwards the indexes must
+// agree with what was written.
+func TestConfigInMemConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	repo := NewConfigInMemRepository(NewChangeIndex())
+	selector, err := model.ParseSelector("parity=even")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*stressWorkers)
+	for w := 0; w < stressWorkers; w++ {
+		name := fmt.Sprintf("config-%d", w%2)
+		namespace := fmt.Sprintf("ns-%d", w%2)
+		first := w / 2 * stressVersions
+
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for v := first; v < first+stressVersions; v++ {
+				config := model.NewConfig(name, v)
+				config.Namespace = namespace
+				config.AddParameter("version", fmt.Sprint(v))
+				config.AddLabel("parity", parity(v))
+				if err := repo.Add(ctx, config); err != nil {
+					errs <- fmt.Errorf("add %s/%d: %w", name, v, err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for v := first; v < first+stressVersions; v++ {
+				if _, err := repo.Get(ctx, namespace, name, v); err != nil && err != model.ErrConfigNotFound {
+					errs <- err
+					return
+				}
+				if _, err := repo.GetAll(ctx, namespace); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := repo.GetVersions(ctx, namespace, name); err != nil && err != model.ErrConfigNotFound {
+					errs <- err
+					return
+				}
+				if _, err := repo.Search(ctx, namespace, selector); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			// Odd versions are deleted as soon as they exist.
+			for v := first + 1; v < first+stressVersions; v += 2 {
+				for repo.Delete(ctx, namespace, name, v) == model.ErrConfigNotFound {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for _, namespace := range []string{"ns-0", "ns-1"} {
+		all, err := repo.GetAll(ctx, namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := stressWorkers / 2 * stressVersions / 2
+		if len(all) != want {
+			t.Errorf("GetAll(%s) returned %d configs, want %d", namespace, len(all), want)
+		}
+		for _, config := range all {
+			if config.Version%2 != 0 {
+				t.Errorf("deleted version %d of %s is still listed", config.Version, config.Name)
+			}
+		}
+		matches, err := repo.Search(ctx, namespace, selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != want {
+			t.Errorf("Search(%s) returned %d configs, want %d", namespace, len(matches), want)
+		}
+	}
+}
+
+func parity(v int) string {
+	if v%2 == 0 {
+		return "even"
+	}
+	return "odd"
+}
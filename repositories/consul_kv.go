@@ -0,0 +1,281 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"projekat/logging"
+	"projekat/tracing"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consulKV is a minimal client for Consul's KV HTTP API (/v1/kv).
+type consulKV struct {
+	address string
+	client  *http.Client
+}
+
+type consulKVPair struct {
+	Key         string `json:"Key"`
+	Value       []byte `json:"Value"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+func newConsulKV(address string) *consulKV {
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		address = "http://" + address
+	}
+	return &consulKV{
+		address: strings.TrimRight(address, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (kv *consulKV) url(key string, query string) string {
+	u := kv.address + "/v1/kv/" + key
+	if query != "" {
+		u += "?" + query
+	}
+	return u
+}
+
+// do sends a request to Consul and logs it at debug level with the logger
+// of ctx. The request is abandoned when ctx is cancelled or its deadline
+// passes.
+func (kv *consulKV) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	ctx, span := tracing.StartWithKind(ctx, "consul "+method, tracing.KindClient,
+		tracing.Attr("http.request.method", method), tracing.Attr("url.full", url))
+	defer span.End()
+	tracing.Inject(ctx, req.Header)
+
+	start := time.Now()
+	resp, err := kv.client.Do(req)
+	logger := logging.FromContext(ctx)
+	if err != nil {
+		span.RecordError(err)
+		if ctx.Err() != nil {
+			// Abandoned because the request ended or its deadline passed.
+			logger.Debug("consul request cancelled", "method", method, "url", url, "error", ctx.Err())
+		} else {
+			logger.Warn("consul request failed", "method", method, "url", url, "error", err)
+		}
+		return nil, fmt.Errorf("consul: %w", err)
+	}
+	span.SetAttributes(tracing.Attr("http.response.status_code", resp.StatusCode))
+	logger.Debug("consul request", "method", method, "url", url, "status", resp.StatusCode,
+		"duration_ms", float64(time.Since(start).Microseconds())/1000)
+	return resp, nil
+}
+
+// get returns the pair stored under key, or false if there is none.
+func (kv *consulKV) get(ctx context.Context, key string) (consulKVPair, bool, error) {
+	resp, err := kv.do(ctx, http.MethodGet, kv.url(key, ""), nil)
+	if err != nil {
+		return consulKVPair{}, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return consulKVPair{}, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return consulKVPair{}, false, unexpectedConsulStatus(resp)
+	}
+
+	var pairs []consulKVPair
+	if err := json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+		return consulKVPair{}, false, fmt.Errorf("consul: %w", err)
+	}
+	if len(pairs) == 0 {
+		return consulKVPair{}, false, nil
+	}
+	return pairs[0], true, nil
+}
+
+// list returns every pair whose key starts with prefix.
+func (kv *consulKV) list(ctx context.Context, prefix string) ([]consulKVPair, error) {
+	resp, err := kv.do(ctx, http.MethodGet, kv.url(prefix, "recurse=true"), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return []consulKVPair{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, unexpectedConsulStatus(resp)
+	}
+
+	var pairs []consulKVPair
+	if err := json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+		return nil, fmt.Errorf("consul: %w", err)
+	}
+	return pairs, nil
+}
+
+// listChildren is list for a prefix ending in "/", keeping only the keys
+// directly below it. Listing the versions of "db" under "configs/db/" must
+// not pick up keys such as "configs/db/x/1". prefix is escaped for the URL,
+// while Consul returns the keys unescaped.
+func (kv *consulKV) listChildren(ctx context.Context, prefix string) ([]consulKVPair, error) {
+	pairs, err := kv.list(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	key, err := url.PathUnescape(prefix)
+	if err != nil {
+		return nil, err
+	}
+	children := pairs[:0]
+	for _, pair := range pairs {
+		if strings.HasPrefix(pair.Key, key) && !strings.Contains(pair.Key[len(key):], "/") {
+			children = append(children, pair)
+		}
+	}
+	return children, nil
+}
+
+// create stores value under key only if the key does not exist yet.
+// It reports false when the key was already taken.
+func (kv *consulKV) create(ctx context.Context, key string, value []byte) (bool, error) {
+	return kv.boolRequest(ctx, http.MethodPut, kv.url(key, "cas=0"), value)
+}
+
+// deleteCAS removes key only if it was not modified since modifyIndex.
+func (kv *consulKV) deleteCAS(ctx context.Context, key string, modifyIndex uint64) (bool, error) {
+	query := "cas=" + strconv.FormatUint(modifyIndex, 10)
+	return kv.boolRequest(ctx, http.MethodDelete, kv.url(key, query), nil)
+}
+
+// consulTxnOp is a KV operation of a transaction (/v1/txn).
+type consulTxnOp struct {
+	Verb  string `json:"Verb"`
+	Key   string `json:"Key"`
+	Value []byte `json:"Value,omitempty"`
+	Index uint64 `json:"Index"`
+}
+
+// txn applies ops atomically. It reports false when a check-and-set failed,
+// in which case none of them was applied. Keys are escaped as for the KV
+// endpoint and unescaped here, since transactions take them verbatim.
+func (kv *consulKV) txn(ctx context.Context, ops []consulTxnOp) (bool, error) {
+	body := make([]map[string]consulTxnOp, len(ops))
+	for i, op := range ops {
+		key, err := url.PathUnescape(op.Key)
+		if err != nil {
+			return false, err
+		}
+		op.Key = key
+		body[i] = map[string]consulTxnOp{"KV": op}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return false, err
+	}
+	resp, err := kv.do(ctx, http.MethodPut, kv.address+"/v1/txn", data)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusConflict:
+		return false, nil
+	}
+	return false, unexpectedConsulStatus(resp)
+}
+
+// createVersion is create for a version of a config or group that also
+// raises the highest version recorded under markKey, in one transaction.
+func (kv *consulKV) createVersion(ctx context.Context, key, markKey string, version int, value []byte) (bool, error) {
+	for {
+		mark, _, err := kv.get(ctx, markKey)
+		if err != nil {
+			return false, err
+		}
+		ops := []consulTxnOp{{Verb: "cas", Key: key, Value: value}}
+		if highest, _ := strconv.Atoi(string(mark.Value)); version > highest {
+			// A missing mark has index 0, which only creates it.
+			ops = append(ops, consulTxnOp{Verb: "cas", Key: markKey, Value: []byte(strconv.Itoa(version)), Index: mark.ModifyIndex})
+		}
+		created, err := kv.txn(ctx, ops)
+		if err != nil || created {
+			return created, err
+		}
+		// Either the version exists or another writer raised the mark
+		// first; only the latter is worth another try.
+		if _, exists, err := kv.get(ctx, key); err != nil || exists {
+			return false, err
+		}
+	}
+}
+
+// highestVersion reads the mark written by createVersion, 0 if there is none.
+func (kv *consulKV) highestVersion(ctx context.Context, markKey string) (int, error) {
+	mark, ok, err := kv.get(ctx, markKey)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.Atoi(string(mark.Value))
+}
+
+func (kv *consulKV) boolRequest(ctx context.Context, method, url string, body []byte) (bool, error) {
+	resp, err := kv.do(ctx, method, url, body)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, unexpectedConsulStatus(resp)
+	}
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("consul: %w", err)
+	}
+	return strings.TrimSpace(string(result)) == "true", nil
+}
+
+// ping checks that the agent answers and that the cluster has elected a
+// leader, without which no KV request can succeed.
+func (kv *consulKV) ping(ctx context.Context) error {
+	resp, err := kv.do(ctx, http.MethodGet, kv.address+"/v1/status/leader", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return unexpectedConsulStatus(resp)
+	}
+	var leader string
+	if err := json.NewDecoder(resp.Body).Decode(&leader); err != nil {
+		return fmt.Errorf("consul: %w", err)
+	}
+	if leader == "" {
+		return errors.New("consul: no cluster leader")
+	}
+	return nil
+}
+
+func unexpectedConsulStatus(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("consul: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+}
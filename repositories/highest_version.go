@@ -0,0 +1,31 @@
+package repositories
+
+// versionMark records the highest version ever stored for a name.
+type versionMark struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Version   int    `json:"version"`
+}
+
+// highestVersions tracks the highest version ever stored per name. It only
+// goes up, so deleting the newest version does not free its number for the
+// next one. The owning repository guards it with its own lock.
+type highestVersions map[resourceKey]int
+
+func (h highestVersions) raise(key resourceKey, version int) {
+	if version > h[key] {
+		h[key] = version
+	}
+}
+
+// marks returns the names whose highest version is no longer stored, for
+// snapshots; the others are implied by the stored versions.
+func (h highestVersions) marks(stored func(key resourceKey, version int) bool) []versionMark {
+	marks := make([]versionMark, 0)
+	for key, version := range h {
+		if !stored(key, version) {
+			marks = append(marks, versionMark{Namespace: key.namespace, Name: key.name, Version: version})
+		}
+	}
+	return marks
+}
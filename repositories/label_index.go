@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"projekat/model"
+	"sort"
+)
+
+// indexRef identifies an indexed document: a standalone config version, or a
+// config inside a group version when config is set.
+type indexRef struct {
+	name    string
+	version int
+	config  string
+}
+
+type refSet map[indexRef]struct{}
+
+// labelIndex is an inverted index from label key and value to the documents
+// carrying that label. It is not safe for concurrent use; the owning
+// repository guards it with its own lock.
+type labelIndex struct {
+	byLabel map[string]map[string]refSet
+	all     refSet
+}
+
+func newLabelIndex() *labelIndex {
+	return &labelIndex{
+		byLabel: make(map[string]map[string]refSet),
+		all:     make(refSet),
+	}
+}
+
+func (ix *labelIndex) add(ref indexRef, labels []model.Label) {
+	ix.all[ref] = struct{}{}
+	for _, l := range labels {
+		values, ok := ix.byLabel[l.Key]
+		if !ok {
+			values = make(map[string]refSet)
+			ix.byLabel[l.Key] = values
+		}
+		refs, ok := values[l.Value]
+		if !ok {
+			refs = make(refSet)
+			values[l.Value] = refs
+		}
+		refs[ref] = struct{}{}
+	}
+}
+
+func (ix *labelIndex) remove(ref indexRef, labels []model.Label) {
+	delete(ix.all, ref)
+	for _, l := range labels {
+		values := ix.byLabel[l.Key]
+		refs := values[l.Value]
+		delete(refs, ref)
+		if len(refs) == 0 {
+			delete(values, l.Value)
+		}
+		if len(values) == 0 {
+			delete(ix.byLabel, l.Key)
+		}
+	}
+}
+
+// candidates narrows the documents to those that can satisfy the positive
+// requirements (=, in, exists) of the selector. Negative requirements cannot
+// be answered from the index, so callers must still check each candidate
+// with Selector.Matches. The result is sorted for stable output.
+func (ix *labelIndex) candidates(selector model.Selector) []indexRef {
+	var result refSet
+	for _, req := range selector {
+		var matched refSet
+		switch req.Operator {
+		case model.SelectorEquals, model.SelectorIn:
+			matched = make(refSet)
+			for _, v := range req.Values {
+				for ref := range ix.byLabel[req.Key][v] {
+					matched[ref] = struct{}{}
+				}
+			}
+		case model.SelectorExists:
+			matched = make(refSet)
+			for _, refs := range ix.byLabel[req.Key] {
+				for ref := range refs {
+					matched[ref] = struct{}{}
+				}
+			}
+		default:
+			continue
+		}
+		if result == nil {
+			result = matched
+		} else {
+			result = intersectRefs(result, matched)
+		}
+		if len(result) == 0 {
+			break
+		}
+	}
+	if result == nil {
+		result = ix.all
+	}
+
+	refs := make([]indexRef, 0, len(result))
+	for ref := range result {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].name != refs[j].name {
+			return refs[i].name < refs[j].name
+		}
+		if refs[i].version != refs[j].version {
+			return refs[i].version < refs[j].version
+		}
+		return refs[i].config < refs[j].config
+	})
+	return refs
+}
+
+func intersectRefs(a, b refSet) refSet {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	result := make(refSet, len(a))
+	for ref := range a {
+		if _, ok := b[ref]; ok {
+			result[ref] = struct{}{}
+		}
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"projekat/health"
+	"projekat/model"
+)
+
+// consulNamespacePrefix is the key prefix for data in a namespace. The
+// default namespace keeps the original un-prefixed layout so existing data
+// stays readable.
+func consulNamespacePrefix(namespace string) string {
+	if model.NamespaceOrDefault(namespace) == model.DefaultNamespace {
+		return ""
+	}
+	return "namespaces/" + url.PathEscape(namespace) + "/"
+}
+
+const namespaceConsulPrefix = "meta/namespaces/"
+
+// NamespaceConsulRepository stores namespace definitions under
+// meta/namespaces/{name}.
+type NamespaceConsulRepository struct {
+	kv *consulKV
+}
+
+func NewNamespaceConsulRepository(address string) model.NamespaceRepository {
+	return &NamespaceConsulRepository{
+		kv: newConsulKV(address),
+	}
+}
+
+func (r *NamespaceConsulRepository) Add(ctx context.Context, namespace model.Namespace) error {
+	value, err := json.Marshal(namespace)
+	if err != nil {
+		return err
+	}
+	created, err := r.kv.create(ctx, namespaceConsulPrefix+url.PathEscape(namespace.Name), value)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return model.ErrNamespaceExists
+	}
+	return nil
+}
+
+func (r *NamespaceConsulRepository) Get(ctx context.Context, name string) (model.Namespace, error) {
+	pair, ok, err := r.kv.get(ctx, namespaceConsulPrefix+url.PathEscape(name))
+	if err != nil {
+		return model.Namespace{}, err
+	}
+	if !ok {
+		return model.Namespace{}, model.ErrNamespaceNotFound
+	}
+	var namespace model.Namespace
+	if err := json.Unmarshal(pair.Value, &namespace); err != nil {
+		return model.Namespace{}, err
+	}
+	return namespace, nil
+}
+
+func (r *NamespaceConsulRepository) GetAll(ctx context.Context) ([]model.Namespace, error) {
+	pairs, err := r.kv.list(ctx, namespaceConsulPrefix)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.Namespace, 0, len(pairs))
+	for _, pair := range pairs {
+		var namespace model.Namespace
+		if err := json.Unmarshal(pair.Value, &namespace); err != nil {
+			return nil, err
+		}
+		result = append(result, namespace)
+	}
+	sortNamespaces(result)
+	return result, nil
+}
+
+func (r *NamespaceConsulRepository) Delete(ctx context.Context, name string) error {
+	key := namespaceConsulPrefix + url.PathEscape(name)
+	pair, ok, err := r.kv.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return model.ErrNamespaceNotFound
+	}
+	deleted, err := r.kv.deleteCAS(ctx, key, pair.ModifyIndex)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return model.ErrConcurrentUpdate
+	}
+	return nil
+}
+
+// RegisterHealthChecks implements health.Registrar: the server is not ready
+// while Consul is unreachable or has no leader.
+func (r *NamespaceConsulRepository) RegisterHealthChecks(c *health.Checker) {
+	c.Register("consul", r.kv.ping)
+}
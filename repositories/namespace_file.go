@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"projekat/health"
+	"projekat/logging"
+	"projekat/model"
+	"sync"
+)
+
+// NamespaceFileRepository keeps namespaces in memory and makes them durable
+// with a write-ahead log, like the config and group file repositories.
+type NamespaceFileRepository struct {
+	mu    sync.RWMutex
+	inner *NamespaceInMemRepository
+	wal   *writeAheadLog
+}
+
+func NewNamespaceFileRepository(opts FileOptions) (*NamespaceFileRepository, error) {
+	wal, err := openWriteAheadLog("namespaces", opts)
+	if err != nil {
+		return nil, err
+	}
+	r := &NamespaceFileRepository{
+		inner: newNamespaceInMemRepository(),
+		wal:   wal,
+	}
+	if err := wal.replay(r.applySnapshot, r.apply); err != nil {
+		return nil, fmt.Errorf("replay namespaces: %w", err)
+	}
+	return r, nil
+}
+
+func (r *NamespaceFileRepository) applySnapshot(entry json.RawMessage) error {
+	ctx := context.Background()
+	var namespace model.Namespace
+	if err := json.Unmarshal(entry, &namespace); err != nil {
+		return err
+	}
+	return r.inner.Add(ctx, namespace)
+}
+
+func (r *NamespaceFileRepository) apply(rec walRecord) error {
+	ctx := context.Background()
+	_, err := r.inner.Get(ctx, rec.Name)
+	exists := err == nil
+	switch rec.Op {
+	case walOpAdd:
+		if exists {
+			return nil
+		}
+		var namespace model.Namespace
+		if err := json.Unmarshal(rec.Value, &namespace); err != nil {
+			return err
+		}
+		return r.inner.Add(ctx, namespace)
+	case walOpDelete:
+		if !exists {
+			return nil
+		}
+		return r.inner.Delete(ctx, rec.Name)
+	}
+	return fmt.Errorf("unknown log operation %q", rec.Op)
+}
+
+func (r *NamespaceFileRepository) Add(ctx context.Context, namespace model.Namespace) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.inner.Get(ctx, namespace.Name); err == nil {
+		return model.ErrNamespaceExists
+	}
+	value, err := json.Marshal(namespace)
+	if err != nil {
+		return err
+	}
+	if err := r.wal.append(walRecord{Op: walOpAdd, Name: namespace.Name, Value: value}); err != nil {
+		return err
+	}
+	if err := r.inner.Add(ctx, namespace); err != nil {
+		return err
+	}
+	r.compactIfNeeded(ctx)
+	return nil
+}
+
+func (r *NamespaceFileRepository) Get(ctx context.Context, name string) (model.Namespace, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.Get(ctx, name)
+}
+
+func (r *NamespaceFileRepository) GetAll(ctx context.Context) ([]model.Namespace, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inner.GetAll(ctx)
+}
+
+func (r *NamespaceFileRepository) Delete(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.inner.Get(ctx, name); err != nil {
+		return err
+	}
+	if err := r.wal.append(walRecord{Op: walOpDelete, Name: name}); err != nil {
+		return err
+	}
+	if err := r.inner.Delete(ctx, name); err != nil {
+		return err
+	}
+	r.compactIfNeeded(ctx)
+	return nil
+}
+
+// compactIfNeeded snapshots the log once it grew large enough; failures are
+// logged because the write that triggered it is already durable.
+func (r *NamespaceFileRepository) compactIfNeeded(ctx context.Context) {
+	if !r.wal.shouldCompact() {
+		return
+	}
+	namespaces, err := r.inner.GetAll(ctx)
+	if err == nil {
+		err = r.wal.compact(ctx, namespaces, nil)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("could not compact write-ahead log", "error", err)
+	}
+}
+
+// Close flushes and closes the write-ahead log.
+func (r *NamespaceFileRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.wal.close()
+}
+
+// RegisterHealthChecks implements health.Registrar: the server is not ready
+// while the write-ahead log cannot be synced.
+func (r *NamespaceFileRepository) RegisterHealthChecks(c *health.Checker) {
+	c.Register("wal:"+r.wal.name, r.wal.check)
+}
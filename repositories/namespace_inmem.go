@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"projekat/model"
+	"sort"
+	"sync"
+)
+
+type NamespaceInMemRepository struct {
+	mu         sync.RWMutex
+	namespaces map[string]model.Namespace
+}
+
+func NewNamespaceInMemRepository() model.NamespaceRepository {
+	return newNamespaceInMemRepository()
+}
+
+func newNamespaceInMemRepository() *NamespaceInMemRepository {
+	return &NamespaceInMemRepository{
+		namespaces: make(map[string]model.Namespace),
+	}
+}
+
+func (r *NamespaceInMemRepository) Add(ctx context.Context, namespace model.Namespace) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.namespaces[namespace.Name]; exists {
+		return model.ErrNamespaceExists
+	}
+	r.namespaces[namespace.Name] = namespace
+	return nil
+}
+
+func (r *NamespaceInMemRepository) Get(ctx context.Context, name string) (model.Namespace, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	namespace, ok := r.namespaces[name]
+	if !ok {
+		return model.Namespace{}, model.ErrNamespaceNotFound
+	}
+	return namespace, nil
+}
+
+// GetAll returns the namespaces ordered by name.
+func (r *NamespaceInMemRepository) GetAll(ctx context.Context) ([]model.Namespace, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]model.Namespace, 0, len(r.namespaces))
+	for _, namespace := range r.namespaces {
+		result = append(result, namespace)
+	}
+	sortNamespaces(result)
+	return result, nil
+}
+
+func (r *NamespaceInMemRepository) Delete(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.namespaces[name]; !ok {
+		return model.ErrNamespaceNotFound
+	}
+	delete(r.namespaces, name)
+	return nil
+}
+
+func sortNamespaces(namespaces []model.Namespace) {
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+}
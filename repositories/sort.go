@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"projekat/model"
+	"sort"
+)
+
+// sortConfigs orders configs by namespace, name and then version so
+// listings are stable regardless of how the backend iterates.
+func sortConfigs(configs []model.Config) {
+	sort.Slice(configs, func(i, j int) bool {
+		if configs[i].Namespace != configs[j].Namespace {
+			return configs[i].Namespace < configs[j].Namespace
+		}
+		if configs[i].Name != configs[j].Name {
+			return configs[i].Name < configs[j].Name
+		}
+		return configs[i].Version < configs[j].Version
+	})
+}
+
+// sortGroups orders config groups by namespace, name and then version.
+func sortGroups(groups []model.ConfigGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Namespace != groups[j].Namespace {
+			return groups[i].Namespace < groups[j].Namespace
+		}
+		if groups[i].Name != groups[j].Name {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].Version < groups[j].Version
+	})
+}
+
+// sortConfigVersions orders the versions of a single config newest first.
+func sortConfigVersions(configs []model.Config) {
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].Version > configs[j].Version
+	})
+}
+
+// sortGroupVersions orders the versions of a single group newest first.
+func sortGroupVersions(groups []model.ConfigGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Version > groups[j].Version
+	})
+}
@@ -0,0 +1,234 @@
+package repositories
+
+import (
+	"context"
+	"io"
+	"projekat/model"
+	"projekat/tracing"
+)
+
+// The Traced repositories wrap another repository and record a span for
+// every call, named after the interface and method and tagged with the
+// backend, so slow storage shows up in traces whatever the implementation.
+
+type TracedConfigRepository struct {
+	inner   model.ConfigRepository
+	backend string
+}
+
+func NewTracedConfigRepository(inner model.ConfigRepository, backend string) *TracedConfigRepository {
+	return &TracedConfigRepository{inner: inner, backend: backend}
+}
+
+func (r *TracedConfigRepository) start(ctx context.Context, method string, attrs ...tracing.Attribute) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "ConfigRepository."+method, append(attrs, tracing.Attr("db.system", r.backend))...)
+}
+
+func (r *TracedConfigRepository) Add(ctx context.Context, config model.Config) error {
+	ctx, span := r.start(ctx, "Add", nameAttrs(config.Namespace, config.Name, config.Version)...)
+	defer span.End()
+	err := r.inner.Add(ctx, config)
+	span.RecordError(err)
+	return err
+}
+
+func (r *TracedConfigRepository) Get(ctx context.Context, namespace, name string, version int) (model.Config, error) {
+	ctx, span := r.start(ctx, "Get", nameAttrs(namespace, name, version)...)
+	defer span.End()
+	config, err := r.inner.Get(ctx, namespace, name, version)
+	span.RecordError(err)
+	return config, err
+}
+
+func (r *TracedConfigRepository) GetAll(ctx context.Context, namespace string) ([]model.Config, error) {
+	ctx, span := r.start(ctx, "GetAll", nameAttrs(namespace, "", 0)...)
+	defer span.End()
+	configs, err := r.inner.GetAll(ctx, namespace)
+	span.RecordError(err)
+	span.SetAttributes(tracing.Attr("result.count", len(configs)))
+	return configs, err
+}
+
+func (r *TracedConfigRepository) GetVersions(ctx context.Context, namespace, name string) ([]model.Config, error) {
+	ctx, span := r.start(ctx, "GetVersions", nameAttrs(namespace, name, 0)...)
+	defer span.End()
+	configs, err := r.inner.GetVersions(ctx, namespace, name)
+	span.RecordError(err)
+	span.SetAttributes(tracing.Attr("result.count", len(configs)))
+	return configs, err
+}
+
+func (r *TracedConfigRepository) Search(ctx context.Context, namespace string, selector model.Selector) ([]model.Config, error) {
+	ctx, span := r.start(ctx, "Search", nameAttrs(namespace, "", 0)...)
+	defer span.End()
+	configs, err := r.inner.Search(ctx, namespace, selector)
+	span.RecordError(err)
+	span.SetAttributes(tracing.Attr("result.count", len(configs)))
+	return configs, err
+}
+
+func (r *TracedConfigRepository) Delete(ctx context.Context, namespace, name string, version int) error {
+	ctx, span := r.start(ctx, "Delete", nameAttrs(namespace, name, version)...)
+	defer span.End()
+	err := r.inner.Delete(ctx, namespace, name, version)
+	span.RecordError(err)
+	return err
+}
+
+func (r *TracedConfigRepository) HighestVersion(ctx context.Context, namespace, name string) (int, error) {
+	ctx, span := r.start(ctx, "HighestVersion", nameAttrs(namespace, name, 0)...)
+	defer span.End()
+	version, err := r.inner.HighestVersion(ctx, namespace, name)
+	span.RecordError(err)
+	return version, err
+}
+
+func (r *TracedConfigRepository) Close() error {
+	return closeInner(r.inner)
+}
+
+type TracedConfigGroupRepository struct {
+	inner   model.ConfigGroupRepository
+	backend string
+}
+
+func NewTracedConfigGroupRepository(inner model.ConfigGroupRepository, backend string) *TracedConfigGroupRepository {
+	return &TracedConfigGroupRepository{inner: inner, backend: backend}
+}
+
+func (r *TracedConfigGroupRepository) start(ctx context.Context, method string, attrs ...tracing.Attribute) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "ConfigGroupRepository."+method, append(attrs, tracing.Attr("db.system", r.backend))...)
+}
+
+func (r *TracedConfigGroupRepository) Add(ctx context.Context, group model.ConfigGroup) error {
+	ctx, span := r.start(ctx, "Add", nameAttrs(group.Namespace, group.Name, group.Version)...)
+	defer span.End()
+	err := r.inner.Add(ctx, group)
+	span.RecordError(err)
+	return err
+}
+
+func (r *TracedConfigGroupRepository) Get(ctx context.Context, namespace, name string, version int) (model.ConfigGroup, error) {
+	ctx, span := r.start(ctx, "Get", nameAttrs(namespace, name, version)...)
+	defer span.End()
+	group, err := r.inner.Get(ctx, namespace, name, version)
+	span.RecordError(err)
+	return group, err
+}
+
+func (r *TracedConfigGroupRepository) GetAll(ctx context.Context, namespace string) ([]model.ConfigGroup, error) {
+	ctx, span := r.start(ctx, "GetAll", nameAttrs(namespace, "", 0)...)
+	defer span.End()
+	groups, err := r.inner.GetAll(ctx, namespace)
+	span.RecordError(err)
+	span.SetAttributes(tracing.Attr("result.count", len(groups)))
+	return groups, err
+}
+
+func (r *TracedConfigGroupRepository) GetVersions(ctx context.Context, namespace, name string) ([]model.ConfigGroup, error) {
+	ctx, span := r.start(ctx, "GetVersions", nameAttrs(namespace, name, 0)...)
+	defer span.End()
+	groups, err := r.inner.GetVersions(ctx, namespace, name)
+	span.RecordError(err)
+	span.SetAttributes(tracing.Attr("result.count", len(groups)))
+	return groups, err
+}
+
+func (r *TracedConfigGroupRepository) Search(ctx context.Context, namespace string, selector model.Selector) ([]model.GroupConfigMatch, error) {
+	ctx, span := r.start(ctx, "Search", nameAttrs(namespace, "", 0)...)
+	defer span.End()
+	matches, err := r.inner.Search(ctx, namespace, selector)
+	span.RecordError(err)
+	span.SetAttributes(tracing.Attr("result.count", len(matches)))
+	return matches, err
+}
+
+func (r *TracedConfigGroupRepository) Delete(ctx context.Context, namespace, name string, version int) error {
+	ctx, span := r.start(ctx, "Delete", nameAttrs(namespace, name, version)...)
+	defer span.End()
+	err := r.inner.Delete(ctx, namespace, name, version)
+	span.RecordError(err)
+	return err
+}
+
+func (r *TracedConfigGroupRepository) HighestVersion(ctx context.Context, namespace, name string) (int, error) {
+	ctx, span := r.start(ctx, "HighestVersion", nameAttrs(namespace, name, 0)...)
+	defer span.End()
+	version, err := r.inner.HighestVersion(ctx, namespace, name)
+	span.RecordError(err)
+	return version, err
+}
+
+func (r *TracedConfigGroupRepository) Close() error {
+	return closeInner(r.inner)
+}
+
+type TracedNamespaceRepository struct {
+	inner   model.NamespaceRepository
+	backend string
+}
+
+func NewTracedNamespaceRepository(inner model.NamespaceRepository, backend string) *TracedNamespaceRepository {
+	return &TracedNamespaceRepository{inner: inner, backend: backend}
+}
+
+func (r *TracedNamespaceRepository) start(ctx context.Context, method string, attrs ...tracing.Attribute) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "NamespaceRepository."+method, append(attrs, tracing.Attr("db.system", r.backend))...)
+}
+
+func (r *TracedNamespaceRepository) Add(ctx context.Context, namespace model.Namespace) error {
+	ctx, span := r.start(ctx, "Add", tracing.Attr("namespace", namespace.Name))
+	defer span.End()
+	err := r.inner.Add(ctx, namespace)
+	span.RecordError(err)
+	return err
+}
+
+func (r *TracedNamespaceRepository) Get(ctx context.Context, name string) (model.Namespace, error) {
+	ctx, span := r.start(ctx, "Get", tracing.Attr("namespace", name))
+	defer span.End()
+	namespace, err := r.inner.Get(ctx, name)
+	span.RecordError(err)
+	return namespace, err
+}
+
+func (r *TracedNamespaceRepository) GetAll(ctx context.Context) ([]model.Namespace, error) {
+	ctx, span := r.start(ctx, "GetAll")
+	defer span.End()
+	namespaces, err := r.inner.GetAll(ctx)
+	span.RecordError(err)
+	span.SetAttributes(tracing.Attr("result.count", len(namespaces)))
+	return namespaces, err
+}
+
+func (r *TracedNamespaceRepository) Delete(ctx context.Context, name string) error {
+	ctx, span := r.start(ctx, "Delete", tracing.Attr("namespace", name))
+	defer span.End()
+	err := r.inner.Delete(ctx, name)
+	span.RecordError(err)
+	return err
+}
+
+func (r *TracedNamespaceRepository) Close() error {
+	return closeInner(r.inner)
+}
+
+// nameAttrs tags a span with the resource it concerns; empty names and
+// zero versions are left out.
+func nameAttrs(namespace, name string, version int) []tracing.Attribute {
+	attrs := []tracing.Attribute{tracing.Attr("namespace", model.NamespaceOrDefault(namespace))}
+	if name != "" {
+		attrs = append(attrs, tracing.Attr("name", name))
+	}
+	if version != 0 {
+		attrs = append(attrs, tracing.Attr("version", version))
+	}
+	return attrs
+}
+
+func closeInner(inner interface{}) error {
+	if closer, ok := inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
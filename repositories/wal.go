@@ -0,0 +1,355 @@
+package repositories
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"projekat/logging"
+	"sync"
+	"time"
+)
+
+// FsyncPolicy controls when the write-ahead log is flushed to stable storage.
+type FsyncPolicy int
+
+const (
+	// FsyncAlways syncs the log after every appended record.
+	FsyncAlways FsyncPolicy = iota
+	// FsyncInterval syncs the log periodically from a background goroutine.
+	FsyncInterval
+	// FsyncNever leaves flushing to the operating system.
+	FsyncNever
+)
+
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch s {
+	case "always":
+		return FsyncAlways, nil
+	case "interval":
+		return FsyncInterval, nil
+	case "never":
+		return FsyncNever, nil
+	}
+	return FsyncAlways, fmt.Errorf("invalid fsync policy %q; expected always, interval or never", s)
+}
+
+// FileOptions configures the file-backed repositories.
+type FileOptions struct {
+	Dir           string
+	Fsync         FsyncPolicy
+	FsyncInterval time.Duration
+	// SnapshotEvery is the number of log records after which the log is
+	// compacted into a snapshot. Zero disables automatic compaction.
+	SnapshotEvery int
+}
+
+const (
+	walOpAdd    = "add"
+	walOpDelete = "delete"
+)
+
+type walRecord struct {
+	Op string `json:"op"`
+	// Namespace is empty in records written before namespaces existed;
+	// those belong to model.DefaultNamespace.
+	Namespace string          `json:"namespace,omitempty"`
+	Name      string          `json:"name"`
+	Version   int             `json:"version"`
+	Value     json.RawMessage `json:"value,omitempty"`
+}
+
+// walSnapshot is the content of a snapshot that keeps version marks. A
+// snapshot without marks is just the array of entries.
+type walSnapshot struct {
+	Entries json.RawMessage `json:"entries"`
+	Highest []versionMark   `json:"highest"`
+}
+
+// writeAheadLog appends records to {name}.wal and compacts them into
+// {name}.snapshot. Callers are responsible for serializing access.
+type writeAheadLog struct {
+	name         string
+	opts         FileOptions
+	logPath      string
+	snapshotPath string
+	file         *os.File
+	records      int
+	// size is the length of the log up to the last complete record.
+	size int64
+	// marks holds the version marks replay read from the snapshot.
+	marks []versionMark
+
+	mu    sync.Mutex // guards file and dirty for the interval syncer
+	dirty bool
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func openWriteAheadLog(name string, opts FileOptions) (*writeAheadLog, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("data directory is required")
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &writeAheadLog{
+		name:         name,
+		opts:         opts,
+		logPath:      filepath.Join(opts.Dir, name+".wal"),
+		snapshotPath: filepath.Join(opts.Dir, name+".snapshot"),
+	}, nil
+}
+
+// replay feeds the snapshot entries and then every logged record to the
+// given callbacks, and opens the log for appending afterwards.
+func (w *writeAheadLog) replay(applySnapshot func(json.RawMessage) error, apply func(walRecord) error) error {
+	if data, err := os.ReadFile(w.snapshotPath); err == nil {
+		var entries []json.RawMessage
+		if err := w.readSnapshot(data, &entries); err != nil {
+			return fmt.Errorf("read snapshot %s: %w", w.snapshotPath, err)
+		}
+		for _, entry := range entries {
+			if err := applySnapshot(entry); err != nil {
+				return err
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	file, err := os.OpenFile(w.logPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	// A crash in the middle of an append can leave a torn last record; it is
+	// cut off so new records start cleanly. A bad record followed by more
+	// data is corruption and fails the replay.
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+		var rec walRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				break
+			}
+			file.Close()
+			return fmt.Errorf("read log %s: corrupt record at offset %d: %w", w.logPath, offset, err)
+		}
+		if err := apply(rec); err != nil {
+			file.Close()
+			return err
+		}
+		offset += int64(len(line))
+		w.records++
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	w.size = offset
+	w.file = file
+
+	if w.opts.Fsync == FsyncInterval {
+		w.startSyncer()
+	}
+	return nil
+}
+
+// readSnapshot decodes a snapshot into its entries and w.marks.
+func (w *writeAheadLog) readSnapshot(data []byte, entries *[]json.RawMessage) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return json.Unmarshal(data, entries)
+	}
+	var snapshot walSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	w.marks = snapshot.Highest
+	return json.Unmarshal(snapshot.Entries, entries)
+}
+
+func (w *writeAheadLog) append(rec walRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.file.Write(line); err != nil {
+		return w.rollback(err)
+	}
+	if w.opts.Fsync == FsyncAlways {
+		if err := w.file.Sync(); err != nil {
+			return w.rollback(err)
+		}
+	}
+	w.size += int64(len(line))
+	w.records++
+	if w.opts.Fsync == FsyncInterval {
+		w.dirty = true
+	}
+	return nil
+}
+
+// rollback cuts a failed append off the log, so that a partial record does
+// not end up in the middle of the file once later appends succeed. The
+// caller holds w.mu.
+func (w *writeAheadLog) rollback(cause error) error {
+	if err := w.file.Truncate(w.size); err != nil {
+		return fmt.Errorf("%v; truncate log: %w", cause, err)
+	}
+	if _, err := w.file.Seek(w.size, io.SeekStart); err != nil {
+		return fmt.Errorf("%v; seek log: %w", cause, err)
+	}
+	return cause
+}
+
+// shouldCompact reports whether enough records accumulated for a snapshot.
+func (w *writeAheadLog) shouldCompact() bool {
+	return w.opts.SnapshotEvery > 0 && w.records >= w.opts.SnapshotEvery
+}
+
+// compact writes entries and marks as the new snapshot and truncates the
+// log. The snapshot is replaced atomically, so a crash leaves either the old
+// snapshot with the full log or the new snapshot; replaying the log over the
+// new snapshot is harmless because replay ignores duplicate adds and deletes.
+func (w *writeAheadLog) compact(ctx context.Context, entries interface{}, marks []versionMark) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if len(marks) > 0 {
+		if data, err = json.Marshal(walSnapshot{Entries: data, Highest: marks}); err != nil {
+			return err
+		}
+	}
+	tmpPath := w.snapshotPath + ".tmp"
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, w.snapshotPath); err != nil {
+		return err
+	}
+	syncDir(w.opts.Dir)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.records = 0
+	w.size = 0
+	w.dirty = false
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("compacted write-ahead log", "snapshot", w.snapshotPath)
+	return nil
+}
+
+func (w *writeAheadLog) startSyncer() {
+	interval := w.opts.FsyncInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.mu.Lock()
+				if w.dirty {
+					w.file.Sync()
+					w.dirty = false
+				}
+				w.mu.Unlock()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// check reports whether the log is open and its file still exists. It does
+// not sync: probes would then hold up appends for an fsync each, whatever
+// the fsync policy.
+func (w *writeAheadLog) check(ctx context.Context) error {
+	w.mu.Lock()
+	file := w.file
+	w.mu.Unlock()
+	if file == nil {
+		return errors.New("log is not open")
+	}
+	_, err := file.Stat()
+	return err
+}
+
+func (w *writeAheadLog) close() error {
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir makes a rename in dir durable; errors are ignored because not every
+// platform supports syncing directories.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
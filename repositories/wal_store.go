@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"projekat/health"
+	"projekat/logging"
+	"projekat/model"
+	"sync"
+)
+
+// versionStore is the part of an in-memory config or group repository that
+// walStore makes durable.
+type versionStore[T any] interface {
+	Add(ctx context.Context, value T) error
+	Get(ctx context.Context, namespace, name string, version int) (T, error)
+	Delete(ctx context.Context, namespace, name string, version int) error
+	all() []T
+	marks() []versionMark
+	raiseHighest(mark versionMark)
+}
+
+// walStore keeps versions in an in-memory repository and makes them durable
+// with a write-ahead log in FileOptions.Dir that is replayed on startup. The
+// config and group file repositories wrap it with their typed reads, which
+// hold mu for reading.
+type walStore[T any] struct {
+	mu    sync.RWMutex
+	inner versionStore[T]
+	wal   *writeAheadLog
+	// exists is returned when a version is added twice.
+	exists error
+}
+
+func openWALStore[T any](name string, opts FileOptions, inner versionStore[T], exists error) (*walStore[T], error) {
+	wal, err := openWriteAheadLog(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &walStore[T]{inner: inner, wal: wal, exists: exists}
+	if err := wal.replay(s.applySnapshot, s.apply); err != nil {
+		return nil, fmt.Errorf("replay %s: %w", name, err)
+	}
+	for _, mark := range wal.marks {
+		inner.raiseHighest(mark)
+	}
+	return s, nil
+}
+
+func (s *walStore[T]) applySnapshot(entry json.RawMessage) error {
+	var value T
+	if err := json.Unmarshal(entry, &value); err != nil {
+		return err
+	}
+	return s.inner.Add(context.Background(), value)
+}
+
+// apply replays a logged record. Records already reflected in the snapshot
+// are skipped.
+func (s *walStore[T]) apply(rec walRecord) error {
+	ctx := context.Background()
+	namespace := model.NamespaceOrDefault(rec.Namespace)
+	_, err := s.inner.Get(ctx, namespace, rec.Name, rec.Version)
+	exists := err == nil
+	switch rec.Op {
+	case walOpAdd:
+		if exists {
+			return nil
+		}
+		var value T
+		if err := json.Unmarshal(rec.Value, &value); err != nil {
+			return err
+		}
+		return s.inner.Add(ctx, value)
+	case walOpDelete:
+		if !exists {
+			return nil
+		}
+		return s.inner.Delete(ctx, namespace, rec.Name, rec.Version)
+	}
+	return fmt.Errorf("unknown log operation %q", rec.Op)
+}
+
+// add logs and stores value, the given version of name in namespace.
+func (s *walStore[T]) add(ctx context.Context, namespace, name string, version int, value T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.inner.Get(ctx, namespace, name, version); err == nil {
+		return s.exists
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	rec := walRecord{Op: walOpAdd, Namespace: namespace, Name: name, Version: version, Value: data}
+	if err := s.wal.append(rec); err != nil {
+		return err
+	}
+	if err := s.inner.Add(ctx, value); err != nil {
+		return err
+	}
+	s.compactIfNeeded(ctx)
+	return nil
+}
+
+func (s *walStore[T]) delete(ctx context.Context, namespace, name string, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.inner.Get(ctx, namespace, name, version); err != nil {
+		return err
+	}
+	if err := s.wal.append(walRecord{Op: walOpDelete, Namespace: namespace, Name: name, Version: version}); err != nil {
+		return err
+	}
+	if err := s.inner.Delete(ctx, namespace, name, version); err != nil {
+		return err
+	}
+	s.compactIfNeeded(ctx)
+	return nil
+}
+
+// compactIfNeeded snapshots the log once it grew large enough. The write
+// that triggered it is already durable, so a failure is only logged and
+// compaction is retried on the next write.
+func (s *walStore[T]) compactIfNeeded(ctx context.Context) {
+	if !s.wal.shouldCompact() {
+		return
+	}
+	if err := s.wal.compact(ctx, s.inner.all(), s.inner.marks()); err != nil {
+		logging.FromContext(ctx).Error("could not compact write-ahead log", "error", err)
+	}
+}
+
+// Close flushes and closes the write-ahead log.
+func (s *walStore[T]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wal.close()
+}
+
+// RegisterHealthChecks implements health.Registrar: the server is not ready
+// while the write-ahead log is not open.
+func (s *walStore[T]) RegisterHealthChecks(c *health.Checker) {
+	c.Register("wal:"+s.wal.name, s.wal.check)
+}
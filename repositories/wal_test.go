@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+// openTestLog opens a log named "test" in dir and replays it, returning the
+// names of the replayed records.
+func openTestLog(t *testing.T, dir string) (*writeAheadLog, []string, error) {
+	t.Helper()
+	w, err := openWriteAheadLog("test", FileOptions{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	err = w.replay(
+		func(json.RawMessage) error { return nil },
+		func(rec walRecord) error {
+			names = append(names, rec.Name)
+			return nil
+		},
+	)
+	if err == nil {
+		t.Cleanup(func() { w.close() })
+	}
+	return w, names, err
+}
+
+func appendNames(t *testing.T, w *writeAheadLog, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := w.append(walRecord{Op: walOpAdd, Name: name, Version: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWALReplayCutsTornLastRecord(t *testing.T) {
+	for name, tail := range map[string]string{
+		"without newline": `{"op":"add","na`,
+		"with newline":    "{\"op\":\"add\",\"na\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, _, err := openTestLog(t, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			appendNames(t, w, "a", "b")
+			w.close()
+
+			file, err := os.OpenFile(w.logPath, os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.WriteString(tail)
+			file.Close()
+
+			w, names, err := openTestLog(t, dir)
+			if err != nil {
+				t.Fatalf("replay failed on a torn last record: %v", err)
+			}
+			if len(names) != 2 {
+				t.Fatalf("replayed %v, want [a b]", names)
+			}
+			appendNames(t, w, "c")
+			w.close()
+			if _, names, err = openTestLog(t, dir); err != nil || len(names) != 3 {
+				t.Fatalf("after appending past the cut: replayed %v, error %v", names, err)
+			}
+		})
+	}
+}
+
+func TestWALReplayFailsOnCorruption(t *testing.T) {
+	dir := t.TempDir()
+	w, _, err := openTestLog(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.close()
+
+	data := "{\"op\":\"add\",\"name\":\"a\",\"version\":1}\n" +
+		"garbage\n" +
+		"{\"op\":\"add\",\"name\":\"b\",\"version\":1}\n"
+	if err := os.WriteFile(w.logPath, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := openTestLog(t, dir); err == nil {
+		t.Fatal("replay accepted a corrupt record in the middle of the log")
+	}
+	got, err := os.ReadFile(w.logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Error("a failed replay modified the log")
+	}
+}
+
+func TestWALAppendRollsBackPartialRecord(t *testing.T) {
+	dir := t.TempDir()
+	w, _, err := openTestLog(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendNames(t, w, "a")
+
+	// Simulate a write that failed half way.
+	w.mu.Lock()
+	w.file.WriteString(`{"op":"add","name":"partial"`)
+	err = w.rollback(errors.New("disk full"))
+	w.mu.Unlock()
+	if err == nil || err.Error() != "disk full" {
+		t.Fatalf("rollback() = %v", err)
+	}
+
+	appendNames(t, w, "b")
+	w.close()
+	_, names, err := openTestLog(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("replayed %v, want [a b]", names)
+	}
+}
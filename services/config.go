@@ -1,34 +1,218 @@
 package services
 
 import (
+	"context"
+	"projekat/events"
+	"projekat/logging"
 	"projekat/model"
+	"projekat/tracing"
+	"strconv"
+	"time"
 )
 
 type ConfigService struct {
-	repo model.ConfigRepository
+	repo       model.ConfigRepository
+	namespaces NamespaceService
+	quotas     QuotaService
+	events     *events.Broker
 }
 
-func NewConfigService(repo model.ConfigRepository) ConfigService {
+func NewConfigService(repo model.ConfigRepository, namespaces NamespaceService, quotas QuotaService, broker *events.Broker) ConfigService {
 	return ConfigService{
-		repo: repo,
+		repo:       repo,
+		namespaces: namespaces,
+		quotas:     quotas,
+		events:     broker,
 	}
 }
 
-func (s ConfigService) Add(config model.Config) error {
-	return s.repo.Add(config)
+// Add stores a config in its namespace, which must exist, and returns the
+// stored version.
+func (s ConfigService) Add(ctx context.Context, config model.Config) (model.Config, error) {
+	ctx, span := tracing.Start(ctx, "ConfigService.Add")
+	defer span.End()
+	if !model.ValidResourceName(config.Name) {
+		return model.Config{}, model.ErrInvalidName
+	}
+	config.Namespace = model.NamespaceOrDefault(config.Namespace)
+	config.CreatedAt = time.Now().UTC()
+	eventType := events.Created
+	if versions, err := s.repo.GetVersions(ctx, config.Namespace, config.Name); err == nil && len(versions) > 0 {
+		eventType = events.NewVersion
+	}
+	return s.add(ctx, config, eventType)
+}
+
+// add stores a config version, if it fits the namespace quota, and announces
+// it to watchers. It returns the version as stored, with its creator.
+func (s ConfigService) add(ctx context.Context, config model.Config, eventType events.Type) (model.Config, error) {
+	config.Metadata = withCreator(ctx, config.Metadata)
+	err := s.namespaces.guard(ctx, config.Namespace, func() error {
+		return s.quotas.admitConfig(ctx, config, func() error {
+			return s.repo.Add(ctx, config)
+		})
+	})
+	if err != nil {
+		return model.Config{}, err
+	}
+	logging.FromContext(ctx).Debug("config version stored", "namespace", config.Namespace, "name", config.Name, "version", config.Version)
+	s.events.Publish(events.Event{
+		Type:      eventType,
+		Resource:  events.ResourceConfigs,
+		Namespace: config.Namespace,
+		Name:      config.Name,
+		Version:   config.Version,
+		Object:    config,
+	})
+	return config, nil
+}
+
+func (s ConfigService) Get(ctx context.Context, namespace, name string, version int) (model.Config, error) {
+	ctx, span := tracing.Start(ctx, "ConfigService.Get")
+	defer span.End()
+	return s.repo.Get(ctx, namespace, name, version)
+}
+
+func (s ConfigService) GetAll(ctx context.Context, namespace string) ([]model.Config, error) {
+	ctx, span := tracing.Start(ctx, "ConfigService.GetAll")
+	defer span.End()
+	if err := s.namespaces.exists(ctx, namespace); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAll(ctx, namespace)
 }
 
-func (s ConfigService) Get(name string, version int) (model.Config, error) {
-	return s.repo.Get(name, version)
+// GetVersions returns the version history of a config, newest first.
+func (s ConfigService) GetVersions(ctx context.Context, namespace, name string) ([]model.Config, error) {
+	ctx, span := tracing.Start(ctx, "ConfigService.GetVersions")
+	defer span.End()
+	return s.repo.GetVersions(ctx, namespace, name)
 }
 
-func (s ConfigService) GetAll() ([]model.Config, error) {
-	return s.repo.GetAll()
+func (s ConfigService) GetLatest(ctx context.Context, namespace, name string) (model.Config, error) {
+	ctx, span := tracing.Start(ctx, "ConfigService.GetLatest")
+	defer span.End()
+	versions, err := s.repo.GetVersions(ctx, namespace, name)
+	if err != nil {
+		return model.Config{}, err
+	}
+	return versions[0], nil
+}
+
+// Search returns every config version in the namespace whose labels match
+// the selector.
+func (s ConfigService) Search(ctx context.Context, namespace string, selector model.Selector) ([]model.Config, error) {
+	ctx, span := tracing.Start(ctx, "ConfigService.Search")
+	defer span.End()
+	if err := s.namespaces.exists(ctx, namespace); err != nil {
+		return nil, err
+	}
+	return s.repo.Search(ctx, namespace, selector)
+}
+
+func (s ConfigService) Delete(ctx context.Context, namespace, name string, version int) error {
+	ctx, span := tracing.Start(ctx, "ConfigService.Delete")
+	defer span.End()
+	existing, err := s.repo.Get(ctx, namespace, name, version)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, namespace, name, version); err != nil {
+		return err
+	}
+	s.quotas.forget(namespace)
+	logging.FromContext(ctx).Debug("config version deleted", "namespace", namespace, "name", name, "version", version)
+	s.events.Publish(events.Event{
+		Type:      events.Deleted,
+		Resource:  events.ResourceConfigs,
+		Namespace: namespace,
+		Name:      name,
+		Version:   version,
+		Object:    existing,
+	})
+	return nil
 }
 
+// Rollback creates a new latest version of the config whose parameters equal
+// those of version to. The author and reason are kept in its metadata.
+func (s ConfigService) Rollback(ctx context.Context, namespace, name string, to int, author, reason string) (model.Config, error) {
+	ctx, span := tracing.Start(ctx, "ConfigService.Rollback")
+	defer span.End()
+	versions, err := s.repo.GetVersions(ctx, namespace, name)
+	if err != nil {
+		return model.Config{}, err
+	}
+	latest := versions[0]
+	highest, err := highWaterMark(ctx, s.repo, namespace, name, latest.Version)
+	if err != nil {
+		return model.Config{}, err
+	}
+	if to < 1 || to > highest {
+		return model.Config{}, model.ErrConfigNotFound
+	}
+	if to == latest.Version {
+		return model.Config{}, model.ErrInvalidRollback
+	}
+
+	var target *model.Config
+	for i := range versions {
+		if versions[i].Version == to {
+			target = &versions[i]
+			break
+		}
+	}
+	if target == nil {
+		return model.Config{}, model.ErrVersionDeleted
+	}
+
+	newConfig := model.NewConfig(name, highest+1)
+	newConfig.Namespace = latest.Namespace
+	newConfig.Parameters = append(newConfig.Parameters, target.Parameters...)
+	newConfig.Labels = append(newConfig.Labels, target.Labels...)
+	newConfig.Metadata = rollbackMetadata(to, author, reason)
+
+	return s.add(ctx, newConfig, events.NewVersion)
+}
 
+// highWaterMark returns the highest version of a name ever stored, so that
+// a deleted newest version is told apart from one that never existed and its
+// number is not reused. It is at least latest, since older stores did not
+// keep the mark.
+func highWaterMark(ctx context.Context, repo interface {
+	HighestVersion(ctx context.Context, namespace, name string) (int, error)
+}, namespace, name string, latest int) (int, error) {
+	highest, err := repo.HighestVersion(ctx, namespace, name)
+	if err != nil {
+		return 0, err
+	}
+	if highest < latest {
+		return latest, nil
+	}
+	return highest, nil
+}
 
-func (s ConfigService) Delete(name string, version int) error {
-	return s.repo.Delete(name, version)
+func rollbackMetadata(to int, author, reason string) map[string]string {
+	metadata := map[string]string{
+		model.MetadataRollbackOf: strconv.Itoa(to),
+		model.MetadataAuthor:     author,
+	}
+	if reason != "" {
+		metadata[model.MetadataReason] = reason
+	}
+	return metadata
 }
 
+// Diff compares two versions of the named config.
+func (s ConfigService) Diff(ctx context.Context, namespace, name string, from, to int) (model.ConfigDiff, error) {
+	ctx, span := tracing.Start(ctx, "ConfigService.Diff")
+	defer span.End()
+	fromConfig, err := s.repo.Get(ctx, namespace, name, from)
+	if err != nil {
+		return model.ConfigDiff{}, err
+	}
+	toConfig, err := s.repo.Get(ctx, namespace, name, to)
+	if err != nil {
+		return model.ConfigDiff{}, err
+	}
+	return model.DiffConfigs(fromConfig, toConfig), nil
+}
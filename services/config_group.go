@@ -1,70 +1,172 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"projekat/events"
+	"projekat/logging"
 	"projekat/model"
-	"strings"
+	"projekat/tracing"
+	"time"
 )
 
 type ConfigGroupService struct {
-	repo model.ConfigGroupRepository
+	repo       model.ConfigGroupRepository
+	namespaces NamespaceService
+	quotas     QuotaService
+	events     *events.Broker
 }
 
-func NewConfigGroupService(repo model.ConfigGroupRepository) ConfigGroupService {
+func NewConfigGroupService(repo model.ConfigGroupRepository, namespaces NamespaceService, quotas QuotaService, broker *events.Broker) ConfigGroupService {
 	return ConfigGroupService{
-		repo: repo,
+		repo:       repo,
+		namespaces: namespaces,
+		quotas:     quotas,
+		events:     broker,
 	}
 }
 
-func (s ConfigGroupService) Add(group model.ConfigGroup) error {
-	return s.repo.Add(group)
+// Add stores a group in its namespace, which must exist, and returns the
+// stored version.
+func (s ConfigGroupService) Add(ctx context.Context, group model.ConfigGroup) (model.ConfigGroup, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.Add")
+	defer span.End()
+	if !model.ValidResourceName(group.Name) {
+		return model.ConfigGroup{}, model.ErrInvalidName
+	}
+	group.Namespace = model.NamespaceOrDefault(group.Namespace)
+	group.CreatedAt = time.Now().UTC()
+	eventType := events.Created
+	if versions, err := s.repo.GetVersions(ctx, group.Namespace, group.Name); err == nil && len(versions) > 0 {
+		eventType = events.NewVersion
+	}
+	return s.add(ctx, group, eventType)
 }
 
-func (s ConfigGroupService) Get(name string, version int) (model.ConfigGroup, error) {
-	return s.repo.Get(name, version)
+// add stores a group version, if it fits the namespace quota, and announces
+// it to watchers. It returns the version as stored, with its creator.
+func (s ConfigGroupService) add(ctx context.Context, group model.ConfigGroup, eventType events.Type) (model.ConfigGroup, error) {
+	group.Metadata = withCreator(ctx, group.Metadata)
+	err := s.namespaces.guard(ctx, group.Namespace, func() error {
+		return s.quotas.admitGroup(ctx, group, func() error {
+			return s.repo.Add(ctx, group)
+		})
+	})
+	if err != nil {
+		return model.ConfigGroup{}, err
+	}
+	logging.FromContext(ctx).Debug("group version stored", "namespace", group.Namespace, "name", group.Name, "version", group.Version)
+	s.events.Publish(events.Event{
+		Type:      eventType,
+		Resource:  events.ResourceGroups,
+		Namespace: group.Namespace,
+		Name:      group.Name,
+		Version:   group.Version,
+		Object:    group,
+	})
+	return group, nil
 }
 
-func (s ConfigGroupService) GetAll() ([]model.ConfigGroup, error) {
-	return s.repo.GetAll()
+func (s ConfigGroupService) Get(ctx context.Context, namespace, name string, version int) (model.ConfigGroup, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.Get")
+	defer span.End()
+	return s.repo.Get(ctx, namespace, name, version)
 }
 
+func (s ConfigGroupService) GetAll(ctx context.Context, namespace string) ([]model.ConfigGroup, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.GetAll")
+	defer span.End()
+	if err := s.namespaces.exists(ctx, namespace); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAll(ctx, namespace)
+}
 
-func (s ConfigGroupService) Delete(name string, version int) error {
-	return s.repo.Delete(name, version)
+// GetVersions returns the version history of a group, newest first.
+func (s ConfigGroupService) GetVersions(ctx context.Context, namespace, name string) ([]model.ConfigGroup, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.GetVersions")
+	defer span.End()
+	return s.repo.GetVersions(ctx, namespace, name)
 }
 
-func (s ConfigGroupService) CreateGroupWithConfig(groupName string, currentVersion int, config model.GroupConfig) (model.ConfigGroup, error) {
-	existingGroup, err := s.repo.Get(groupName, currentVersion)
+func (s ConfigGroupService) GetLatest(ctx context.Context, namespace, name string) (model.ConfigGroup, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.GetLatest")
+	defer span.End()
+	versions, err := s.repo.GetVersions(ctx, namespace, name)
 	if err != nil {
 		return model.ConfigGroup{}, err
 	}
-	
+	return versions[0], nil
+}
+
+// Search returns every config in any group version of the namespace whose
+// labels match the selector, together with the owning group name and
+// version.
+func (s ConfigGroupService) Search(ctx context.Context, namespace string, selector model.Selector) ([]model.GroupConfigMatch, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.Search")
+	defer span.End()
+	if err := s.namespaces.exists(ctx, namespace); err != nil {
+		return nil, err
+	}
+	return s.repo.Search(ctx, namespace, selector)
+}
+
+func (s ConfigGroupService) Delete(ctx context.Context, namespace, name string, version int) error {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.Delete")
+	defer span.End()
+	existing, err := s.repo.Get(ctx, namespace, name, version)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, namespace, name, version); err != nil {
+		return err
+	}
+	s.quotas.forget(namespace)
+	logging.FromContext(ctx).Debug("group version deleted", "namespace", namespace, "name", name, "version", version)
+	s.events.Publish(events.Event{
+		Type:      events.Deleted,
+		Resource:  events.ResourceGroups,
+		Namespace: namespace,
+		Name:      name,
+		Version:   version,
+		Object:    existing,
+	})
+	return nil
+}
+
+func (s ConfigGroupService) CreateGroupWithConfig(ctx context.Context, namespace, groupName string, currentVersion int, config model.GroupConfig) (model.ConfigGroup, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.CreateGroupWithConfig")
+	defer span.End()
+	existingGroup, err := s.repo.Get(ctx, namespace, groupName, currentVersion)
+	if err != nil {
+		return model.ConfigGroup{}, err
+	}
+
 	newVersion := currentVersion + 1
 	newGroup := model.NewConfigGroup(groupName, newVersion)
-	
+	newGroup.Namespace = existingGroup.Namespace
+
 	for _, existingConfig := range existingGroup.Configs {
 		newGroup.AddConfig(existingConfig)
 	}
-	
+
 	newGroup.AddConfig(config)
-	
-	err = s.repo.Add(newGroup)
-	if err != nil {
-		return model.ConfigGroup{}, err
-	}
-	
-	return newGroup, nil
+
+	return s.add(ctx, newGroup, events.NewVersion)
 }
 
-func (s ConfigGroupService) CreateGroupWithoutConfig(groupName string, currentVersion int, configName string) (model.ConfigGroup, error) {
-	existingGroup, err := s.repo.Get(groupName, currentVersion)
+func (s ConfigGroupService) CreateGroupWithoutConfig(ctx context.Context, namespace, groupName string, currentVersion int, configName string) (model.ConfigGroup, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.CreateGroupWithoutConfig")
+	defer span.End()
+	existingGroup, err := s.repo.Get(ctx, namespace, groupName, currentVersion)
 	if err != nil {
 		return model.ConfigGroup{}, err
 	}
-	
+
 	newVersion := currentVersion + 1
 	newGroup := model.NewConfigGroup(groupName, newVersion)
-	
+	newGroup.Namespace = existingGroup.Namespace
+
 	found := false
 	for _, existingConfig := range existingGroup.Configs {
 		if existingConfig.Name != configName {
@@ -73,121 +175,137 @@ func (s ConfigGroupService) CreateGroupWithoutConfig(groupName string, currentVe
 			found = true
 		}
 	}
-	
+
 	if !found {
-		return model.ConfigGroup{}, errors.New("config not found in group")
+		return model.ConfigGroup{}, model.ErrConfigNotInGroup
 	}
-	
-	err = s.repo.Add(newGroup)
+
+	return s.add(ctx, newGroup, events.NewVersion)
+}
+
+// Rollback creates a new latest version of the group whose configs equal
+// those of version to. The author and reason are kept in its metadata.
+func (s ConfigGroupService) Rollback(ctx context.Context, namespace, groupName string, to int, author, reason string) (model.ConfigGroup, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.Rollback")
+	defer span.End()
+	versions, err := s.repo.GetVersions(ctx, namespace, groupName)
 	if err != nil {
 		return model.ConfigGroup{}, err
 	}
-	
-	return newGroup, nil
+	latest := versions[0]
+	highest, err := highWaterMark(ctx, s.repo, namespace, groupName, latest.Version)
+	if err != nil {
+		return model.ConfigGroup{}, err
+	}
+	if to < 1 || to > highest {
+		return model.ConfigGroup{}, model.ErrGroupNotFound
+	}
+	if to == latest.Version {
+		return model.ConfigGroup{}, model.ErrInvalidRollback
+	}
+
+	var target *model.ConfigGroup
+	for i := range versions {
+		if versions[i].Version == to {
+			target = &versions[i]
+			break
+		}
+	}
+	if target == nil {
+		return model.ConfigGroup{}, model.ErrVersionDeleted
+	}
+
+	newGroup := model.NewConfigGroup(groupName, highest+1)
+	newGroup.Namespace = latest.Namespace
+	for _, config := range target.Configs {
+		newGroup.AddConfig(config)
+	}
+	newGroup.Metadata = rollbackMetadata(to, author, reason)
+
+	return s.add(ctx, newGroup, events.NewVersion)
 }
 
-func (s ConfigGroupService) GetConfig(groupName string, groupVersion int, configName string) (model.GroupConfig, error) {
-	group, err := s.repo.Get(groupName, groupVersion)
+// Diff compares two versions of the named group.
+func (s ConfigGroupService) Diff(ctx context.Context, namespace, groupName string, from, to int) (model.GroupDiff, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.Diff")
+	defer span.End()
+	fromGroup, err := s.repo.Get(ctx, namespace, groupName, from)
+	if err != nil {
+		return model.GroupDiff{}, err
+	}
+	toGroup, err := s.repo.Get(ctx, namespace, groupName, to)
+	if err != nil {
+		return model.GroupDiff{}, err
+	}
+	return model.DiffGroups(fromGroup, toGroup), nil
+}
+
+func (s ConfigGroupService) GetConfig(ctx context.Context, namespace, groupName string, groupVersion int, configName string) (model.GroupConfig, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.GetConfig")
+	defer span.End()
+	group, err := s.repo.Get(ctx, namespace, groupName, groupVersion)
 	if err != nil {
 		return model.GroupConfig{}, err
 	}
 	config, found := group.GetConfig(configName)
 	if !found {
-		return model.GroupConfig{}, errors.New("config not found in group")
+		return model.GroupConfig{}, model.ErrConfigNotInGroup
 	}
 	return config, nil
 }
 
-// labels string format example: "k1:v1;k2:v2"
-func parseLabelsStringToMap(labelsStr string) (map[string]string, error) {
-    labels := make(map[string]string)
-    if strings.TrimSpace(labelsStr) == "" {
-        return labels, nil
-    }
-    pairs := strings.Split(labelsStr, ";")
-    for _, pair := range pairs {
-        pair = strings.TrimSpace(pair)
-        if pair == "" {
-            continue
-        }
-        kv := strings.SplitN(pair, ":", 2)
-        if len(kv) != 2 {
-            return nil, errors.New("invalid labels format; expected key:value pairs separated by ';'")
-        }
-        key := strings.TrimSpace(kv[0])
-        value := strings.TrimSpace(kv[1])
-        if key == "" || value == "" {
-            return nil, errors.New("invalid labels: empty key or value")
-        }
-        labels[key] = value
-    }
-    return labels, nil
-}
-
-func configMatchesAllLabels(cfg model.GroupConfig, required map[string]string) bool {
-    if len(required) == 0 {
-        return true
-    }
-    have := make(map[string]string)
-    for _, l := range cfg.Labels {
-        have[l.Key] = l.Value
-    }
-    for k, v := range required {
-        hv, ok := have[k]
-        if !ok || hv != v {
-            return false
-        }
-    }
-    return true
-}
-
-func (s ConfigGroupService) FilterConfigsByLabels(groupName string, groupVersion int, labelsStr string) ([]model.GroupConfig, error) {
-    group, err := s.repo.Get(groupName, groupVersion)
-    if err != nil {
-        return nil, err
-    }
-    labelsMap, err := parseLabelsStringToMap(labelsStr)
-    if err != nil {
-        return nil, err
-    }
-    result := make([]model.GroupConfig, 0)
-    for _, cfg := range group.Configs {
-        if configMatchesAllLabels(cfg, labelsMap) {
-            result = append(result, cfg)
-        }
-    }
-    return result, nil
-}
-
-func (s ConfigGroupService) CreateGroupWithoutConfigsByLabels(groupName string, currentVersion int, labelsStr string) (model.ConfigGroup, error) {
-    existingGroup, err := s.repo.Get(groupName, currentVersion)
-    if err != nil {
-        return model.ConfigGroup{}, err
-    }
-    labelsMap, err := parseLabelsStringToMap(labelsStr)
-    if err != nil {
-        return model.ConfigGroup{}, err
-    }
-
-    newVersion := currentVersion + 1
-    newGroup := model.NewConfigGroup(groupName, newVersion)
-
-    removedAny := false
-    for _, cfg := range existingGroup.Configs {
-        if configMatchesAllLabels(cfg, labelsMap) {
-            removedAny = true
-            continue
-        }
-        newGroup.AddConfig(cfg)
-    }
-
-    if !removedAny {
-        return model.ConfigGroup{}, errors.New("no configs matched given labels")
-    }
-
-    if err := s.repo.Add(newGroup); err != nil {
-        return model.ConfigGroup{}, err
-    }
-
-    return newGroup, nil
+// FilterConfigsByLabels returns the configs of a group version matching a
+// label selector (see model.Selector).
+func (s ConfigGroupService) FilterConfigsByLabels(ctx context.Context, namespace, groupName string, groupVersion int, labelsStr string) ([]model.GroupConfig, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.FilterConfigsByLabels")
+	defer span.End()
+	group, err := s.repo.Get(ctx, namespace, groupName, groupVersion)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := model.ParseSelector(labelsStr)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.GroupConfig, 0)
+	for _, cfg := range group.Configs {
+		if selector.Matches(cfg.Labels) {
+			result = append(result, cfg)
+		}
+	}
+	return result, nil
+}
+
+// CreateGroupWithoutConfigsByLabels creates the next group version without
+// the configs matching a label selector.
+func (s ConfigGroupService) CreateGroupWithoutConfigsByLabels(ctx context.Context, namespace, groupName string, currentVersion int, labelsStr string) (model.ConfigGroup, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.CreateGroupWithoutConfigsByLabels")
+	defer span.End()
+	existingGroup, err := s.repo.Get(ctx, namespace, groupName, currentVersion)
+	if err != nil {
+		return model.ConfigGroup{}, err
+	}
+	selector, err := model.ParseSelector(labelsStr)
+	if err != nil {
+		return model.ConfigGroup{}, err
+	}
+
+	newVersion := currentVersion + 1
+	newGroup := model.NewConfigGroup(groupName, newVersion)
+	newGroup.Namespace = existingGroup.Namespace
+
+	removedAny := false
+	for _, cfg := range existingGroup.Configs {
+		if selector.Matches(cfg.Labels) {
+			removedAny = true
+			continue
+		}
+		newGroup.AddConfig(cfg)
+	}
+
+	if !removedAny {
+		return model.ConfigGroup{}, errors.New("no configs matched given labels")
+	}
+
+	return s.add(ctx, newGroup, events.NewVersion)
 }
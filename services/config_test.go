@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"projekat/auth"
+	"projekat/model"
+	"projekat/repositories"
+	"reflect"
+	"testing"
+)
+
+func TestAddReturnsStoredVersion(t *testing.T) {
+	configs, _ := newQuotaTestServices(t, model.QuotaPolicy{})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{ID: "apikey:ci"})
+
+	added, err := configs.Add(ctx, testConfig("a", 1, "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := configs.Get(ctx, model.DefaultNamespace, "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(added, stored) {
+		t.Errorf("Add() returned %+v, but %+v was stored", added, stored)
+	}
+	if added.CreatedAt.IsZero() || added.Metadata[model.MetadataCreatedBy] != "apikey:ci" {
+		t.Errorf("Add() returned %+v without its creation time and creator", added)
+	}
+}
+
+func newGroupTestService(t *testing.T) ConfigGroupService {
+	t.Helper()
+	index := repositories.NewChangeIndex()
+	configs := repositories.NewConfigInMemRepository(index)
+	repo := repositories.NewConfigGroupInMemRepository(index)
+	namespaces := NewNamespaceService(repositories.NewNamespaceInMemRepository(), configs, repo)
+	if err := namespaces.EnsureDefault(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return NewConfigGroupService(repo, namespaces, NewQuotaService(model.QuotaPolicy{}, namespaces, configs, repo), nil)
+}
+
+func TestGroupVersionsReturnStoredVersion(t *testing.T) {
+	groups := newGroupTestService(t)
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{ID: "apikey:ci"})
+
+	group := model.NewConfigGroup("web", 1)
+	group.AddConfig(model.GroupConfig{Name: "db", Labels: []model.Label{{Key: "env", Value: "dev"}}})
+	if _, err := groups.Add(ctx, group); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name string
+		run  func() (model.ConfigGroup, error)
+	}{
+		{"add config", func() (model.ConfigGroup, error) {
+			return groups.CreateGroupWithConfig(ctx, model.DefaultNamespace, "web", 1, model.GroupConfig{Name: "cache"})
+		}},
+		{"remove config", func() (model.ConfigGroup, error) {
+			return groups.CreateGroupWithoutConfig(ctx, model.DefaultNamespace, "web", 2, "cache")
+		}},
+		{"remove by labels", func() (model.ConfigGroup, error) {
+			return groups.CreateGroupWithoutConfigsByLabels(ctx, model.DefaultNamespace, "web", 3, "env=dev")
+		}},
+		{"rollback", func() (model.ConfigGroup, error) {
+			return groups.Rollback(ctx, model.DefaultNamespace, "web", 1, "apikey:ci", "")
+		}},
+	}
+	for _, step := range steps {
+		returned, err := step.run()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		stored, err := groups.Get(ctx, model.DefaultNamespace, "web", returned.Version)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(returned, stored) {
+			t.Errorf("%s returned %+v, but %+v was stored", step.name, returned, stored)
+		}
+		if returned.Metadata[model.MetadataCreatedBy] != "apikey:ci" {
+			t.Errorf("%s returned metadata %v without the creator", step.name, returned.Metadata)
+		}
+	}
+}
+
+// TestRollbackAfterDeletingNewestVersion deletes version 3 of 3: rolling
+// back to it is 410 rather than 404, and the rollback becomes version 4.
+func TestRollbackAfterDeletingNewestVersion(t *testing.T) {
+	ctx := context.Background()
+	configs, _ := newQuotaTestServices(t, model.QuotaPolicy{})
+	groups := newGroupTestService(t)
+	for v := 1; v <= 3; v++ {
+		if _, err := configs.Add(ctx, testConfig("db", v, "x")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := groups.Add(ctx, model.NewConfigGroup("web", v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := configs.Delete(ctx, model.DefaultNamespace, "db", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := groups.Delete(ctx, model.DefaultNamespace, "web", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	rollbacks := map[string]func(to int) (int, error){
+		"config": func(to int) (int, error) {
+			config, err := configs.Rollback(ctx, model.DefaultNamespace, "db", to, "ops", "")
+			return config.Version, err
+		},
+		"group": func(to int) (int, error) {
+			group, err := groups.Rollback(ctx, model.DefaultNamespace, "web", to, "ops", "")
+			return group.Version, err
+		},
+	}
+	notFound := map[string]error{"config": model.ErrConfigNotFound, "group": model.ErrGroupNotFound}
+	for kind, rollback := range rollbacks {
+		if _, err := rollback(3); !errors.Is(err, model.ErrVersionDeleted) {
+			t.Errorf("%s rollback to the deleted newest version: %v, want ErrVersionDeleted", kind, err)
+		}
+		if _, err := rollback(4); !errors.Is(err, notFound[kind]) {
+			t.Errorf("%s rollback to a version never stored: %v, want %v", kind, err, notFound[kind])
+		}
+		version, err := rollback(1)
+		if err != nil {
+			t.Fatalf("%s rollback: %v", kind, err)
+		}
+		if version != 4 {
+			t.Errorf("%s rollback created version %d, want 4", kind, version)
+		}
+	}
+}
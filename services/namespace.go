@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"projekat/logging"
+	"projekat/model"
+	"sync"
+	"time"
+)
+
+// NamespaceService manages namespaces. Writes to configs and groups run
+// through guard so a namespace cannot be deleted while something is being
+// added to it.
+type NamespaceService struct {
+	repo    model.NamespaceRepository
+	configs model.ConfigRepository
+	groups  model.ConfigGroupRepository
+	mu      *sync.RWMutex
+}
+
+func NewNamespaceService(repo model.NamespaceRepository, configs model.ConfigRepository, groups model.ConfigGroupRepository) NamespaceService {
+	return NamespaceService{
+		repo:    repo,
+		configs: configs,
+		groups:  groups,
+		mu:      &sync.RWMutex{},
+	}
+}
+
+// EnsureDefault creates the default namespace if it does not exist yet.
+func (s NamespaceService) EnsureDefault(ctx context.Context) error {
+	if _, err := s.repo.Get(ctx, model.DefaultNamespace); err == nil {
+		return nil
+	}
+	_, err := s.Create(ctx, model.DefaultNamespace)
+	if err == model.ErrNamespaceExists {
+		return nil
+	}
+	return err
+}
+
+func (s NamespaceService) Create(ctx context.Context, name string) (model.Namespace, error) {
+	if !model.ValidNamespaceName(name) {
+		return model.Namespace{}, model.ErrInvalidNamespace
+	}
+	namespace := model.Namespace{
+		Name:      name,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.repo.Add(ctx, namespace); err != nil {
+		return model.Namespace{}, err
+	}
+	logging.FromContext(ctx).Info("namespace created", "namespace", name)
+	return namespace, nil
+}
+
+func (s NamespaceService) Get(ctx context.Context, name string) (model.Namespace, error) {
+	return s.repo.Get(ctx, name)
+}
+
+func (s NamespaceService) GetAll(ctx context.Context) ([]model.Namespace, error) {
+	return s.repo.GetAll(ctx)
+}
+
+// Delete removes an empty namespace. The default namespace is never
+// deleted.
+func (s NamespaceService) Delete(ctx context.Context, name string) error {
+	if name == model.DefaultNamespace {
+		return model.ErrNamespaceProtected
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.repo.Get(ctx, name); err != nil {
+		return err
+	}
+	configs, err := s.configs.GetAll(ctx, name)
+	if err != nil {
+		return err
+	}
+	groups, err := s.groups.GetAll(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(configs) > 0 || len(groups) > 0 {
+		return model.ErrNamespaceNotEmpty
+	}
+	if err := s.repo.Delete(ctx, name); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("namespace deleted", "namespace", name)
+	return nil
+}
+
+// exists returns ErrNamespaceNotFound for unknown namespaces.
+func (s NamespaceService) exists(ctx context.Context, name string) error {
+	_, err := s.repo.Get(ctx, name)
+	return err
+}
+
+// guard runs fn if the namespace exists, holding off concurrent deletes of
+// the namespace until fn returns. fn does not run once ctx is done, as the
+// caller may have waited for the lock past its deadline.
+func (s NamespaceService) guard(ctx context.Context, name string, fn func() error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.exists(ctx, name); err != nil {
+		return err
+	}
+	return fn()
+}
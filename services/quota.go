@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"projekat/auth"
+	"projekat/model"
+	"sync"
+	"time"
+)
+
+// QuotaService enforces the per-namespace and per-principal quotas. The
+// usage of a namespace is scanned from the repositories on its first write
+// and then kept up to date by this server, under a lock per namespace. The
+// lock is process-local: with several servers sharing a Consul backend, each
+// sees the others' writes only when it rescans, every usageRescanInterval,
+// and concurrent writes through different servers can overshoot a quota.
+type QuotaService struct {
+	policy     model.QuotaPolicy
+	namespaces NamespaceService
+	configs    model.ConfigRepository
+	groups     model.ConfigGroupRepository
+	usage      *usageCache
+	// enabled is false when the policy sets no limits at all, so writes
+	// need not be counted.
+	enabled bool
+}
+
+func NewQuotaService(policy model.QuotaPolicy, namespaces NamespaceService, configs model.ConfigRepository, groups model.ConfigGroupRepository) QuotaService {
+	return QuotaService{
+		policy:     policy,
+		namespaces: namespaces,
+		configs:    configs,
+		groups:     groups,
+		usage:      &usageCache{entries: make(map[string]*usageEntry)},
+		enabled:    !policy.Empty(),
+	}
+}
+
+// LoadQuotaPolicy reads a model.QuotaPolicy from a JSON file.
+func LoadQuotaPolicy(file string) (model.QuotaPolicy, error) {
+	var policy model.QuotaPolicy
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return policy, err
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return policy, fmt.Errorf("parse quotas: %w", err)
+	}
+	if err := validateQuota(policy.Default); err != nil {
+		return policy, fmt.Errorf("default quota: %w", err)
+	}
+	for namespace, quota := range policy.Namespaces {
+		if !model.ValidNamespaceName(namespace) {
+			return policy, fmt.Errorf("quota for %q: %w", namespace, model.ErrInvalidNamespace)
+		}
+		if err := validateQuota(quota); err != nil {
+			return policy, fmt.Errorf("quota for %q: %w", namespace, err)
+		}
+	}
+	for principal, quota := range policy.Principals {
+		if err := validateQuota(quota); err != nil {
+			return policy, fmt.Errorf("quota for principal %q: %w", principal, err)
+		}
+	}
+	return policy, nil
+}
+
+func validateQuota(q model.Quota) error {
+	if q.MaxConfigs < 0 || q.MaxGroups < 0 || q.MaxVersionsPerName < 0 ||
+		q.MaxParameters < 0 || q.MaxValueBytes < 0 || q.MaxTotalBytes < 0 {
+		return errors.New("limits must not be negative")
+	}
+	return nil
+}
+
+// usageRescanInterval bounds how long cached usage may miss writes made
+// through other servers.
+const usageRescanInterval = 30 * time.Second
+
+// namespaceUsage is the usage of a namespace together with the number of
+// stored versions of every config and group name, and the same for the
+// versions created by each principal.
+type namespaceUsage struct {
+	model.Usage
+	configVersions map[string]int
+	groupVersions  map[string]int
+	principals     map[string]*namespaceUsage
+}
+
+func newNamespaceUsage() *namespaceUsage {
+	return &namespaceUsage{
+		configVersions: make(map[string]int),
+		groupVersions:  make(map[string]int),
+		principals:     make(map[string]*namespaceUsage),
+	}
+}
+
+// principal returns the usage of the versions created by id.
+func (u *namespaceUsage) principal(id string) *namespaceUsage {
+	if p, ok := u.principals[id]; ok {
+		return p
+	}
+	return newNamespaceUsage()
+}
+
+// record counts a stored version of a config or group, for the namespace
+// and for the principal that created it.
+func (u *namespaceUsage) record(group bool, name string, size int64, creator string) {
+	u.add(group, name, size)
+	if creator == "" {
+		return
+	}
+	p, ok := u.principals[creator]
+	if !ok {
+		p = newNamespaceUsage()
+		u.principals[creator] = p
+	}
+	p.add(group, name, size)
+}
+
+func (u *namespaceUsage) add(group bool, name string, size int64) {
+	if group {
+		if u.groupVersions[name] == 0 {
+			u.Groups++
+		}
+		u.groupVersions[name]++
+		u.GroupVersions++
+	} else {
+		if u.configVersions[name] == 0 {
+			u.Configs++
+		}
+		u.configVersions[name]++
+		u.ConfigVersions++
+	}
+	u.TotalBytes += size
+}
+
+// usageEntry holds the cached usage of one namespace. mu serializes the
+// admissions in the namespace so concurrent writes cannot overshoot a quota
+// together.
+type usageEntry struct {
+	mu sync.Mutex
+	// usage is nil until the namespace is scanned, and again after a delete.
+	usage     *namespaceUsage
+	scannedAt time.Time
+}
+
+type usageCache struct {
+	mu      sync.Mutex
+	entries map[string]*usageEntry
+}
+
+func (c *usageCache) entry(namespace string) *usageEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[namespace]
+	if !ok {
+		entry = &usageEntry{}
+		c.entries[namespace] = entry
+	}
+	return entry
+}
+
+func (s QuotaService) scan(ctx context.Context, namespace string) (*namespaceUsage, error) {
+	// Admission waits for the namespace lock before scanning; give up if the
+	// request ended meanwhile.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	usage := newNamespaceUsage()
+	configs, err := s.configs.GetAll(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, config := range configs {
+		usage.record(false, config.Name, config.Size(), config.Metadata[model.MetadataCreatedBy])
+	}
+	groups, err := s.groups.GetAll(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		usage.record(true, group.Name, group.Size(), group.Metadata[model.MetadataCreatedBy])
+	}
+	return usage, nil
+}
+
+// current returns the usage of the namespace, scanning it if it is not
+// cached or the cache is too old. The caller holds entry.mu.
+func (s QuotaService) current(ctx context.Context, entry *usageEntry, namespace string) (*namespaceUsage, error) {
+	if entry.usage != nil && time.Since(entry.scannedAt) < usageRescanInterval {
+		return entry.usage, nil
+	}
+	usage, err := s.scan(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	entry.usage = usage
+	entry.scannedAt = time.Now()
+	return usage, nil
+}
+
+// forget drops the cached usage of a namespace after versions were deleted
+// from it.
+func (s QuotaService) forget(namespace string) {
+	if !s.enabled {
+		return
+	}
+	entry := s.usage.entry(model.NamespaceOrDefault(namespace))
+	entry.mu.Lock()
+	entry.usage = nil
+	entry.mu.Unlock()
+}
+
+// Usage returns what the namespace stores and its quota.
+func (s QuotaService) Usage(ctx context.Context, namespace string) (model.Usage, model.Quota, error) {
+	var result model.Usage
+	err := s.read(ctx, namespace, func(usage *namespaceUsage) {
+		result = usage.Usage
+	})
+	return result, s.policy.For(namespace), err
+}
+
+// PrincipalUsage returns what the principal created in the namespace and
+// its quota.
+func (s QuotaService) PrincipalUsage(ctx context.Context, namespace, principal string) (model.Usage, model.Quota, error) {
+	var result model.Usage
+	err := s.read(ctx, namespace, func(usage *namespaceUsage) {
+		result = usage.principal(principal).Usage
+	})
+	return result, s.policy.ForPrincipal(principal), err
+}
+
+// read calls fn with the usage of the namespace while holding its lock.
+func (s QuotaService) read(ctx context.Context, namespace string, fn func(*namespaceUsage)) error {
+	if err := s.namespaces.exists(ctx, namespace); err != nil {
+		return err
+	}
+	namespace = model.NamespaceOrDefault(namespace)
+	entry := s.usage.entry(namespace)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	usage, err := s.current(ctx, entry, namespace)
+	if err != nil {
+		return err
+	}
+	fn(usage)
+	return nil
+}
+
+// checkObject applies the per-object limits to one set of parameters and
+// labels.
+func checkObject(quota model.Quota, name string, parameters []model.ConfigParameter, labels []model.Label) error {
+	if quota.MaxParameters > 0 && len(parameters) > quota.MaxParameters {
+		return fmt.Errorf("%w: %q has %d parameters, at most %d are allowed",
+			model.ErrQuotaExceeded, name, len(parameters), quota.MaxParameters)
+	}
+	if quota.MaxValueBytes > 0 {
+		for _, p := range parameters {
+			if len(p.Value) > quota.MaxValueBytes {
+				return fmt.Errorf("%w: value of %q in %q is %d bytes, at most %d are allowed",
+					model.ErrQuotaExceeded, p.Key, name, len(p.Value), quota.MaxValueBytes)
+			}
+		}
+		for _, l := range labels {
+			if len(l.Value) > quota.MaxValueBytes {
+				return fmt.Errorf("%w: value of label %q in %q is %d bytes, at most %d are allowed",
+					model.ErrQuotaExceeded, l.Key, name, len(l.Value), quota.MaxValueBytes)
+			}
+		}
+	}
+	return nil
+}
+
+// checkStored applies the limits on stored data to a new version of a
+// config or group. owner names whose usage is checked in errors.
+func checkStored(quota model.Quota, usage *namespaceUsage, owner string, group bool, name string, size int64) error {
+	kind, names, versions, maxNames := "configs", usage.Configs, usage.configVersions[name], quota.MaxConfigs
+	if group {
+		kind, names, versions, maxNames = "groups", usage.Groups, usage.groupVersions[name], quota.MaxGroups
+	}
+	if versions == 0 && maxNames > 0 && names >= maxNames {
+		return fmt.Errorf("%w: %s already has %d %s, at most %d are allowed",
+			model.ErrQuotaExceeded, owner, names, kind, maxNames)
+	}
+	if quota.MaxVersionsPerName > 0 && versions >= quota.MaxVersionsPerName {
+		return fmt.Errorf("%w: %q already has %d versions by %s, at most %d are allowed",
+			model.ErrQuotaExceeded, name, versions, owner, quota.MaxVersionsPerName)
+	}
+	if quota.MaxTotalBytes > 0 && usage.TotalBytes+size > quota.MaxTotalBytes {
+		return fmt.Errorf("%w: %s uses %d of %d bytes, %d more do not fit",
+			model.ErrStorageFull, owner, usage.TotalBytes, quota.MaxTotalBytes, size)
+	}
+	return nil
+}
+
+// admit runs store if a new version of a config or group fits the quota of
+// its namespace and of the principal that creates it, and counts it.
+func (s QuotaService) admit(ctx context.Context, namespace string, group bool, name string, size int64, creator string, store func() error) error {
+	if !s.enabled {
+		return store()
+	}
+	quota := s.policy.For(namespace)
+	principalQuota := s.policy.ForPrincipal(creator)
+
+	entry := s.usage.entry(namespace)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if quota != (model.Quota{}) || principalQuota != (model.Quota{}) {
+		usage, err := s.current(ctx, entry, namespace)
+		if err != nil {
+			return err
+		}
+		if err := checkStored(quota, usage, "the namespace", group, name, size); err != nil {
+			return err
+		}
+		owner := fmt.Sprintf("principal %q", creator)
+		if err := checkStored(principalQuota, usage.principal(creator), owner, group, name, size); err != nil {
+			return err
+		}
+	}
+	if err := store(); err != nil {
+		return err
+	}
+	if entry.usage != nil {
+		entry.usage.record(group, name, size, creator)
+	}
+	return nil
+}
+
+// admitConfig runs store if config fits the quotas that apply to it.
+func (s QuotaService) admitConfig(ctx context.Context, config model.Config, store func() error) error {
+	creator := config.Metadata[model.MetadataCreatedBy]
+	for _, quota := range []model.Quota{s.policy.For(config.Namespace), s.policy.ForPrincipal(creator)} {
+		if err := checkObject(quota, config.Name, config.Parameters, config.Labels); err != nil {
+			return err
+		}
+	}
+	return s.admit(ctx, config.Namespace, false, config.Name, config.Size(), creator, store)
+}
+
+// admitGroup runs store if group fits the quotas that apply to it.
+func (s QuotaService) admitGroup(ctx context.Context, group model.ConfigGroup, store func() error) error {
+	creator := group.Metadata[model.MetadataCreatedBy]
+	for _, quota := range []model.Quota{s.policy.For(group.Namespace), s.policy.ForPrincipal(creator)} {
+		for _, config := range group.Configs {
+			if err := checkObject(quota, config.Name, config.Parameters, config.Labels); err != nil {
+				return err
+			}
+		}
+	}
+	return s.admit(ctx, group.Namespace, true, group.Name, group.Size(), creator, store)
+}
+
+// withCreator returns metadata naming the principal in ctx as the creator of
+// a new version. A creator copied from an older version or sent by the
+// client is replaced.
+func withCreator(ctx context.Context, metadata map[string]string) map[string]string {
+	principal, authenticated := auth.PrincipalFromContext(ctx)
+	if _, ok := metadata[model.MetadataCreatedBy]; !ok && !authenticated {
+		return metadata
+	}
+	result := make(map[string]string, len(metadata)+1)
+	for key, value := range metadata {
+		result[key] = value
+	}
+	delete(result, model.MetadataCreatedBy)
+	if authenticated {
+		result[model.MetadataCreatedBy] = principal.ID
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
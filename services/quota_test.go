@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"projekat/auth"
+	"projekat/events"
+	"projekat/model"
+	"projekat/repositories"
+	"sync"
+	"testing"
+)
+
+func newQuotaTestServices(t *testing.T, policy model.QuotaPolicy) (ConfigService, QuotaService) {
+	t.Helper()
+	index := repositories.NewChangeIndex()
+	configs := repositories.NewConfigInMemRepository(index)
+	groups := repositories.NewConfigGroupInMemRepository(index)
+	namespaces := NewNamespaceService(repositories.NewNamespaceInMemRepository(), configs, groups)
+	if err := namespaces.EnsureDefault(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	quotas := NewQuotaService(policy, namespaces, configs, groups)
+	return NewConfigService(configs, namespaces, quotas, events.NewBroker(10)), quotas
+}
+
+func testConfig(name string, version int, value string) model.Config {
+	config := model.NewConfig(name, version)
+	config.Parameters = []model.ConfigParameter{{Key: "k", Value: value}}
+	return config
+}
+
+func TestQuotaCountsNamesAndVersions(t *testing.T) {
+	ctx := context.Background()
+	configs, quotas := newQuotaTestServices(t, model.QuotaPolicy{
+		Default: model.Quota{MaxConfigs: 2, MaxVersionsPerName: 2},
+	})
+
+	for _, c := range []model.Config{testConfig("a", 1, "x"), testConfig("a", 2, "x"), testConfig("b", 1, "x")} {
+		if _, err := configs.Add(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := configs.Add(ctx, testConfig("a", 3, "x")); !errors.Is(err, model.ErrQuotaExceeded) {
+		t.Errorf("third version of a: %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := configs.Add(ctx, testConfig("c", 1, "x")); !errors.Is(err, model.ErrQuotaExceeded) {
+		t.Errorf("third config: %v, want ErrQuotaExceeded", err)
+	}
+
+	// Deleting frees room even though the usage is cached.
+	if err := configs.Delete(ctx, model.DefaultNamespace, "b", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configs.Add(ctx, testConfig("c", 1, "x")); err != nil {
+		t.Errorf("config after a delete: %v", err)
+	}
+	usage, _, err := quotas.Usage(ctx, model.DefaultNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Configs != 2 || usage.ConfigVersions != 3 {
+		t.Errorf("usage = %+v, want 2 configs with 3 versions", usage)
+	}
+}
+
+func TestQuotaLimitsLabelValues(t *testing.T) {
+	configs, _ := newQuotaTestServices(t, model.QuotaPolicy{Default: model.Quota{MaxValueBytes: 4}})
+	config := testConfig("a", 1, "x")
+	config.Labels = []model.Label{{Key: "team", Value: "platform"}}
+	if _, err := configs.Add(context.Background(), config); !errors.Is(err, model.ErrQuotaExceeded) {
+		t.Errorf("Add() = %v, want ErrQuotaExceeded for a long label value", err)
+	}
+}
+
+func TestPrincipalQuota(t *testing.T) {
+	configs, quotas := newQuotaTestServices(t, model.QuotaPolicy{
+		Principals: map[string]model.Quota{"apikey:ci": {MaxConfigs: 1}},
+	})
+	ci := auth.WithPrincipal(context.Background(), auth.Principal{ID: "apikey:ci"})
+	admin := auth.WithPrincipal(context.Background(), auth.Principal{ID: "apikey:admin"})
+
+	if _, err := configs.Add(ci, testConfig("a", 1, "x")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configs.Add(ci, testConfig("b", 1, "x")); !errors.Is(err, model.ErrQuotaExceeded) {
+		t.Errorf("second config by ci: %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := configs.Add(admin, testConfig("b", 1, "x")); err != nil {
+		t.Errorf("config by another principal: %v", err)
+	}
+
+	stored, err := configs.Get(ci, model.DefaultNamespace, "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Metadata[model.MetadataCreatedBy] != "apikey:ci" {
+		t.Errorf("metadata = %v, want the creator recorded", stored.Metadata)
+	}
+	usage, quota, err := quotas.PrincipalUsage(ci, model.DefaultNamespace, "apikey:ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Configs != 1 || quota.MaxConfigs != 1 {
+		t.Errorf("principal usage = %+v with limits %+v", usage, quota)
+	}
+}
+
+func TestCreatorCannotBeSpoofed(t *testing.T) {
+	configs, _ := newQuotaTestServices(t, model.QuotaPolicy{})
+	ctx := context.Background()
+	config := testConfig("a", 1, "x")
+	config.Metadata = map[string]string{model.MetadataCreatedBy: "apikey:someone"}
+	if _, err := configs.Add(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := configs.Get(ctx, model.DefaultNamespace, "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored.Metadata[model.MetadataCreatedBy]; ok {
+		t.Errorf("metadata = %v, want the client's creator dropped", stored.Metadata)
+	}
+}
+
+// TestQuotaConcurrentAdmissions writes from many goroutines at once; the
+// namespace lock must keep the total within the quota. Run it with -race.
+func TestQuotaConcurrentAdmissions(t *testing.T) {
+	const limit = 10
+	configs, _ := newQuotaTestServices(t, model.QuotaPolicy{Default: model.Quota{MaxConfigs: limit}})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	stored := 0
+	for i := 0; i < 4*limit; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := configs.Add(ctx, testConfig(fmt.Sprintf("c%d", i), 1, "x"))
+			if err == nil {
+				mu.Lock()
+				stored++
+				mu.Unlock()
+			} else if !errors.Is(err, model.ErrQuotaExceeded) {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if stored != limit {
+		t.Errorf("stored %d configs, want %d", stored, limit)
+	}
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"net/http"
+	"projekat/httpstatus"
+	"projekat/logging"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware starts a server span for every request, continuing the trace
+// of an incoming traceparent header, and tags the request's logger and
+// access log line with the trace ID. Spans are named after the method and
+// route template; install it with Router.Use so the template is known.
+func (t *Tracer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if sc, ok := Extract(r.Header); ok {
+			ctx = ContextWithRemoteSpanContext(ctx, sc)
+		}
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+		name := r.Method
+		if route != "" {
+			name += " " + route
+		}
+		span := t.start(ctx, name, KindServer, []Attribute{
+			Attr("http.request.method", r.Method),
+			Attr("http.route", route),
+			Attr("url.path", r.URL.Path),
+		})
+		defer span.End()
+
+		traceID := span.SpanContext().TraceID.String()
+		ctx = ContextWithSpan(ctx, span)
+		ctx = logging.NewContext(ctx, logging.FromContext(ctx).With("trace_id", traceID))
+		logging.SetField(ctx, "trace_id", traceID)
+
+		recorder := httpstatus.NewRecorder(w)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(Attr("http.response.status_code", recorder.Status))
+		if recorder.Status >= 500 {
+			span.SetStatus(StatusError, http.StatusText(recorder.Status))
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestMiddlewareExportsParentAndChild sends a request with a traceparent
+// through the middleware into a handler that starts a span of its own, and
+// reads both spans back from the file exporter.
+func TestMiddlewareExportsParentAndChild(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	exporter, err := NewFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := New(exporter, Options{ServiceName: "test"})
+	SetDefault(tracer)
+	defer SetDefault(nil)
+
+	router := mux.NewRouter()
+	router.Use(tracer.Middleware)
+	router.HandleFunc("/configs/{name}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "ConfigService.Get")
+		span.End()
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	const (
+		remoteTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+		remoteSpan  = "00f067aa0ba902b7"
+	)
+	r := httptest.NewRequest(http.MethodGet, "/configs/db", nil)
+	r.Header.Set("traceparent", "00-"+remoteTrace+"-"+remoteSpan+"-01")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	// Run exports what is queued once ctx is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tracer.Run(ctx)
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported otlpRequest
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatalf("exported %q: %v", data, err)
+	}
+	spans := map[string]otlpSpan{}
+	for _, span := range exported.ResourceSpans[0].ScopeSpans[0].Spans {
+		spans[span.Name] = span
+	}
+	server, ok := spans["GET /configs/{name}"]
+	if !ok {
+		t.Fatalf("no server span among %+v", spans)
+	}
+	child, ok := spans["ConfigService.Get"]
+	if !ok {
+		t.Fatalf("no child span among %+v", spans)
+	}
+	if server.TraceID != remoteTrace || child.TraceID != remoteTrace {
+		t.Errorf("trace IDs %s and %s, want the caller's %s", server.TraceID, child.TraceID, remoteTrace)
+	}
+	if server.ParentSpanID != remoteSpan {
+		t.Errorf("server span parent %q, want the caller's span %s", server.ParentSpanID, remoteSpan)
+	}
+	if child.ParentSpanID != server.SpanID || child.SpanID == server.SpanID {
+		t.Errorf("child span %s has parent %q, want the server span %s", child.SpanID, child.ParentSpanID, server.SpanID)
+	}
+	if server.Kind != KindServer || child.Kind != KindInternal {
+		t.Errorf("kinds %d and %d, want server and internal", server.Kind, child.Kind)
+	}
+	status := ""
+	for _, attr := range server.Attributes {
+		if attr.Key == "http.response.status_code" && attr.Value.IntValue != nil {
+			status = *attr.Value.IntValue
+		}
+	}
+	if status != "404" {
+		t.Errorf("recorded status %q, want 404", status)
+	}
+}
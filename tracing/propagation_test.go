@@ -0,0 +1,56 @@
+package tracing
+
+import "testing"
+
+func TestParseTraceparent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	tests := []struct {
+		name    string
+		value   string
+		ok      bool
+		sampled bool
+	}{
+		{"sampled", "00-" + traceID + "-" + spanID + "-01", true, true},
+		{"not sampled", "00-" + traceID + "-" + spanID + "-00", true, false},
+		{"surrounding space", " 00-" + traceID + "-" + spanID + "-01 ", true, true},
+		{"future version", "01-" + traceID + "-" + spanID + "-01", true, true},
+		{"future version with more fields", "01-" + traceID + "-" + spanID + "-01-extra", true, true},
+		{"empty", "", false, false},
+		{"invalid version ff", "ff-" + traceID + "-" + spanID + "-01", false, false},
+		{"non-hex version", "0g-" + traceID + "-" + spanID + "-01", false, false},
+		{"version 00 with more fields", "00-" + traceID + "-" + spanID + "-01-extra", false, false},
+		{"future version without separator", "01-" + traceID + "-" + spanID + "-01x", false, false},
+		{"all-zero trace ID", "00-00000000000000000000000000000000-" + spanID + "-01", false, false},
+		{"all-zero span ID", "00-" + traceID + "-0000000000000000-01", false, false},
+		{"short trace ID", "00-" + traceID[1:] + "-" + spanID + "-01", false, false},
+		{"long span ID", "00-" + traceID + "-" + spanID + "a-01", false, false},
+		{"short flags", "00-" + traceID + "-" + spanID + "-1", false, false},
+		{"uppercase trace ID", "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanID + "-01", false, false},
+		{"uppercase span ID", "00-" + traceID + "-00F067AA0BA902B7-01", false, false},
+		{"uppercase version", "0A-" + traceID + "-" + spanID + "-01", false, false},
+	}
+	for _, tt := range tests {
+		sc, ok := ParseTraceparent(tt.value)
+		if ok != tt.ok {
+			t.Errorf("%s: ParseTraceparent(%q) ok = %v, want %v", tt.name, tt.value, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID || sc.Sampled != tt.sampled || !sc.Remote {
+			t.Errorf("%s: ParseTraceparent(%q) = %+v", tt.name, tt.value, sc)
+		}
+	}
+}
+
+func TestFormatTraceparentRoundTrip(t *testing.T) {
+	sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	parsed, ok := ParseTraceparent(FormatTraceparent(sc))
+	if !ok || parsed.TraceID != sc.TraceID || parsed.SpanID != sc.SpanID || !parsed.Sampled {
+		t.Errorf("round trip of %+v gave %+v, %v", sc, parsed, ok)
+	}
+}
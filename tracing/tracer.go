@@ -0,0 +1,203 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"projekat/logging"
+	"sync/atomic"
+	"time"
+)
+
+type Options struct {
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string
+	// SampleRatio is the share of new traces that are recorded. Traces
+	// continued from an incoming traceparent follow its sampled flag.
+	SampleRatio float64
+	// QueueSize bounds the finished spans waiting for export; spans ended
+	// while it is full are dropped.
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	ExportTimeout time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.ServiceName == "" {
+		o.ServiceName = "ars"
+	}
+	if o.SampleRatio <= 0 || o.SampleRatio > 1 {
+		o.SampleRatio = 1
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 2048
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 512
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 5 * time.Second
+	}
+	if o.ExportTimeout <= 0 {
+		o.ExportTimeout = 10 * time.Second
+	}
+	return o
+}
+
+// Tracer starts spans and exports them in batches from Run.
+type Tracer struct {
+	exporter Exporter
+	opts     Options
+	queue    chan SpanData
+	dropped  atomic.Uint64
+	exported atomic.Uint64
+	failed   atomic.Uint64
+}
+
+func New(exporter Exporter, opts Options) *Tracer {
+	opts = opts.withDefaults()
+	return &Tracer{
+		exporter: exporter,
+		opts:     opts,
+		queue:    make(chan SpanData, opts.QueueSize),
+	}
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+// SetDefault installs the tracer used by Start. Until it is called, or
+// after SetDefault(nil), Start returns nil spans and nothing is recorded.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Start begins an internal span as a child of the current span of ctx and
+// returns a context carrying it. End the span when the operation finishes.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return StartWithKind(ctx, name, KindInternal, attrs...)
+}
+
+// StartWithKind is Start for server spans, which begin serving a request,
+// and client spans, which wrap calls to other services.
+func StartWithKind(ctx context.Context, name string, kind Kind, attrs ...Attribute) (context.Context, *Span) {
+	t := defaultTracer.Load()
+	if t == nil || ctx.Value(untracedKey{}) != nil {
+		return ctx, nil
+	}
+	span := t.start(ctx, name, kind, attrs)
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind Kind, attrs []Attribute) *Span {
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = t.sample(sc.TraceID)
+	}
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			SpanContext: sc,
+			Parent:      parent.SpanID,
+			Name:        name,
+			Kind:        kind,
+			Start:       time.Now(),
+		},
+	}
+	if sc.Sampled {
+		span.data.Attributes = append(span.data.Attributes, attrs...)
+	}
+	return span
+}
+
+// sample decides from the random trace ID, so every span of a trace gets
+// the same decision.
+func (t *Tracer) sample(id TraceID) bool {
+	if t.opts.SampleRatio >= 1 {
+		return true
+	}
+	return float64(binary.BigEndian.Uint64(id[8:])) < t.opts.SampleRatio*math.MaxUint64
+}
+
+func (t *Tracer) enqueue(span SpanData) {
+	select {
+	case t.queue <- span:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// Stats counts spans by what became of them.
+type Stats struct {
+	Exported uint64
+	Failed   uint64
+	Dropped  uint64
+}
+
+func (t *Tracer) Stats() Stats {
+	return Stats{
+		Exported: t.exported.Load(),
+		Failed:   t.failed.Load(),
+		Dropped:  t.dropped.Load(),
+	}
+}
+
+// Run exports finished spans whenever a batch fills up and at least every
+// FlushInterval. When ctx is done it exports what is queued and returns.
+func (t *Tracer) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, t.opts.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			t.export(batch)
+			batch = make([]SpanData, 0, t.opts.BatchSize)
+		}
+	}
+	for {
+		select {
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) >= t.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case span := <-t.queue:
+					batch = append(batch, span)
+					if len(batch) >= t.opts.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *Tracer) export(batch []SpanData) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.opts.ExportTimeout)
+	defer cancel()
+	if err := t.exporter.Export(ctx, t.opts.ServiceName, batch); err != nil {
+		t.failed.Add(uint64(len(batch)))
+		logging.Default().Warn("Could not export spans", "spans", len(batch), "error", err)
+		return
+	}
+	t.exported.Add(uint64(len(batch)))
+}
+
+// Close releases the exporter.
+func (t *Tracer) Close() error {
+	return t.exporter.Close()
+}
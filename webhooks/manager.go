@@ -0,0 +1,380 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"projekat/events"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Options tune delivery. Zero values fall back to the defaults below.
+type Options struct {
+	MaxAttempts     int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+	Timeout         time.Duration
+	DeliveryLogSize int
+	// MaxConcurrent bounds the attempts in flight across all webhooks.
+	MaxConcurrent int
+	// QueueSize bounds the events waiting for delivery to one webhook.
+	// Events arriving while its queue is full are dropped and recorded as
+	// failed deliveries.
+	QueueSize int
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Minute
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.DeliveryLogSize <= 0 {
+		o.DeliveryLogSize = 100
+	}
+	if o.MaxConcurrent <= 0 {
+		o.MaxConcurrent = 16
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1000
+	}
+	return o
+}
+
+// Manager keeps the registered webhooks in memory and delivers matching
+// events from the broker to them. Each webhook has a bounded queue drained
+// by its own worker, so events reach a webhook in order and a slow receiver
+// only delays its own deliveries.
+type Manager struct {
+	mu         sync.RWMutex
+	webhooks   map[string]Webhook
+	deliveries map[string][]Delivery
+	queues     map[string]*queue
+
+	broker *events.Broker
+	client *http.Client
+	opts   Options
+	slots  chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewManager(broker *events.Broker, opts Options) *Manager {
+	opts = opts.withDefaults()
+	return &Manager{
+		webhooks:   make(map[string]Webhook),
+		deliveries: make(map[string][]Delivery),
+		queues:     make(map[string]*queue),
+		broker:     broker,
+		client:     &http.Client{Timeout: opts.Timeout},
+		opts:       opts,
+		slots:      make(chan struct{}, opts.MaxConcurrent),
+	}
+}
+
+// Register validates and stores a webhook. A secret is generated when none
+// is given; the returned webhook is the only place it is shown.
+func (m *Manager) Register(wh Webhook) (Webhook, error) {
+	if err := wh.validate(); err != nil {
+		return Webhook{}, err
+	}
+	wh.ID = randomID()
+	if wh.Secret == "" {
+		wh.Secret = randomID() + randomID()
+	}
+	wh.CreatedAt = time.Now().UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhooks[wh.ID] = wh
+	m.deliveries[wh.ID] = make([]Delivery, 0)
+	return wh, nil
+}
+
+// List returns all webhooks ordered by creation time, without secrets.
+func (m *Manager) List() []Webhook {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]Webhook, 0, len(m.webhooks))
+	for _, wh := range m.webhooks {
+		wh.Secret = ""
+		result = append(result, wh)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Get returns a webhook without its secret.
+func (m *Manager) Get(id string) (Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wh, ok := m.webhooks[id]
+	if !ok {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	wh.Secret = ""
+	return wh, nil
+}
+
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[id]; !ok {
+		return ErrWebhookNotFound
+	}
+	delete(m.webhooks, id)
+	delete(m.deliveries, id)
+	if q, ok := m.queues[id]; ok {
+		close(q.stop)
+		delete(m.queues, id)
+	}
+	return nil
+}
+
+// Deliveries returns the most recent delivery attempts, newest first.
+func (m *Manager) Deliveries(id string) ([]Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	log, ok := m.deliveries[id]
+	if !ok {
+		return nil, ErrWebhookNotFound
+	}
+	result := make([]Delivery, len(log))
+	for i := range log {
+		result[i] = log[len(log)-1-i]
+	}
+	return result, nil
+}
+
+// Run consumes events until ctx is done and then waits for in-flight
+// deliveries to give up. If the manager falls behind the broker it resumes
+// from the last event it saw.
+func (m *Manager) Run(ctx context.Context) {
+	defer m.wg.Wait()
+
+	lastID := m.broker.LastID()
+	for ctx.Err() == nil && !m.broker.Closed() {
+		sub, backlog, _ := m.broker.Subscribe(lastID)
+		for _, e := range backlog {
+			m.dispatch(ctx, e)
+			lastID = e.ID
+		}
+	stream:
+		for {
+			select {
+			case e, ok := <-sub.C:
+				if !ok {
+					break stream
+				}
+				m.dispatch(ctx, e)
+				lastID = e.ID
+			case <-ctx.Done():
+				m.broker.Unsubscribe(sub)
+				return
+			}
+		}
+	}
+}
+
+// job is an event waiting in a webhook's queue.
+type job struct {
+	webhook Webhook
+	event   events.Event
+	body    []byte
+}
+
+type queue struct {
+	jobs chan job
+	// stop is closed when the webhook is deleted.
+	stop chan struct{}
+}
+
+func (m *Manager) dispatch(ctx context.Context, e events.Event) {
+	m.mu.RLock()
+	targets := make([]Webhook, 0)
+	for _, wh := range m.webhooks {
+		if wh.Filter.Matches(e) && wh.Permits(e) {
+			targets = append(targets, wh)
+		}
+	}
+	m.mu.RUnlock()
+
+	if len(targets) == 0 {
+		return
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	for _, wh := range targets {
+		q, ok := m.queue(ctx, wh.ID)
+		if !ok {
+			continue
+		}
+		select {
+		case q.jobs <- job{webhook: wh, event: e, body: body}:
+		default:
+			m.record(wh.ID, Delivery{
+				ID:        randomID(),
+				EventID:   e.ID,
+				EventType: e.Type,
+				Time:      time.Now().UTC(),
+				Error:     "delivery queue full, event dropped",
+			})
+		}
+	}
+}
+
+// queue returns the queue of a webhook, starting its worker on first use.
+// It reports false when the webhook has been deleted in the meantime.
+func (m *Manager) queue(ctx context.Context, id string) (*queue, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[id]; !ok {
+		return nil, false
+	}
+	q, ok := m.queues[id]
+	if !ok {
+		q = &queue{
+			jobs: make(chan job, m.opts.QueueSize),
+			stop: make(chan struct{}),
+		}
+		m.queues[id] = q
+		m.wg.Add(1)
+		go m.work(ctx, q)
+	}
+	return q, true
+}
+
+// work delivers the jobs of one queue in order until ctx is done or the
+// webhook is deleted.
+func (m *Manager) work(ctx context.Context, q *queue) {
+	defer m.wg.Done()
+	for {
+		select {
+		case j := <-q.jobs:
+			m.deliver(ctx, j.webhook, j.event, j.body)
+		case <-q.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver posts the payload, retrying with exponential backoff on network
+// errors, 408, 429 and 5xx responses.
+func (m *Manager) deliver(ctx context.Context, wh Webhook, e events.Event, body []byte) {
+	deliveryID := randomID()
+	backoff := m.opts.InitialBackoff
+	for attempt := 1; attempt <= m.opts.MaxAttempts; attempt++ {
+		select {
+		case m.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		d := m.attempt(ctx, wh, e, body, deliveryID, attempt)
+		<-m.slots
+		m.record(wh.ID, d)
+
+		if d.Success || !retryable(d.StatusCode) || attempt == m.opts.MaxAttempts {
+			return
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff *= 2
+		if backoff > m.opts.MaxBackoff {
+			backoff = m.opts.MaxBackoff
+		}
+	}
+}
+
+func (m *Manager) attempt(ctx context.Context, wh Webhook, e events.Event, body []byte, deliveryID string, attempt int) Delivery {
+	d := Delivery{
+		ID:        deliveryID,
+		EventID:   e.ID,
+		EventType: e.Type,
+		Attempt:   attempt,
+		Time:      time.Now().UTC(),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", wh.ID)
+	req.Header.Set("X-Webhook-Delivery", deliveryID)
+	req.Header.Set("X-Webhook-Event", string(e.Type))
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", Sign(wh.Secret, timestamp, body))
+
+	resp, err := m.client.Do(req)
+	d.Duration = time.Since(d.Time)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	resp.Body.Close()
+	d.StatusCode = resp.StatusCode
+	d.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !d.Success {
+		d.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return d
+}
+
+func (m *Manager) record(webhookID string, d Delivery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log, ok := m.deliveries[webhookID]
+	if !ok {
+		// the webhook was deleted while the delivery was in flight
+		return
+	}
+	log = append(log, d)
+	if len(log) > m.opts.DeliveryLogSize {
+		log = log[len(log)-m.opts.DeliveryLogSize:]
+	}
+	m.deliveries[webhookID] = log
+}
+
+// retryable reports whether a failed attempt should be retried. Status 0
+// means the request did not get a response at all.
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests || status >= 500
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
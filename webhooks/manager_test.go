@@ -0,0 +1,302 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"projekat/auth"
+	"projekat/events"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is an httptest webhook endpoint that records what it receives.
+// Events named "warmup" are acknowledged without being recorded.
+type receiver struct {
+	mu       sync.Mutex
+	requests []receivedRequest
+	// respond, if set, decides the status of the n-th recorded request
+	// (counting from 1) and may block.
+	respond func(n int) int
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+	event  events.Event
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var e events.Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if e.Name == "warmup" {
+		return
+	}
+	rc.mu.Lock()
+	rc.requests = append(rc.requests, receivedRequest{header: r.Header.Clone(), body: body, event: e})
+	n := len(rc.requests)
+	rc.mu.Unlock()
+	if rc.respond != nil {
+		w.WriteHeader(rc.respond(n))
+	}
+}
+
+func (rc *receiver) received() []receivedRequest {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]receivedRequest(nil), rc.requests...)
+}
+
+// startManager registers a webhook for the receiver and runs the manager
+// until the test ends. It returns once the manager delivers events, since
+// it only sees events published after it subscribed.
+func startManager(t *testing.T, rc *receiver, opts Options) (*Manager, *events.Broker, Webhook) {
+	t.Helper()
+	server := httptest.NewServer(rc)
+	broker := events.NewBroker(100)
+	m := NewManager(broker, opts)
+	wh, err := m.Register(Webhook{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		server.Close()
+	})
+
+	waitFor(t, func() bool {
+		broker.Publish(events.Event{Type: events.Created, Resource: events.ResourceConfigs, Name: "warmup"})
+		deliveries, _ := m.Deliveries(wh.ID)
+		return len(deliveries) > 0
+	})
+	return m, broker, wh
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 5s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// publish sends versions first to last of config "db".
+func publish(broker *events.Broker, first, last int) {
+	for version := first; version <= last; version++ {
+		broker.Publish(events.Event{
+			Type:     events.NewVersion,
+			Resource: events.ResourceConfigs,
+			Name:     "db",
+			Version:  version,
+		})
+	}
+}
+
+func TestDeliveriesAreSignedAndInOrder(t *testing.T) {
+	rc := &receiver{}
+	_, broker, wh := startManager(t, rc, Options{})
+
+	publish(broker, 1, 20)
+	waitFor(t, func() bool { return len(rc.received()) == 20 })
+
+	now := time.Now()
+	for i, req := range rc.received() {
+		if req.event.Version != i+1 {
+			t.Errorf("request %d carries version %d, want %d", i, req.event.Version, i+1)
+		}
+		signature := req.header.Get("X-Webhook-Signature")
+		timestamp := req.header.Get("X-Webhook-Timestamp")
+		if err := Verify(wh.Secret, signature, timestamp, req.body, time.Minute, now); err != nil {
+			t.Errorf("request %d: %v", i, err)
+		}
+		if req.header.Get("X-Webhook-ID") != wh.ID || req.header.Get("X-Webhook-Event") != string(events.NewVersion) {
+			t.Errorf("request %d has headers %v", i, req.header)
+		}
+	}
+}
+
+func TestFailedDeliveriesAreRetried(t *testing.T) {
+	rc := &receiver{respond: func(n int) int {
+		if n < 3 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	}}
+	m, broker, wh := startManager(t, rc, Options{InitialBackoff: time.Millisecond})
+
+	publish(broker, 1, 1)
+	waitFor(t, func() bool { return len(rc.received()) == 3 })
+
+	waitFor(t, func() bool {
+		deliveries, _ := m.Deliveries(wh.ID)
+		return len(deliveries) > 0 && deliveries[0].Success
+	})
+	deliveries, _ := m.Deliveries(wh.ID)
+	// Newest first: two failures, then the success, after the warmup.
+	for i, want := range []int{http.StatusOK, http.StatusServiceUnavailable, http.StatusServiceUnavailable} {
+		if deliveries[i].StatusCode != want || deliveries[i].Attempt != 3-i {
+			t.Errorf("delivery %d = %+v, want attempt %d with status %d", i, deliveries[i], 3-i, want)
+		}
+	}
+}
+
+func TestNonRetryableFailureIsNotRetried(t *testing.T) {
+	rc := &receiver{respond: func(int) int { return http.StatusBadRequest }}
+	m, broker, wh := startManager(t, rc, Options{InitialBackoff: time.Millisecond})
+
+	publish(broker, 1, 1)
+	waitFor(t, func() bool {
+		deliveries, _ := m.Deliveries(wh.ID)
+		return len(deliveries) > 0 && deliveries[0].StatusCode == http.StatusBadRequest
+	})
+	time.Sleep(20 * time.Millisecond)
+	if n := len(rc.received()); n != 1 {
+		t.Errorf("receiver got %d requests, want 1", n)
+	}
+}
+
+func TestFullQueueDropsEvents(t *testing.T) {
+	release := make(chan struct{})
+	var releaseOnce sync.Once
+	rc := &receiver{respond: func(n int) int {
+		if n == 1 {
+			<-release
+		}
+		return http.StatusOK
+	}}
+	m, broker, wh := startManager(t, rc, Options{QueueSize: 2})
+	t.Cleanup(func() { releaseOnce.Do(func() { close(release) }) })
+
+	// The first event blocks the worker, two wait in the queue and the
+	// other seven are dropped.
+	publish(broker, 1, 1)
+	waitFor(t, func() bool { return len(rc.received()) == 1 })
+	publish(broker, 2, 10)
+	waitFor(t, func() bool {
+		deliveries, _ := m.Deliveries(wh.ID)
+		return countDropped(deliveries) == 7
+	})
+	releaseOnce.Do(func() { close(release) })
+	waitFor(t, func() bool { return len(rc.received()) == 3 })
+
+	time.Sleep(20 * time.Millisecond)
+	received := rc.received()
+	if len(received) != 3 {
+		t.Fatalf("receiver got %d events, want 3", len(received))
+	}
+	for i, req := range received {
+		if req.event.Version != i+1 {
+			t.Errorf("event %d has version %d, want %d", i, req.event.Version, i+1)
+		}
+	}
+}
+
+func countDropped(deliveries []Delivery) int {
+	n := 0
+	for _, d := range deliveries {
+		if d.Attempt == 0 && d.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDeleteStopsDeliveries(t *testing.T) {
+	rc := &receiver{}
+	m, broker, wh := startManager(t, rc, Options{})
+
+	if err := m.Delete(wh.ID); err != nil {
+		t.Fatal(err)
+	}
+	publish(broker, 1, 5)
+	time.Sleep(20 * time.Millisecond)
+	if n := len(rc.received()); n != 0 {
+		t.Errorf("receiver got %d events after the webhook was deleted", n)
+	}
+}
+
+func TestDeliveriesFollowThePrincipalsGrants(t *testing.T) {
+	m, broker, _ := startManager(t, &receiver{}, Options{})
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+	principal := auth.Principal{ID: "apikey:team-a", Grants: []auth.Grant{{
+		Permissions: []string{auth.ScopeConfigsRead},
+		Namespaces:  []string{"team-a"},
+		Resources:   []string{"configs/web_*"},
+	}}}
+	if _, err := m.Register(Webhook{URL: server.URL, Principal: &principal}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range []events.Event{
+		{Resource: events.ResourceConfigs, Namespace: "team-b", Name: "web_db"},
+		{Resource: events.ResourceConfigs, Namespace: "team-a", Name: "db"},
+		{Resource: events.ResourceGroups, Namespace: "team-a", Name: "web_db"},
+		{Resource: events.ResourceConfigs, Namespace: "team-a", Name: "web_db"},
+	} {
+		e.Type = events.Created
+		broker.Publish(e)
+	}
+	waitFor(t, func() bool { return len(rc.received()) >= 1 })
+	time.Sleep(20 * time.Millisecond)
+
+	received := rc.received()
+	if len(received) != 1 || received[0].event.Namespace != "team-a" || received[0].event.Resource != events.ResourceConfigs {
+		t.Errorf("received %d events, want only the readable config: %+v", len(received), received)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign("secret", timestamp, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		body      []byte
+		now       time.Time
+		want      error
+	}{
+		{"valid", "secret", signature, timestamp, body, now, nil},
+		{"within tolerance", "secret", signature, timestamp, body, now.Add(4 * time.Minute), nil},
+		{"replayed later", "secret", signature, timestamp, body, now.Add(10 * time.Minute), ErrStaleSignature},
+		{"from the future", "secret", signature, timestamp, body, now.Add(-10 * time.Minute), ErrStaleSignature},
+		{"wrong secret", "other", signature, timestamp, body, now, ErrInvalidSignature},
+		{"modified body", "secret", signature, timestamp, []byte(`{"id":2}`), now, ErrInvalidSignature},
+		{"timestamp swapped", "secret", signature, strconv.FormatInt(now.Unix()+1, 10), body, now, ErrInvalidSignature},
+		{"garbage timestamp", "secret", Sign("secret", "soon", body), "soon", body, now, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.signature, tt.timestamp, tt.body, 5*time.Minute, tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"path"
+	"projekat/auth"
+	"projekat/events"
+	"projekat/model"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidURL      = errors.New("webhook url must be an absolute http or https URL")
+	ErrInvalidResource = errors.New("resources may only contain 'configs' and 'groups'")
+
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+	ErrStaleSignature   = errors.New("webhook timestamp is outside the tolerance")
+)
+
+// Filter selects which events a webhook receives. Empty fields match
+// everything.
+type Filter struct {
+	// Resources limits events to "configs" and/or "groups".
+	Resources []string `json:"resources,omitempty"`
+	// Namespaces limits events to the listed namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Events limits events by type: created, new-version, deleted.
+	Events []events.Type `json:"events,omitempty"`
+	// NamePattern is a shell-style glob matched against the resource name,
+	// e.g. "web_*".
+	NamePattern string `json:"namePattern,omitempty"`
+	// Selector is a label selector. A config matches on its own labels, a
+	// group when any of its configs matches.
+	Selector string `json:"selector,omitempty"`
+
+	selector model.Selector
+}
+
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Filter    Filter    `json:"filter"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Principal is the caller that registered the webhook; it only receives
+	// events about resources the principal's grants let it read. Webhooks
+	// registered without authentication receive every matching event.
+	Principal *auth.Principal `json:"-"`
+}
+
+// Permits reports whether the webhook's principal may read the resource e
+// is about.
+func (wh Webhook) Permits(e events.Event) bool {
+	if wh.Principal == nil {
+		return true
+	}
+	permission := auth.ScopeConfigsRead
+	if e.Resource == events.ResourceGroups {
+		permission = auth.ScopeGroupsRead
+	}
+	return wh.Principal.Allows(permission, model.NamespaceOrDefault(e.Namespace), e.Resource+"/"+e.Name)
+}
+
+// Delivery records one attempt to deliver an event to a webhook.
+type Delivery struct {
+	ID         string        `json:"id"`
+	EventID    uint64        `json:"eventId"`
+	EventType  events.Type   `json:"eventType"`
+	Attempt    int           `json:"attempt"`
+	StatusCode int           `json:"statusCode,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Success    bool          `json:"success"`
+	Time       time.Time     `json:"time"`
+	Duration   time.Duration `json:"durationNs"`
+}
+
+// validate checks the webhook definition and compiles its selector.
+func (wh *Webhook) validate() error {
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	for _, r := range wh.Filter.Resources {
+		if r != events.ResourceConfigs && r != events.ResourceGroups {
+			return ErrInvalidResource
+		}
+	}
+	if _, err := path.Match(wh.Filter.NamePattern, ""); err != nil {
+		return err
+	}
+	selector, err := model.ParseSelector(wh.Filter.Selector)
+	if err != nil {
+		return err
+	}
+	wh.Filter.selector = selector
+	return nil
+}
+
+func (f Filter) Matches(e events.Event) bool {
+	if len(f.Resources) > 0 && !containsString(f.Resources, e.Resource) {
+		return false
+	}
+	if len(f.Namespaces) > 0 && !containsString(f.Namespaces, e.Namespace) {
+		return false
+	}
+	if len(f.Events) > 0 && !containsType(f.Events, e.Type) {
+		return false
+	}
+	if f.NamePattern != "" {
+		if ok, _ := path.Match(f.NamePattern, e.Name); !ok {
+			return false
+		}
+	}
+	if len(f.selector) == 0 {
+		return true
+	}
+	switch obj := e.Object.(type) {
+	case model.Config:
+		return f.selector.Matches(obj.Labels)
+	case model.ConfigGroup:
+		for _, cfg := range obj.Configs {
+			if f.selector.Matches(cfg.Labels) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Sign returns the value of the X-Webhook-Signature header: the HMAC of the
+// X-Webhook-Timestamp value, a ".", and the body. Covering the timestamp
+// lets receivers reject replayed deliveries.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery the way a receiver should: the signature must
+// match and the timestamp, in Unix seconds, must be within tolerance of now.
+func Verify(secret, signature, timestamp string, body []byte, tolerance time.Duration, now time.Time) error {
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrStaleSignature
+	}
+	return nil
+}
+
+func containsString(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsType(values []events.Type, v events.Type) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}